@@ -32,6 +32,11 @@ type MetricSpec struct {
 
 	// Categories contains an object to add metrics for crds by crd category. Categories are only evaluated, if MatchName is nil
 	Categories *MetricCategory `json:"categories,omitempty"`
+
+	// StorageVersionOnly watches only the storage version of matching CRDs instead of every version, so that objects are not counted once per version.
+	// The stores follow the storage version when it changes. Defaults to true
+	// +kubebuilder:default=true
+	StorageVersionOnly *bool `json:"storageVersionOnly,omitempty"`
 }
 
 // MetricStatus defines the observed state of Metric
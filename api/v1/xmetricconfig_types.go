@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// XMetricConfigSpec defines the desired state of XMetricConfig
+type XMetricConfigSpec struct {
+	// Resources lists the resources a metric store is registered for
+	// +listType=map
+	// +listMapKey=metricName
+	Resources []XMetricResource `json:"resources"`
+}
+
+// XMetricResource configures the metric store of a single resource
+type XMetricResource struct {
+	// MetricName is the name of the metric families exported for the resource. It must be unique across all XMetricConfigs.
+	// The resources registered by the wildcard * are named <metricName>_<singular resource>
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	MetricName string `json:"metricName"`
+	Group      string `json:"group"`
+	// Version of the resource. Defaults to the storage version of its CRD
+	// +optional
+	Version string `json:"version,omitempty"`
+	// Resource is the plural name of the resource, e.g. instances, or * for all resources of the group defined by CRDs
+	// +kubebuilder:validation:MinLength=1
+	Resource string `json:"resource"`
+	// Exclude lists the plural names of resources of the group not registered by the wildcard *, e.g. vpcs
+	Exclude []string `json:"exclude,omitempty"`
+	// Namespace restricts the metrics to objects in a single namespace
+	Namespace *string `json:"namespace,omitempty"`
+
+	// InfoMappings lists field paths exposed as labels on the _info family
+	InfoMappings []InfoMapping `json:"infoMappings,omitempty"`
+}
+
+// InfoMapping maps the value at FieldPath to a label of the _info family
+type InfoMapping struct {
+	FieldPath string `json:"fieldPath"`
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Label string `json:"label"`
+}
+
+// XMetricConfigStatus defines the observed state of XMetricConfig
+type XMetricConfigStatus struct {
+	Resources []XMetricResourceStatus `json:"resources,omitempty"`
+}
+
+// XMetricResourceStatus describes a metric store registered for a XMetricConfig
+type XMetricResourceStatus struct {
+	MetricName string  `json:"metricName"`
+	Group      string  `json:"group"`
+	Version    string  `json:"version"`
+	Resource   string  `json:"resource"`
+	Namespace  *string `json:"namespace,omitempty"`
+
+	// Conditions of the metric store: Registered and Failed
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Objects is the number of objects exported by the metric store
+	Objects int `json:"objects"`
+	// LastSyncTime is the time of the last list, watch or event of the metric
+	// store
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// Conditions of the metric stores of a XMetricConfig
+const (
+	// ConditionRegistered is True once the metric store is registered
+	ConditionRegistered = "Registered"
+	// ConditionFailed is True while the metric store fails to list and watch
+	// its resource, e.g. because of missing permissions or a missing CRD
+	ConditionFailed = "Failed"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// XMetricConfig is the Schema for the xmetricconfigs API
+type XMetricConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   XMetricConfigSpec   `json:"spec,omitempty"`
+	Status XMetricConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// XMetricConfigList contains a list of XMetricConfig
+type XMetricConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []XMetricConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&XMetricConfig{}, &XMetricConfigList{})
+}
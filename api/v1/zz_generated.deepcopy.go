@@ -22,6 +22,7 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -115,6 +116,21 @@ func (in *ClusterMetricStatus) DeepCopy() *ClusterMetricStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InfoMapping) DeepCopyInto(out *InfoMapping) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InfoMapping.
+func (in *InfoMapping) DeepCopy() *InfoMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(InfoMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metric) DeepCopyInto(out *Metric) {
 	*out = *in
@@ -225,6 +241,11 @@ func (in *MetricSpec) DeepCopyInto(out *MetricSpec) {
 		*out = new(MetricCategory)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.StorageVersionOnly != nil {
+		in, out := &in.StorageVersionOnly, &out.StorageVersionOnly
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricSpec.
@@ -292,3 +313,167 @@ func (in *WatchedResource) DeepCopy() *WatchedResource {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XMetricConfig) DeepCopyInto(out *XMetricConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XMetricConfig.
+func (in *XMetricConfig) DeepCopy() *XMetricConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(XMetricConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *XMetricConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XMetricConfigList) DeepCopyInto(out *XMetricConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]XMetricConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XMetricConfigList.
+func (in *XMetricConfigList) DeepCopy() *XMetricConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(XMetricConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *XMetricConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XMetricConfigSpec) DeepCopyInto(out *XMetricConfigSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]XMetricResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XMetricConfigSpec.
+func (in *XMetricConfigSpec) DeepCopy() *XMetricConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(XMetricConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XMetricConfigStatus) DeepCopyInto(out *XMetricConfigStatus) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]XMetricResourceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XMetricConfigStatus.
+func (in *XMetricConfigStatus) DeepCopy() *XMetricConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(XMetricConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XMetricResource) DeepCopyInto(out *XMetricResource) {
+	*out = *in
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Namespace != nil {
+		in, out := &in.Namespace, &out.Namespace
+		*out = new(string)
+		**out = **in
+	}
+	if in.InfoMappings != nil {
+		in, out := &in.InfoMappings, &out.InfoMappings
+		*out = make([]InfoMapping, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XMetricResource.
+func (in *XMetricResource) DeepCopy() *XMetricResource {
+	if in == nil {
+		return nil
+	}
+	out := new(XMetricResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XMetricResourceStatus) DeepCopyInto(out *XMetricResourceStatus) {
+	*out = *in
+	if in.Namespace != nil {
+		in, out := &in.Namespace, &out.Namespace
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XMetricResourceStatus.
+func (in *XMetricResourceStatus) DeepCopy() *XMetricResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(XMetricResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
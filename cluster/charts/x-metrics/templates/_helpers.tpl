@@ -60,3 +60,14 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Create the name of the secret of the webhook serving certificate
+*/}}
+{{- define "x-metrics.webhookSecretName" -}}
+{{- if .Values.webhook.certManager }}
+{{- include "x-metrics.fullname" . }}-webhook-tls
+{{- else }}
+{{- required "webhook.secretName is required if webhook.certManager is disabled" .Values.webhook.secretName }}
+{{- end }}
+{{- end }}
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"time"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// dump lists the configured resources once, writes their metric families to
+// stdout and returns.
+func dump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to a YAML file configuring the generated metrics. Required.")
+	timeout := fs.Duration("timeout", time.Minute, "Time to wait for the list of all resources.")
+	config.RegisterFlags(fs)
+	opts := zap.Options{}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if *configPath == "" {
+		return errors.New("--config is required")
+	}
+	c, err := xmetrics.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	conf, err := ctrl.GetConfig()
+	if err != nil {
+		return err
+	}
+	dc, err := dynamic.NewForConfig(conf)
+	if err != nil {
+		return err
+	}
+	disco, err := discovery.NewDiscoveryClientForConfig(conf)
+	if err != nil {
+		return err
+	}
+	resources, err := resolveResources(disco, c)
+	if err != nil {
+		return err
+	}
+
+	mm := xmetrics.NewManagedMetricsHandler(dc, c)
+	if mm.MetadataClient, err = metadata.NewForConfig(conf); err != nil {
+		return err
+	}
+	for _, cc := range c.Clusters {
+		cluster, err := xmetrics.NewCluster(cc)
+		if err != nil {
+			return err
+		}
+		mm.Clusters = append(mm.Clusters, cluster)
+	}
+	defer mm.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	for _, r := range resources {
+		mm.RegisterAndAddMetricStoreForGVR(ctx, r.metricName, r.gvr, "")
+	}
+	if err := mm.WaitForSync(ctx); err != nil {
+		return err
+	}
+	mm.WriteAll(os.Stdout)
+	return nil
+}
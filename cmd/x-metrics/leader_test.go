@@ -0,0 +1,36 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLeaderGauge(t *testing.T) {
+	elected := make(chan struct{})
+	g := newLeaderGauge(elected)
+	if diff := cmp.Diff(0.0, testutil.ToFloat64(g)); diff != "" {
+		t.Errorf("\nShould report a standby replica before the lease is acquired.\nToFloat64(...): -want, +got:\n%s", diff)
+	}
+	close(elected)
+	if diff := cmp.Diff(1.0, testutil.ToFloat64(g)); diff != "" {
+		t.Errorf("\nShould report the leader once the lease is acquired.\nToFloat64(...): -want, +got:\n%s", diff)
+	}
+}
@@ -17,26 +17,43 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 
 	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	"github.com/crossplane-contrib/x-metrics/pkg/controller/crossplane"
+	"github.com/crossplane-contrib/x-metrics/pkg/controller/discovery"
 	controllers "github.com/crossplane-contrib/x-metrics/pkg/controller/metric"
+	"github.com/crossplane-contrib/x-metrics/pkg/controller/xmetricconfig"
 	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/otlp"
+	"github.com/crossplane-contrib/x-metrics/pkg/pushgateway"
+	"github.com/crossplane-contrib/x-metrics/pkg/server"
+	xsharding "github.com/crossplane-contrib/x-metrics/pkg/sharding"
 
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	//+kubebuilder:scaffold:imports
@@ -57,14 +74,92 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "dump" {
+		if err := dump(os.Args[2:]); err != nil {
+			setupLog.Error(err, "unable to dump metrics")
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		if err := validateConfig(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var configPath string
+	var discoverCRDs bool
+	var discoveryCategories string
+	var categories string
+	var crossplaneStores bool
+	var secureMetricsAddr string
+	var tlsCertFile string
+	var tlsKeyFile string
+	var secureMetricsAuth bool
+	var shard int
+	var totalShards int
+	var autoSharding bool
+	var podName string
+	var podNamespace string
+	var otlpEndpoint string
+	var otlpInterval time.Duration
+	var otlpHeaders string
+	var pushgatewayURL string
+	var pushgatewayJob string
+	var pushgatewayGrouping string
+	var responseCacheTTL time.Duration
+	var resync time.Duration
+	var staticLabels string
+	var conditionEncoding string
+	var pprof bool
+	var debugStores string
+	var kubeAPIQPS float64
+	var kubeAPIBurst int
+	var kubeAPITimeout time.Duration
+	var maxInitialLists int
+	var enableWebhooks bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&secureMetricsAddr, "secure-metrics-bind-address", "", "The address the HTTPS metric endpoint binds to, e.g. :8443. Disabled if empty.")
+	flag.StringVar(&tlsCertFile, "tls-cert-file", "", "Path to the PEM encoded certificate of the HTTPS metric endpoint. Reloaded on change.")
+	flag.StringVar(&tlsKeyFile, "tls-key-file", "", "Path to the PEM encoded key of the HTTPS metric endpoint. Reloaded on change.")
+	flag.BoolVar(&secureMetricsAuth, "secure-metrics-auth", false, "Authenticate and authorize requests to the HTTPS metric endpoint by TokenReviews and SubjectAccessReviews.")
+	flag.IntVar(&shard, "shard", 0, "The index of this instance if objects are sharded across instances, from 0 to --total-shards - 1.")
+	flag.IntVar(&totalShards, "total-shards", 1, "The number of instances the objects are sharded across.")
+	flag.BoolVar(&autoSharding, "auto-sharding", false, "Derive --shard from the ordinal of the pod and --total-shards from the replicas of its StatefulSet.")
+	flag.StringVar(&podName, "pod", os.Getenv("POD_NAME"), "Name of the pod x-metrics is running in, used by --auto-sharding.")
+	flag.StringVar(&podNamespace, "pod-namespace", os.Getenv("POD_NAMESPACE"), "Namespace of the pod x-metrics is running in, used by --auto-sharding.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "URL of the OTLP/HTTP metrics endpoint of an OpenTelemetry collector to push metrics to, e.g. http://otel-collector:4318/v1/metrics. Disabled if empty.")
+	flag.DurationVar(&otlpInterval, "otlp-interval", otlp.DefaultInterval, "Interval between two pushes to the OTLP endpoint.")
+	flag.StringVar(&otlpHeaders, "otlp-headers", "", "Comma separated key=value pairs added as headers to OTLP requests.")
+	flag.StringVar(&pushgatewayURL, "pushgateway-url", "", "URL of a Prometheus Pushgateway to push the final metrics to on shutdown, e.g. http://pushgateway:9091. Disabled if empty.")
+	flag.StringVar(&pushgatewayJob, "pushgateway-job", pushgateway.DefaultJob, "Job label of the metrics pushed to the Pushgateway.")
+	flag.StringVar(&pushgatewayGrouping, "pushgateway-grouping", "", "Comma separated key=value pairs added as grouping labels of the metrics pushed to the Pushgateway.")
+	flag.DurationVar(&responseCacheTTL, "response-cache-ttl", 0, "Time a rendered /x-metrics response is served to further scrapes, e.g. 10s. Disabled if 0.")
+	flag.DurationVar(&resync, "resync", 0, "Period in which the objects of all stores are listed again, unless configured per resource, e.g. 1h. Disabled if 0.")
+	flag.StringVar(&staticLabels, "labels", "", "Comma separated key=value pairs added as labels to every series, e.g. environment=prod,region=eu.")
+	flag.StringVar(&conditionEncoding, "condition-encoding", "", "Encoding of the condition families of all resources not configuring their own, Value or StateSet. Defaults to Value.")
+	flag.BoolVar(&pprof, "pprof", false, "Serve the runtime profiles of x-metrics at /debug/pprof/ of the metric endpoints.")
+	flag.StringVar(&debugStores, "debug-stores", "", "Comma separated stores, named by their metric name or as <resource>.<group>, whose lists and watches are logged at the default verbosity, e.g. while debugging a single store.")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 20, "Maximum queries per second to the Kubernetes API server.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 30, "Maximum burst of queries to the Kubernetes API server.")
+	flag.DurationVar(&kubeAPITimeout, "kube-api-timeout", 0, "Timeout of the list requests of the metric stores, e.g. 1m. Watches are not timed out. Disabled if 0.")
+	flag.IntVar(&maxInitialLists, "max-initial-lists", 10, "Maximum number of resources listed concurrently before their first sync, e.g. on startup. Unlimited if 0.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the validating webhook of XMetricConfigs on port 9443, with the certificate in /tmp/k8s-webhook-server/serving-certs.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&configPath, "config", "", "Path to a YAML file configuring the generated metrics.")
+	flag.BoolVar(&discoverCRDs, "discover-crds", false, "Automatically register metric stores for all CRDs with one of the discovery categories.")
+	flag.StringVar(&discoveryCategories, "discovery-categories", strings.Join(discovery.DefaultCategories, ","), "Comma separated CRD categories registered by --discover-crds.")
+	flag.StringVar(&categories, "categories", "", "Comma separated API categories whose CRDs get a metric store, e.g. managed,claim,composite. Shorthand for --discover-crds --discovery-categories.")
+	flag.BoolVar(&crossplaneStores, "crossplane-stores", false, "Register built-in metric stores for the resources of Crossplane itself, like packages, XRDs and Compositions.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
+			"Enabling this will ensure there is only one active controller manager, "+
+			"which registers the metric stores. Standby replicas serve no metrics.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -73,7 +168,49 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	labels, err := parseKeyValues(staticLabels)
+	if err != nil {
+		setupLog.Error(err, "invalid flag", "flag", "labels")
+		os.Exit(1)
+	}
+	grouping, err := parseKeyValues(pushgatewayGrouping)
+	if err != nil {
+		setupLog.Error(err, "invalid flag", "flag", "pushgateway-grouping")
+		os.Exit(1)
+	}
+	headers, err := parseKeyValues(otlpHeaders)
+	if err != nil {
+		setupLog.Error(err, "invalid flag", "flag", "otlp-headers")
+		os.Exit(1)
+	}
+
 	conf := ctrl.GetConfigOrDie()
+	conf.QPS = float32(kubeAPIQPS)
+	conf.Burst = kubeAPIBurst
+	kc, err := kubernetes.NewForConfig(conf)
+	if err != nil {
+		setupLog.Error(err, "unable to set kubernetes client")
+		os.Exit(1)
+	}
+
+	sharding := xmetrics.Sharding{Shard: int32(shard), TotalShards: int32(totalShards)}
+	var statefulSet string
+	if autoSharding {
+		sharding, statefulSet, err = shardingFromStatefulSet(kc, podNamespace, podName)
+		if err != nil {
+			setupLog.Error(err, "unable to determine shard", "pod", podName)
+			os.Exit(1)
+		}
+	}
+	if err := sharding.Validate(); err != nil {
+		setupLog.Error(err, "invalid sharding")
+		os.Exit(1)
+	}
+	if enableLeaderElection && (autoSharding || sharding.TotalShards > 1) {
+		setupLog.Error(nil, "sharding requires all instances to register metric stores, disable --leader-elect")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(conf, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -81,6 +218,8 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "f3c9825e.crossplane.io",
+		// Hand over to a standby replica without waiting for the lease to expire
+		LeaderElectionReleaseOnCancel: true,
 	})
 
 	if err != nil {
@@ -88,19 +227,148 @@ func main() {
 		os.Exit(1)
 	}
 
+	metrics.Registry.MustRegister(newLeaderGauge(mgr.Elected()))
+
 	dc, err := dynamic.NewForConfig(conf)
 	if err != nil {
 		setupLog.Error(err, "unable to set dynamic client")
 		os.Exit(1)
 	}
-	mm := xmetrics.NewManagedMetricsHandler(dc)
-
-	err = mgr.AddMetricsExtraHandler("/x-metrics", &mm)
+	config := xmetrics.Config{}
+	if configPath != "" {
+		config, err = xmetrics.LoadConfig(configPath)
+		if err != nil {
+			setupLog.Error(err, "unable to load config", "path", configPath)
+			os.Exit(1)
+		}
+	}
+	flagConfig := xmetrics.Config{Labels: labels, Defaults: xmetrics.ResourceOptions{ConditionEncoding: xmetrics.ConditionEncoding(conditionEncoding)}}
+	if err := flagConfig.Validate(); err != nil {
+		setupLog.Error(err, "invalid flags")
+		os.Exit(1)
+	}
+	handlerOpts := []xmetrics.Option{xmetrics.WithResync(resync), xmetrics.WithLabels(labels)}
+	if debugStores != "" {
+		handlerOpts = append(handlerOpts, xmetrics.WithDebugStores(strings.Split(debugStores, ",")...))
+	}
+	if conditionEncoding != "" {
+		handlerOpts = append(handlerOpts, xmetrics.WithConditionEncoding(xmetrics.ConditionEncoding(conditionEncoding)))
+	}
+	mm := xmetrics.NewManagedMetricsHandler(dc, config, handlerOpts...)
+	mm.Sharding = sharding
+	mm.ResponseCacheTTL = responseCacheTTL
+	mm.CheckPermissions = true
+	mm.ListTimeout = kubeAPITimeout
+	mm.MaxInitialLists = maxInitialLists
+	mm.EventRecorder = mgr.GetEventRecorderFor("x-metrics")
+	if podName != "" && podNamespace != "" {
+		// Failures of stores not registered by an XMetricConfig are emitted
+		// on the pod of x-metrics
+		pod, err := kc.CoreV1().Pods(podNamespace).Get(context.Background(), podName, metav1.GetOptions{})
+		if err != nil {
+			setupLog.Error(err, "unable to get pod, not emitting failure events on it", "pod", podName)
+		} else {
+			mm.EventObject = pod
+		}
+	}
+	metrics.Registry.MustRegister(mm.SyncCollector())
+	for _, c := range config.Clusters {
+		cluster, err := xmetrics.NewCluster(c)
+		if err != nil {
+			setupLog.Error(err, "unable to set up remote cluster", "cluster", c.Name)
+			os.Exit(1)
+		}
+		mm.Clusters = append(mm.Clusters, cluster)
+	}
+	mm.MetadataClient, err = metadata.NewForConfig(conf)
 	if err != nil {
+		setupLog.Error(err, "unable to set metadata client")
+		os.Exit(1)
+	}
+
+	runnable := &xmetrics.Runnable{Handler: &mm, ResourcesPath: "/api/v1/resources", StoresPath: "/debug/stores", Pprof: pprof}
+	if pushgatewayURL != "" {
+		// The stores are closed on shutdown, so the final state is pushed first
+		runnable.BeforeClose = func() {
+			if err := (&pushgateway.Pusher{
+				URL:      pushgatewayURL,
+				Job:      pushgatewayJob,
+				Grouping: grouping,
+				Source:   &mm,
+			}).Push(); err != nil {
+				setupLog.Error(err, "unable to push metrics to Pushgateway")
+			}
+		}
+	}
+	if err = runnable.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to setup handler")
 		os.Exit(1)
 	}
 
+	if secureMetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+		mux.Handle("/x-metrics", &mm)
+		mux.Handle("/x-metrics/", mm.StoreHandler("/x-metrics/"))
+		mux.Handle("/api/v1/resources", mm.ResourcesHandler())
+		mux.Handle("/debug/stores", mm.StoresDebugHandler())
+		if pprof {
+			for path, h := range xmetrics.PprofHandlers() {
+				mux.Handle(path, h)
+			}
+		}
+		var handler http.Handler = mux
+		if secureMetricsAuth {
+			handler = server.WithAuth(kc, mux)
+		}
+		if err = mgr.Add(&server.Server{
+			Addr:     secureMetricsAddr,
+			CertFile: tlsCertFile,
+			KeyFile:  tlsKeyFile,
+			Handler:  handler,
+		}); err != nil {
+			setupLog.Error(err, "unable to setup HTTPS metrics server")
+			os.Exit(1)
+		}
+	}
+
+	if configPath != "" {
+		if err = mgr.Add(&xmetrics.ConfigReloader{Path: configPath, Handler: &mm, Elected: mgr.Elected()}); err != nil {
+			setupLog.Error(err, "unable to setup config reloader")
+			os.Exit(1)
+		}
+	}
+
+	if autoSharding {
+		if err = mgr.Add(&xsharding.Watcher{
+			Client:      kc,
+			Namespace:   podNamespace,
+			StatefulSet: statefulSet,
+			Pod:         podName,
+			Handler:     &mm,
+		}); err != nil {
+			setupLog.Error(err, "unable to setup StatefulSet watcher")
+			os.Exit(1)
+		}
+	}
+
+	if otlpEndpoint != "" {
+		resource := map[string]string{"service.name": "x-metrics"}
+		if config.ClusterName != "" {
+			resource["k8s.cluster.name"] = config.ClusterName
+		}
+		if err = mgr.Add(&otlp.Exporter{
+			Endpoint: otlpEndpoint,
+			Interval: otlpInterval,
+			Headers:  headers,
+			Resource: resource,
+			Source:   &mm,
+		}); err != nil {
+			setupLog.Error(err, "unable to setup OTLP exporter")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controllers.MetricReconciler{
 		Kind:      "Metric",
 		Client:    mgr.GetClient(),
@@ -119,6 +387,46 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Clustermetric")
 		os.Exit(1)
 	}
+	if err = (&xmetricconfig.XMetricConfigReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		MmHandler: &mm,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "XMetricConfig")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		if err = ctrl.NewWebhookManagedBy(mgr).
+			For(&metricsv1.XMetricConfig{}).
+			WithValidator(&xmetricconfig.Validator{Client: mgr.GetClient(), Mapper: mgr.GetRESTMapper()}).
+			Complete(); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "XMetricConfig")
+			os.Exit(1)
+		}
+	}
+	if categories != "" {
+		discoverCRDs, discoveryCategories = true, categories
+	}
+	if discoverCRDs {
+		if err = (&discovery.CRDReconciler{
+			Client:     mgr.GetClient(),
+			MmHandler:  &mm,
+			Categories: strings.Split(discoveryCategories, ","),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Discovery")
+			os.Exit(1)
+		}
+	}
+	if crossplaneStores {
+		if err = (&crossplane.CRDReconciler{
+			Client:    mgr.GetClient(),
+			MmHandler: &mm,
+			Config:    config,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Crossplane")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -136,3 +444,40 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// shardingFromStatefulSet returns the sharding of a pod of a StatefulSet and
+// the name of the StatefulSet.
+func shardingFromStatefulSet(kc kubernetes.Interface, namespace, pod string) (xmetrics.Sharding, string, error) {
+	ctx := context.Background()
+	name, err := xsharding.StatefulSet(ctx, kc, namespace, pod)
+	if err != nil {
+		return xmetrics.Sharding{}, "", err
+	}
+	sts, err := kc.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return xmetrics.Sharding{}, "", err
+	}
+	s, err := xsharding.FromStatefulSet(sts, pod)
+	return s, name, err
+}
+
+// parseKeyValues parses comma separated key=value pairs. Empty entries are
+// ignored, entries without = or with an empty key are rejected.
+func parseKeyValues(s string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not a key=value pair", kv)
+		}
+		k = strings.TrimSpace(k)
+		if k == "" {
+			return nil, fmt.Errorf("%q has an empty key", kv)
+		}
+		m[k] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	type want struct {
+		m   map[string]string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		s      string
+		want   want
+	}{
+		"Empty": {
+			reason: "Should return no pairs for an unset flag.",
+			s:      "",
+			want:   want{m: map[string]string{}},
+		},
+		"Pairs": {
+			reason: "Should parse comma separated pairs, trimming spaces and ignoring empty entries.",
+			s:      "environment=prod, region = eu,,",
+			want:   want{m: map[string]string{"environment": "prod", "region": "eu"}},
+		},
+		"EmptyValue": {
+			reason: "Should accept pairs with an empty value.",
+			s:      "environment=",
+			want:   want{m: map[string]string{"environment": ""}},
+		},
+		"MissingSeparator": {
+			reason: "Should reject entries without =.",
+			s:      "environment=prod,region",
+			want:   want{err: cmpopts.AnyError},
+		},
+		"EmptyKey": {
+			reason: "Should reject entries with an empty key.",
+			s:      " =prod",
+			want:   want{err: cmpopts.AnyError},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := parseKeyValues(tc.s)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nparseKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.m, m); diff != "" {
+				t.Errorf("\n%s\nparseKeyValues(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// resource is a configured resource resolved by the discovery API.
+type resource struct {
+	metricName string
+	gvr        schema.GroupVersionResource
+}
+
+// resolveResources resolves the configured resources by the discovery API.
+// Resources without a version resolve to the preferred version of their group.
+func resolveResources(d discovery.DiscoveryInterface, config xmetrics.Config) ([]resource, error) {
+	preferred, err := preferredVersions(d)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]resource, 0, len(config.Resources))
+	for _, r := range config.Resources {
+		res, err := resolveResource(d, preferred, r)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// preferredVersions returns the preferred version of each API group.
+func preferredVersions(d discovery.DiscoveryInterface) (map[string]string, error) {
+	groups, err := d.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("cannot discover API groups: %w", err)
+	}
+	preferred := map[string]string{}
+	for _, g := range groups.Groups {
+		preferred[g.Name] = g.PreferredVersion.Version
+	}
+	return preferred, nil
+}
+
+func resolveResource(d discovery.DiscoveryInterface, preferred map[string]string, r xmetrics.ResourceConfig) (resource, error) {
+	version := r.Version
+	if version == "" {
+		if version = preferred[r.Group]; version == "" {
+			return resource{}, fmt.Errorf("API group %q of resource %s not found", r.Group, r.Resource)
+		}
+	}
+	gv := schema.GroupVersion{Group: r.Group, Version: version}
+	list, err := d.ServerResourcesForGroupVersion(gv.String())
+	if err != nil {
+		return resource{}, fmt.Errorf("cannot discover resources of %s: %w", gv, err)
+	}
+	for _, ar := range list.APIResources {
+		if ar.Name == r.Resource {
+			return resource{
+				metricName: xmetrics.GetValidLabel(r.Group + "_" + ar.Kind + "_" + version),
+				gvr:        gv.WithResource(r.Resource),
+			}, nil
+		}
+	}
+	return resource{}, fmt.Errorf("resource %s not found in %s", r.Resource, gv)
+}
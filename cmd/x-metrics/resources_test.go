@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+func TestResolveResources(t *testing.T) {
+	d := &fake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "rds.aws.upbound.io/v1beta1", APIResources: []metav1.APIResource{{Name: "instances", Kind: "Instance"}}},
+		{GroupVersion: "rds.aws.upbound.io/v1alpha1", APIResources: []metav1.APIResource{{Name: "instances", Kind: "Instance"}}},
+	}}}
+
+	type want struct {
+		resources []resource
+		err       error
+	}
+	cases := map[string]struct {
+		reason    string
+		resources []xmetrics.ResourceConfig
+		want      want
+	}{
+		"Version": {
+			reason:    "Should resolve a resource of the configured version.",
+			resources: []xmetrics.ResourceConfig{{Group: "rds.aws.upbound.io", Version: "v1alpha1", Resource: "instances"}},
+			want: want{resources: []resource{{
+				metricName: "rds_aws_upbound_io_Instance_v1alpha1",
+				gvr:        schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1alpha1", Resource: "instances"},
+			}}},
+		},
+		"PreferredVersion": {
+			reason:    "Should resolve a resource without version to the preferred version of its group.",
+			resources: []xmetrics.ResourceConfig{{Group: "rds.aws.upbound.io", Resource: "instances"}},
+			want: want{resources: []resource{{
+				metricName: "rds_aws_upbound_io_Instance_v1beta1",
+				gvr:        schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"},
+			}}},
+		},
+		"UnknownGroup": {
+			reason:    "Should return an error if the group is not served.",
+			resources: []xmetrics.ResourceConfig{{Group: "ec2.aws.upbound.io", Resource: "vpcs"}},
+			want:      want{err: cmpopts.AnyError},
+		},
+		"UnknownResource": {
+			reason:    "Should return an error if the resource is not served.",
+			resources: []xmetrics.ResourceConfig{{Group: "rds.aws.upbound.io", Resource: "clusters"}},
+			want:      want{err: cmpopts.AnyError},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := resolveResources(d, xmetrics.Config{Resources: tc.resources})
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nresolveResources(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.resources, got, cmp.AllowUnexported(resource{})); diff != "" {
+				t.Errorf("\n%s\nresolveResources(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
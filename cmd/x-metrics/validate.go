@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// validateConfig checks the configuration against a cluster and reports all
+// errors to stdout.
+func validateConfig(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to a YAML file configuring the generated metrics. Required.")
+	config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+
+	if *configPath == "" {
+		return errors.New("--config is required")
+	}
+	c, err := xmetrics.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	conf, err := ctrl.GetConfig()
+	if err != nil {
+		return err
+	}
+	disco, err := discovery.NewDiscoveryClientForConfig(conf)
+	if err != nil {
+		return err
+	}
+	crds, err := apiextensionsclient.NewForConfig(conf)
+	if err != nil {
+		return err
+	}
+
+	errs := checkResources(context.Background(), disco, crds.CustomResourceDefinitions(), c)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		return fmt.Errorf("%s: %d errors", *configPath, len(errs))
+	}
+	fmt.Printf("%s: %d resources valid\n", *configPath, len(c.Resources))
+	return nil
+}
+
+// checkResources resolves the configured resources by the discovery API and
+// checks the field paths of their options against the schema of their CRD.
+// Resources not defined by a CRD are only resolved.
+func checkResources(ctx context.Context, d discovery.DiscoveryInterface, crds apiextensionsclient.CustomResourceDefinitionInterface, c xmetrics.Config) []error {
+	preferred, err := preferredVersions(d)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for i, r := range c.Resources {
+		res, err := resolveResource(d, preferred, r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resources[%d]: %w", i, err))
+			continue
+		}
+		crd, err := crds.Get(ctx, res.gvr.GroupResource().String(), metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resources[%d]: cannot get CRD: %w", i, err))
+			continue
+		}
+		for _, err := range c.ResourceConfigFor(res.gvr).ValidateSchema(xmetrics.VersionSchema(crd, res.gvr.Version)) {
+			errs = append(errs, fmt.Errorf("resources[%d] (%s): %w", i, res.gvr, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+func TestCheckResources(t *testing.T) {
+	d := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "rds.aws.upbound.io/v1beta1", APIResources: []metav1.APIResource{{Name: "instances", Kind: "Instance"}}},
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps", Kind: "ConfigMap"}}},
+	}}}
+	crds := fake.NewSimpleClientset(&apiextensions.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "instances.rds.aws.upbound.io"},
+		Spec: apiextensions.CustomResourceDefinitionSpec{
+			Group: "rds.aws.upbound.io",
+			Versions: []apiextensions.CustomResourceDefinitionVersion{{
+				Name: "v1beta1",
+				Schema: &apiextensions.CustomResourceValidation{OpenAPIV3Schema: &apiextensions.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensions.JSONSchemaProps{
+						"spec": {Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+							"forProvider": {Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+								"region": {Type: "string"},
+							}},
+						}},
+					},
+				}},
+			}},
+		},
+	}).ApiextensionsV1().CustomResourceDefinitions()
+
+	cases := map[string]struct {
+		reason string
+		config xmetrics.Config
+		want   []string
+	}{
+		"Valid": {
+			reason: "Should report no errors for resolvable resources with field paths in their schema.",
+			config: xmetrics.Config{Resources: []xmetrics.ResourceConfig{
+				{Group: "rds.aws.upbound.io", Resource: "instances", ResourceOptions: xmetrics.ResourceOptions{
+					InfoMappings: []xmetrics.InfoMappings{{FieldPath: "spec.forProvider.region", Label: "region"}},
+				}},
+				{Group: "", Version: "v1", Resource: "configmaps"},
+			}},
+		},
+		"Invalid": {
+			reason: "Should report all unknown resources and field paths.",
+			config: xmetrics.Config{
+				Defaults: xmetrics.ResourceOptions{
+					Gauges: []xmetrics.GaugeMappings{{FieldPath: "status.atProvider.allocatedStorage", Suffix: "storage"}},
+				},
+				Resources: []xmetrics.ResourceConfig{
+					{Group: "rds.aws.upbound.io", Resource: "clusters"},
+					{Group: "rds.aws.upbound.io", Resource: "instances"},
+				},
+			},
+			want: []string{
+				"resources[0]: resource clusters not found in rds.aws.upbound.io/v1beta1",
+				`resources[1] (rds.aws.upbound.io/v1beta1, Resource=instances): gauges[0]: field path "status.atProvider.allocatedStorage": field "status" not found in schema`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, err := range checkResources(context.Background(), d, crds, tc.config) {
+				got = append(got, err.Error())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ncheckResources(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package crossplane registers built-in metric stores for the resources of
+// Crossplane itself, like packages, XRDs and Compositions.
+package crossplane
+
+import (
+	"context"
+
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/controller/discovery"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// Store is a built-in metric store of a Crossplane resource.
+type Store struct {
+	// CRD is the name of the CRD of the resource
+	CRD string
+	// MetricName of the store
+	MetricName string
+	// Config of the store. Group, version and resource are set from the CRD
+	Config xmetrics.ResourceConfig
+}
+
+// conditionsOnly disables the families of the Ready and Synced conditions for
+// resources reporting other conditions, which are exported by _condition.
+var conditionsOnly = []string{"ready", "ready_time", "synced", "synced_time"}
+
+// packageConditions are the conditions of packages and their revisions.
+var packageConditions = []string{"Installed", "Healthy"}
+
+var packageConfig = xmetrics.ResourceConfig{ResourceOptions: xmetrics.ResourceOptions{
+	InfoMappings: []xmetrics.InfoMappings{
+		{FieldPath: "spec.package", Label: "package"},
+		{FieldPath: "spec.revisionActivationPolicy", Label: "activation_policy"},
+		{FieldPath: "status.currentRevision", Label: "current_revision"},
+		{FieldPath: "status.currentIdentifier", Label: "current_identifier"},
+	},
+	ExtraConditions:  packageConditions,
+	DisabledFamilies: conditionsOnly,
+}}
+
+var revisionConfig = xmetrics.ResourceConfig{ResourceOptions: xmetrics.ResourceOptions{
+	InfoMappings: []xmetrics.InfoMappings{
+		{FieldPath: "metadata.labels[pkg.crossplane.io/package]", Label: "package"},
+		{FieldPath: "spec.image", Label: "image"},
+		{FieldPath: "spec.desiredState", Label: "desired_state"},
+	},
+	Gauges: []xmetrics.GaugeMappings{
+		{FieldPath: "spec.revision", Suffix: "revision", Help: "Revision number of the {kind}"},
+	},
+	ExtraConditions:  packageConditions,
+	DisabledFamilies: conditionsOnly,
+}}
+
+var xrdConfig = xmetrics.ResourceConfig{ResourceOptions: xmetrics.ResourceOptions{
+	InfoMappings: []xmetrics.InfoMappings{
+		{FieldPath: "spec.group", Label: "xr_group"},
+		{FieldPath: "spec.names.kind", Label: "composite_kind"},
+		{FieldPath: "spec.claimNames.kind", Label: "claim_kind"},
+		{FieldPath: "spec.defaultCompositionRef.name", Label: "default_composition"},
+	},
+	Expressions: []xmetrics.ExpressionMappings{
+		{Expression: "spec.versions.filter(v, v.served).size()", Suffix: "served_versions", Help: "Number of served versions of the {kind}"},
+	},
+	ExtraConditions:  []string{"Established", "Offered"},
+	DisabledFamilies: conditionsOnly,
+}}
+
+// validationModeLabel is the annotation of the schema validation mode of
+// Compositions.
+const validationModeLabel = "metadata.annotations[crossplane.io/composition-schema-aware-validation-mode]"
+
+// compositionSteps are the counts of composed templates and function
+// pipeline steps, shared by Compositions and CompositionRevisions.
+var compositionSteps = []xmetrics.ExpressionMappings{
+	{Expression: "has(spec.resources) ? size(spec.resources) : 0", Suffix: "resources", Help: "Number of composed resource templates of the {kind}"},
+	{Expression: "has(spec.pipeline) ? size(spec.pipeline) : 0", Suffix: "pipeline_steps", Help: "Number of function pipeline steps of the {kind}"},
+}
+
+var compositionConfig = xmetrics.ResourceConfig{ResourceOptions: xmetrics.ResourceOptions{
+	InfoMappings: []xmetrics.InfoMappings{
+		{FieldPath: "spec.compositeTypeRef.apiVersion", Label: "composite_api_version"},
+		{FieldPath: "spec.compositeTypeRef.kind", Label: "composite_kind"},
+		{FieldPath: "spec.mode", Label: "mode"},
+		{FieldPath: validationModeLabel, Label: "validation_mode"},
+	},
+	Expressions:      compositionSteps,
+	DisabledFamilies: conditionsOnly,
+}}
+
+var compositionRevisionConfig = xmetrics.ResourceConfig{ResourceOptions: xmetrics.ResourceOptions{
+	InfoMappings: []xmetrics.InfoMappings{
+		{FieldPath: "metadata.labels[crossplane.io/composition-name]", Label: "composition"},
+		{FieldPath: "metadata.labels[crossplane.io/composition-hash]", Label: "hash"},
+		{FieldPath: "spec.compositeTypeRef.kind", Label: "composite_kind"},
+		{FieldPath: "spec.mode", Label: "mode"},
+	},
+	Gauges: []xmetrics.GaugeMappings{
+		{FieldPath: "spec.revision", Suffix: "revision", Help: "Revision number of the {kind}"},
+	},
+	Expressions:      compositionSteps,
+	DisabledFamilies: conditionsOnly,
+}}
+
+// usageConfig labels a Usage with the used and the using resource. Usages
+// without a using resource carry the reason of the deletion protection.
+var usageConfig = xmetrics.ResourceConfig{ResourceOptions: xmetrics.ResourceOptions{
+	InfoMappings: []xmetrics.InfoMappings{
+		{FieldPath: "spec.of.apiVersion", Label: "of_api_version"},
+		{FieldPath: "spec.of.kind", Label: "of_kind"},
+		{FieldPath: "spec.of.resourceRef.name", Label: "of_name"},
+		{FieldPath: "spec.by.apiVersion", Label: "by_api_version"},
+		{FieldPath: "spec.by.kind", Label: "by_kind"},
+		{FieldPath: "spec.by.resourceRef.name", Label: "by_name"},
+		{FieldPath: "spec.reason", Label: "reason"},
+		{FieldPath: "spec.replayDeletion", Label: "replay_deletion"},
+	},
+}}
+
+// environmentConfigConfig exports no data of EnvironmentConfigs by default.
+// The data to export is selected by info mappings in the configuration.
+var environmentConfigConfig = xmetrics.ResourceConfig{ResourceOptions: xmetrics.ResourceOptions{
+	Expressions: []xmetrics.ExpressionMappings{
+		{Expression: "has(self.data) ? size(self.data) : 0", Suffix: "data_keys", Help: "Number of top-level data keys of the {kind}"},
+	},
+	DisabledFamilies: conditionsOnly,
+}}
+
+// DefaultStores are the built-in stores. The conditions of packages and
+// revisions (Installed, Healthy) and of XRDs (Established, Offered) are
+// exported as extra conditions.
+var DefaultStores = []Store{
+	{CRD: "providers.pkg.crossplane.io", MetricName: "crossplane_provider", Config: packageConfig},
+	{CRD: "providerrevisions.pkg.crossplane.io", MetricName: "crossplane_provider_revision", Config: revisionConfig},
+	{CRD: "configurations.pkg.crossplane.io", MetricName: "crossplane_configuration", Config: packageConfig},
+	{CRD: "configurationrevisions.pkg.crossplane.io", MetricName: "crossplane_configuration_revision", Config: revisionConfig},
+	{CRD: "compositeresourcedefinitions.apiextensions.crossplane.io", MetricName: "crossplane_xrd", Config: xrdConfig},
+	{CRD: "compositions.apiextensions.crossplane.io", MetricName: "crossplane_composition", Config: compositionConfig},
+	{CRD: "compositionrevisions.apiextensions.crossplane.io", MetricName: "crossplane_composition_revision", Config: compositionRevisionConfig},
+	{CRD: "usages.apiextensions.crossplane.io", MetricName: "crossplane_usage", Config: usageConfig},
+	{CRD: "environmentconfigs.apiextensions.crossplane.io", MetricName: "crossplane_environment_config", Config: environmentConfigConfig},
+}
+
+// CRDReconciler registers the built-in store of a Crossplane resource for the
+// storage version of its CRD once the CRD exists, and removes it when the CRD
+// is deleted.
+type CRDReconciler struct {
+	client.Client
+	MmHandler xmetrics.IManagedMetricsHandler
+	// Stores to register. Defaults to DefaultStores
+	Stores []Store
+	// Config overriding the options of the stores
+	Config xmetrics.Config
+
+	stores map[string]Store
+	// registered holds the resource of the registered stores per CRD
+	registered map[string]schema.GroupVersionResource
+}
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+func (r *CRDReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	store, ok := r.stores[req.Name]
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+
+	crd := &apiextensions.CustomResourceDefinition{}
+	if err := r.Get(ctx, req.NamespacedName, crd); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+
+	var desired schema.GroupVersionResource
+	if crd.DeletionTimestamp.IsZero() {
+		if v, ok := discovery.StorageVersion(crd); ok {
+			desired = schema.GroupVersionResource{Group: crd.Spec.Group, Version: v, Resource: crd.Spec.Names.Plural}
+		}
+	}
+
+	current, ok := r.registered[req.Name]
+	if ok && current == desired {
+		return ctrl.Result{}, nil
+	}
+	if ok {
+		log.Info("removing metric store", "metricName", store.MetricName)
+		r.MmHandler.Stop(store.MetricName)
+		delete(r.registered, req.Name)
+	}
+	if desired.Empty() {
+		return ctrl.Result{}, nil
+	}
+
+	log.Info("registering metric store", "metricName", store.MetricName, "gvr", desired.String())
+	config := store.Config
+	config.Group, config.Version, config.Resource, config.Kind = desired.Group, desired.Version, desired.Resource, crd.Spec.Names.Kind
+	r.MmHandler.RegisterAndAddMetricStore(ctx, store.MetricName, desired, "", r.Config.ResourceConfigWithBase(config))
+	r.registered[req.Name] = desired
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CRDReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.init()
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("crossplane").
+		For(&apiextensions.CustomResourceDefinition{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			_, ok := r.stores[o.GetName()]
+			return ok
+		}))).
+		Complete(r)
+}
+
+func (r *CRDReconciler) init() {
+	if r.Stores == nil {
+		r.Stores = DefaultStores
+	}
+	r.stores = make(map[string]Store, len(r.Stores))
+	for _, s := range r.Stores {
+		r.stores[s.CRD] = s
+	}
+	r.registered = map[string]schema.GroupVersionResource{}
+}
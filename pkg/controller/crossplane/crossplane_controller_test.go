@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossplane
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/controller/metric/mock"
+)
+
+func newCRD(name string, versions ...apiextensions.CustomResourceDefinitionVersion) *apiextensions.CustomResourceDefinition {
+	return &apiextensions.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name + ".pkg.crossplane.io"},
+		Spec: apiextensions.CustomResourceDefinitionSpec{
+			Group:    "pkg.crossplane.io",
+			Names:    apiextensions.CustomResourceDefinitionNames{Kind: "Provider", Plural: name},
+			Versions: versions,
+		},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	v1 := apiextensions.CustomResourceDefinitionVersion{Name: "v1", Served: true, Storage: true}
+	v2 := apiextensions.CustomResourceDefinitionVersion{Name: "v2", Served: true, Storage: true}
+
+	cases := map[string]struct {
+		reason string
+		crd    *apiextensions.CustomResourceDefinition
+		want   map[string]schema.GroupVersionResource
+	}{
+		"Registered": {
+			reason: "Should keep the store of an unchanged CRD.",
+			crd:    newCRD("providers", v1),
+			want: map[string]schema.GroupVersionResource{
+				"crossplane_provider": {Group: "pkg.crossplane.io", Version: "v1", Resource: "providers"},
+			},
+		},
+		"StorageVersionChanged": {
+			reason: "Should re-register the store if the storage version changed.",
+			crd:    newCRD("providers", v2),
+			want: map[string]schema.GroupVersionResource{
+				"crossplane_provider": {Group: "pkg.crossplane.io", Version: "v2", Resource: "providers"},
+			},
+		},
+		"Deleted": {
+			reason: "Should remove the store if the CRD is gone.",
+			want:   map[string]schema.GroupVersionResource{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			if err := apiextensions.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			objs := []client.Object{}
+			if tc.crd != nil {
+				objs = append(objs, tc.crd)
+			}
+			mm := xmetrics.NewManagedMetricsHandlerMock()
+			r := &CRDReconciler{
+				Client:    fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build(),
+				MmHandler: &mm,
+			}
+			r.init()
+			// A previously registered store
+			gvr := schema.GroupVersionResource{Group: "pkg.crossplane.io", Version: "v1", Resource: "providers"}
+			mm.RegisterAndAddMetricStoreForGVR(context.Background(), "crossplane_provider", gvr, "")
+			r.registered["providers.pkg.crossplane.io"] = gvr
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "providers.pkg.crossplane.io"}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Reconcile(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, mm.GetRegister()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
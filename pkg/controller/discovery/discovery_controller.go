@@ -0,0 +1,137 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// DefaultCategories are the CRD categories of Crossplane resources.
+var DefaultCategories = []string{"managed", "crossplane"}
+
+// CRDReconciler registers a metric store for the storage version of CRDs
+// having one of the given categories, and removes it when the CRD is deleted.
+type CRDReconciler struct {
+	client.Client
+	MmHandler xmetrics.IManagedMetricsHandler
+	// Categories of the CRDs to watch. Defaults to DefaultCategories
+	Categories []string
+
+	// registered holds the names of the registered metric stores per CRD
+	registered map[string]map[string]struct{}
+}
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+func (r *CRDReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	crd := &apiextensions.CustomResourceDefinition{}
+	if err := r.Get(ctx, req.NamespacedName, crd); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+
+	desired := map[string]schema.GroupVersionResource{}
+	if crd.DeletionTimestamp.IsZero() && r.matches(crd) {
+		// Objects are returned by every served version, so only the storage
+		// version is watched. Stores are re-registered if it changes.
+		if v, ok := StorageVersion(crd); ok {
+			metricName := xmetrics.GetValidLabel(crd.Spec.Group + "_" + crd.Spec.Names.Kind + "_" + v)
+			desired[metricName] = schema.GroupVersionResource{Group: crd.Spec.Group, Version: v, Resource: crd.Spec.Names.Plural}
+		}
+	}
+
+	current := r.registered[req.Name]
+	if current == nil {
+		current = map[string]struct{}{}
+	}
+
+	for name := range current {
+		if _, ok := desired[name]; !ok {
+			log.Info("removing metric store", "metricName", name)
+			r.MmHandler.Stop(name)
+			delete(current, name)
+		}
+	}
+
+	for name, gvr := range desired {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		log.Info("registering metric store", "metricName", name, "gvr", gvr.String())
+		r.MmHandler.RegisterAndAddMetricStoreForGVR(ctx, name, gvr, "")
+		current[name] = struct{}{}
+	}
+
+	if len(current) == 0 {
+		delete(r.registered, req.Name)
+	} else {
+		r.registered[req.Name] = current
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CRDReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.init()
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("discovery").
+		For(&apiextensions.CustomResourceDefinition{}).
+		Complete(r)
+}
+
+func (r *CRDReconciler) init() {
+	r.registered = map[string]map[string]struct{}{}
+	if r.Categories == nil {
+		r.Categories = DefaultCategories
+	}
+}
+
+// matches returns true if the CRD has one of the configured categories.
+func (r *CRDReconciler) matches(crd *apiextensions.CustomResourceDefinition) bool {
+	for _, c := range crd.Spec.Names.Categories {
+		for _, w := range r.Categories {
+			if c == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StorageVersion returns the storage version of the CRD. If the storage
+// version is not served, the first served version is returned instead.
+func StorageVersion(crd *apiextensions.CustomResourceDefinition) (string, bool) {
+	var served string
+	for _, v := range crd.Spec.Versions {
+		if v.Storage && v.Served {
+			return v.Name, true
+		}
+		if v.Served && served == "" {
+			served = v.Name
+		}
+	}
+	return served, served != ""
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/controller/metric/mock"
+)
+
+func newCRD(categories []string, versions ...apiextensions.CustomResourceDefinitionVersion) *apiextensions.CustomResourceDefinition {
+	return &apiextensions.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "instances.rds.aws.upbound.io"},
+		Spec: apiextensions.CustomResourceDefinitionSpec{
+			Group: "rds.aws.upbound.io",
+			Names: apiextensions.CustomResourceDefinitionNames{
+				Kind:       "Instance",
+				Plural:     "instances",
+				Categories: categories,
+			},
+			Versions: versions,
+		},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	v1beta1 := apiextensions.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true}
+	v1alpha1 := apiextensions.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: false}
+	v1beta2 := apiextensions.CustomResourceDefinitionVersion{Name: "v1beta2", Served: true, Storage: true}
+
+	cases := map[string]struct {
+		reason string
+		crd    *apiextensions.CustomResourceDefinition
+		want   map[string]schema.GroupVersionResource
+	}{
+		"Managed": {
+			reason: "Should fall back to a served version if the storage version is not served.",
+			crd:    newCRD([]string{"crossplane", "managed", "aws"}, v1beta1, v1alpha1),
+			want: map[string]schema.GroupVersionResource{
+				"rds_aws_upbound_io_Instance_v1beta1": {Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"},
+			},
+		},
+		"StorageVersionChanged": {
+			reason: "Should re-register the store if the storage version changed.",
+			crd:    newCRD([]string{"managed"}, v1beta1, v1beta2),
+			want: map[string]schema.GroupVersionResource{
+				"rds_aws_upbound_io_Instance_v1beta2": {Group: "rds.aws.upbound.io", Version: "v1beta2", Resource: "instances"},
+			},
+		},
+		"OtherCategory": {
+			reason: "Should ignore CRDs without a Crossplane category.",
+			crd:    newCRD([]string{"all"}, v1beta1),
+			want:   map[string]schema.GroupVersionResource{},
+		},
+		"Deleted": {
+			reason: "Should remove stores of deleted CRDs.",
+			want:   map[string]schema.GroupVersionResource{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			if err := apiextensions.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			objs := []client.Object{}
+			if tc.crd != nil {
+				objs = append(objs, tc.crd)
+			}
+			mm := xmetrics.NewManagedMetricsHandlerMock()
+			r := &CRDReconciler{
+				Client:    fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build(),
+				MmHandler: &mm,
+			}
+			r.init()
+			// A previously registered store, which must be removed if the CRD is gone
+			mm.RegisterAndAddMetricStoreForGVR(context.Background(), "rds_aws_upbound_io_Instance_v1beta1", schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}, "")
+			r.registered["instances.rds.aws.upbound.io"] = map[string]struct{}{"rds_aws_upbound_io_Instance_v1beta1": {}}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "instances.rds.aws.upbound.io"}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Reconcile(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, mm.GetRegister()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
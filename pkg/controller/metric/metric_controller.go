@@ -24,14 +24,21 @@ import (
 
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	"github.com/crossplane-contrib/x-metrics/pkg/controller/discovery"
 	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -87,7 +94,7 @@ func (r *MetricReconciler) newReconciler() (client.Object, error) {
 // +kubebuilder:rbac:groups=metrics.crossplane.io,resources=metrics,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metrics.crossplane.io,resources=metrics/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=metrics.crossplane.io,resources=metrics/finalizers,verbs=update
-// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
 func (r *MetricReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	log := log.FromContext(ctx)
@@ -165,7 +172,9 @@ func (r *MetricReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 					Version:  v.Version,
 					Resource: v.Resource,
 				}
-				channel := r.MmHandler.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, currentNamespace)
+				// Failures of the store are emitted as events on the metric
+				// registering it first
+				channel := r.MmHandler.RegisterAndAddMetricStoreForGVR(xmetrics.WithEventObject(ctx, metric), metricName, gvr, currentNamespace)
 				metricsMemory[metricName] = &MetricsMemory{
 					Consumer: map[string]struct{}{
 						currentConsumerName: {},
@@ -215,9 +224,50 @@ func (r *MetricReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(reconcilerType).
+		// The stores follow the CRDs, e.g. changes of their storage version
+		Watches(&source.Kind{Type: &apiextensions.CustomResourceDefinition{}}, handler.EnqueueRequestsFromMapFunc(r.metricsOfCRD)).
 		Complete(r)
 }
 
+// metricsOfCRD returns the metrics of the reconciled kind selecting the CRD.
+func (r *MetricReconciler) metricsOfCRD(obj client.Object) []reconcile.Request {
+	crd, ok := obj.(*apiextensions.CustomResourceDefinition)
+	if !ok {
+		return nil
+	}
+	ro, err := r.Scheme.New(metricsv1.GroupVersion.WithKind(r.Kind + "List"))
+	if err != nil {
+		return nil
+	}
+	list, ok := ro.(client.ObjectList)
+	if !ok {
+		return nil
+	}
+	if err := r.List(context.Background(), list); err != nil {
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil
+	}
+	namespaced := r.Kind == "Metric"
+	var requests []reconcile.Request
+	for _, item := range items {
+		metric, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+		_, spec, _, err := getSpecAndStatus(metric)
+		if err != nil {
+			continue
+		}
+		if matchesCRD(spec, crd, namespaced) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: metric.GetNamespace(), Name: metric.GetName()}})
+		}
+	}
+	return requests
+}
+
 func (r *MetricReconciler) getGVRForMetric(ctx context.Context, metric *metricsv1.MetricSpec, namespaced bool) (*map[string]Resource, error) {
 
 	list := map[string]Resource{}
@@ -227,37 +277,56 @@ func (r *MetricReconciler) getGVRForMetric(ctx context.Context, metric *metricsv
 	if err := r.Client.List(ctx, &crds, &options); err != nil {
 		return nil, err
 	}
-	if metric.MatchName != nil || metric.Categories != nil {
-		for _, crd := range crds.Items {
-			name := crd.GetName()
-			match := true
-			if metric.MatchName != nil {
-				match, _ = regexp.MatchString(*metric.MatchName, name)
-			} else if metric.Categories != nil {
-				crdCategories := crd.Spec.Names.Categories
-				match = matchesCategories(crdCategories, metric.Categories.Values, metric.Categories.Join)
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		if !matchesCRD(metric, crd, namespaced) {
+			continue
+		}
+		versions := []string{}
+		if pointer.BoolDeref(metric.StorageVersionOnly, true) {
+			if v, ok := discovery.StorageVersion(crd); ok {
+				versions = append(versions, v)
 			}
-			inNameList := inList(metric.IncludeNames, name)
-			inExcludeList := inList(metric.ExcludeNames, name)
-			inNamespace := isNamespaced(&crd)
-			// if we need a gvr for a metrics resource, we only watch namespaced resources
-			if (match || inNameList) && !inExcludeList && (namespaced == inNamespace || !namespaced) {
-				for _, version := range crd.Spec.Versions {
-					metricName := xmetrics.GetValidLabel(crd.Spec.Group + "_" + crd.Spec.Names.Kind + "_" + version.Name)
-					list[metricName] = Resource{
-						Group:      crd.Spec.Group,
-						Version:    version.Name,
-						Resource:   crd.Spec.Names.Plural,
-						Kind:       crd.Spec.Names.Kind,
-						MetricName: metricName,
-					}
-				}
+		} else {
+			for _, version := range crd.Spec.Versions {
+				versions = append(versions, version.Name)
+			}
+		}
+		for _, version := range versions {
+			metricName := xmetrics.GetValidLabel(crd.Spec.Group + "_" + crd.Spec.Names.Kind + "_" + version)
+			list[metricName] = Resource{
+				Group:      crd.Spec.Group,
+				Version:    version,
+				Resource:   crd.Spec.Names.Plural,
+				Kind:       crd.Spec.Names.Kind,
+				MetricName: metricName,
 			}
 		}
 	}
 	return &list, nil
 }
 
+// matchesCRD returns true if the metric selects the CRD. Metrics, as opposed
+// to ClusterMetrics, only select namespaced CRDs.
+func matchesCRD(metric *metricsv1.MetricSpec, crd *apiextensions.CustomResourceDefinition, namespaced bool) bool {
+	if metric.MatchName == nil && metric.Categories == nil {
+		return false
+	}
+	name := crd.GetName()
+	match := true
+	if metric.MatchName != nil {
+		match, _ = regexp.MatchString(*metric.MatchName, name)
+	} else if metric.Categories != nil {
+		crdCategories := crd.Spec.Names.Categories
+		match = matchesCategories(crdCategories, metric.Categories.Values, metric.Categories.Join)
+	}
+	inNameList := inList(metric.IncludeNames, name)
+	inExcludeList := inList(metric.ExcludeNames, name)
+	inNamespace := isNamespaced(crd)
+	// if we need a gvr for a metrics resource, we only watch namespaced resources
+	return (match || inNameList) && !inExcludeList && (namespaced == inNamespace || !namespaced)
+}
+
 func matchesCategories(current []string, wanted []string, joinType metricsv1.MetricJoin) bool {
 	contains := false
 	for _, w := range wanted {
@@ -29,6 +29,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
 )
 
 var i = 0
@@ -350,7 +351,8 @@ var _ = Describe("Managed Metrics", func() {
 					Namespace: metricNamespace,
 				},
 				Spec: metricsv1.MetricSpec{
-					MatchName: &matchName,
+					StorageVersionOnly: pointer.Bool(false),
+					MatchName:          &matchName,
 				},
 			}
 
@@ -467,8 +469,9 @@ var _ = Describe("Managed Metrics", func() {
 					Namespace: metricNamespace,
 				},
 				Spec: metricsv1.MetricSpec{
-					MatchName:    &matchName,
-					IncludeNames: &[]string{"namecs.testb.cloud"},
+					StorageVersionOnly: pointer.Bool(false),
+					MatchName:          &matchName,
+					IncludeNames:       &[]string{"namecs.testb.cloud"},
 				},
 			}
 
@@ -634,6 +637,7 @@ var _ = Describe("Managed Metrics", func() {
 					Namespace: metricNamespace,
 				},
 				Spec: metricsv1.MetricSpec{
+					StorageVersionOnly: pointer.Bool(false),
 					Categories: &metricsv1.MetricCategory{
 						Values: []string{
 							"crda",
@@ -812,6 +816,7 @@ var _ = Describe("Managed Metrics", func() {
 					Namespace: metricNamespace,
 				},
 				Spec: metricsv1.MetricSpec{
+					StorageVersionOnly: pointer.Bool(false),
 					Categories: &metricsv1.MetricCategory{
 						Values: []string{
 							"crda",
@@ -899,6 +904,7 @@ var _ = Describe("Managed Metrics", func() {
 					Namespace: metricNamespace,
 				},
 				Spec: metricsv1.MetricSpec{
+					StorageVersionOnly: pointer.Bool(false),
 					Categories: &metricsv1.MetricCategory{
 						Values: []string{
 							"crdy",
@@ -1067,6 +1073,7 @@ var _ = Describe("Managed Metrics", func() {
 					Namespace: metricNamespace,
 				},
 				Spec: metricsv1.MetricSpec{
+					StorageVersionOnly: pointer.Bool(false),
 					Categories: &metricsv1.MetricCategory{
 						Values: []string{
 							"crda",
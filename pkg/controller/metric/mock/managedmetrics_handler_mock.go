@@ -21,6 +21,8 @@ import (
 	"net/http"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
 )
 
 type ManagedMetricsHandlerMock struct {
@@ -55,6 +57,10 @@ func (m *ManagedMetricsHandlerMock) RegisterAndAddMetricStoreForGVR(ctx context.
 	return make(chan struct{})
 }
 
+func (m *ManagedMetricsHandlerMock) RegisterAndAddMetricStore(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, config xmetrics.ResourceConfig) chan struct{} {
+	return m.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, namespace)
+}
+
 func (m *ManagedMetricsHandlerMock) GetRegister() map[string]schema.GroupVersionResource {
 	return m.register
 }
@@ -70,3 +76,11 @@ func (m *ManagedMetricsHandlerMock) ResetRegister() {
 func (m *ManagedMetricsHandlerMock) RemoveMetricStore(name string) {
 	delete(m.register, name)
 }
+
+func (m *ManagedMetricsHandlerMock) Stop(name string) {
+	delete(m.register, name)
+}
+
+func (m *ManagedMetricsHandlerMock) Close() {
+	m.register = map[string]schema.GroupVersionResource{}
+}
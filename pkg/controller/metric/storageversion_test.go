@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	mock "github.com/crossplane-contrib/x-metrics/pkg/controller/metric/mock"
+)
+
+func TestFollowStorageVersion(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := apiextensions.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := metricsv1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	newCRD := func(storage string) *apiextensions.CustomResourceDefinition {
+		crd := &apiextensions.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.org"},
+			Spec: apiextensions.CustomResourceDefinitionSpec{
+				Group: "example.org",
+				Names: apiextensions.CustomResourceDefinitionNames{Kind: "Widget", Plural: "widgets"},
+				Scope: apiextensions.NamespaceScoped,
+			},
+		}
+		for _, v := range []string{"v1alpha1", "v1"} {
+			crd.Spec.Versions = append(crd.Spec.Versions, apiextensions.CustomResourceDefinitionVersion{Name: v, Served: true, Storage: v == storage})
+		}
+		return crd
+	}
+	matchName := "widgets.example.org"
+	newMetric := func(name string, storageVersionOnly *bool) *metricsv1.Metric {
+		return &metricsv1.Metric{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Finalizers: []string{finalizerName}},
+			Spec:       metricsv1.MetricSpec{MatchName: &matchName, StorageVersionOnly: storageVersionOnly},
+		}
+	}
+	otherName := "gadgets.example.org"
+	other := &metricsv1.Metric{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other", Finalizers: []string{finalizerName}},
+		Spec:       metricsv1.MetricSpec{MatchName: &otherName},
+	}
+
+	cases := map[string]struct {
+		reason string
+		metric *metricsv1.Metric
+		want   []string
+		update []string
+	}{
+		"Default": {
+			reason: "Should watch the storage version by default and follow it when it changes.",
+			metric: newMetric("widgets", nil),
+			want:   []string{"example_org_Widget_v1alpha1"},
+			update: []string{"example_org_Widget_v1"},
+		},
+		"AllVersions": {
+			reason: "Should watch every version if storageVersionOnly is false.",
+			metric: newMetric("widgets", pointer.Bool(false)),
+			want:   []string{"example_org_Widget_v1", "example_org_Widget_v1alpha1"},
+			update: []string{"example_org_Widget_v1", "example_org_Widget_v1alpha1"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			metricsMemory = map[string]*MetricsMemory{}
+			crd := newCRD("v1alpha1")
+			c := fake.NewClientBuilder().WithScheme(s).WithObjects(crd, tc.metric, other).Build()
+			h := mock.NewManagedMetricsHandlerMock()
+			r := &MetricReconciler{Client: c, Kind: "Metric", Scheme: s, MmHandler: &h}
+			registered := func() []string {
+				t.Helper()
+				if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: tc.metric.Name}}); err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for name := range h.GetRegister() {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return names
+			}
+
+			if diff := cmp.Diff(tc.want, registered()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(crd), crd); err != nil {
+				t.Fatal(err)
+			}
+			crd.Spec.Versions[0].Storage, crd.Spec.Versions[1].Storage = false, true
+			if err := c.Update(context.Background(), crd); err != nil {
+				t.Fatal(err)
+			}
+			want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: tc.metric.Name}}}
+			if diff := cmp.Diff(want, r.metricsOfCRD(crd)); diff != "" {
+				t.Errorf("\n%s\nmetricsOfCRD(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.update, registered()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...) after the storage version changed: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xmetricconfig
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	"github.com/crossplane-contrib/x-metrics/pkg/controller/discovery"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// wildcard registers all resources of a group defined by CRDs.
+const wildcard = "*"
+
+// expandResource returns the resources registered by res: the resources of
+// its group defined by crds, except the excluded ones, if res is a wildcard,
+// or res itself. Missing versions default to the storage version of the CRD.
+func expandResource(res metricsv1.XMetricResource, crds []apiextensions.CustomResourceDefinition) ([]metricsv1.XMetricResource, error) {
+	if res.Resource != wildcard {
+		if res.Version != "" {
+			return []metricsv1.XMetricResource{res}, nil
+		}
+		crd := findCRD(crds, res.Group, res.Resource)
+		if crd == nil {
+			return nil, errors.New("version must be set for resources not defined by a CRD")
+		}
+		v, ok := discovery.StorageVersion(crd)
+		if !ok {
+			return nil, fmt.Errorf("CRD %s serves no version", crd.GetName())
+		}
+		res.Version = v
+		return []metricsv1.XMetricResource{res}, nil
+	}
+
+	excluded := map[string]bool{}
+	for _, e := range res.Exclude {
+		excluded[e] = true
+	}
+	var expanded []metricsv1.XMetricResource
+	for i := range crds {
+		crd := &crds[i]
+		if crd.Spec.Group != res.Group || excluded[crd.Spec.Names.Plural] || !crd.DeletionTimestamp.IsZero() {
+			continue
+		}
+		version, ok := res.Version, servesVersion(crd, res.Version)
+		if version == "" {
+			version, ok = discovery.StorageVersion(crd)
+		}
+		if !ok {
+			continue
+		}
+		singular := crd.Spec.Names.Singular
+		if singular == "" {
+			singular = strings.ToLower(crd.Spec.Names.Kind)
+		}
+		r := *res.DeepCopy()
+		r.MetricName = xmetrics.GetValidLabel(res.MetricName + "_" + singular)
+		r.Version = version
+		r.Resource = crd.Spec.Names.Plural
+		r.Exclude = nil
+		expanded = append(expanded, r)
+	}
+	sort.Slice(expanded, func(i, j int) bool {
+		return expanded[i].MetricName < expanded[j].MetricName
+	})
+	return expanded, nil
+}
+
+// findCRD returns the CRD of the resource, nil if there is none.
+func findCRD(crds []apiextensions.CustomResourceDefinition, group, resource string) *apiextensions.CustomResourceDefinition {
+	for i := range crds {
+		if crds[i].Spec.Group == group && crds[i].Spec.Names.Plural == resource {
+			return &crds[i]
+		}
+	}
+	return nil
+}
+
+// servesVersion returns true if the CRD serves version.
+func servesVersion(crd *apiextensions.CustomResourceDefinition, version string) bool {
+	for _, v := range crd.Spec.Versions {
+		if v.Name == version && v.Served {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xmetricconfig
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+)
+
+func newCRD(group, kind, plural string, versions ...apiextensions.CustomResourceDefinitionVersion) apiextensions.CustomResourceDefinition {
+	return apiextensions.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: plural + "." + group},
+		Spec: apiextensions.CustomResourceDefinitionSpec{
+			Group:    group,
+			Names:    apiextensions.CustomResourceDefinitionNames{Kind: kind, Plural: plural},
+			Versions: versions,
+		},
+	}
+}
+
+func TestExpandResource(t *testing.T) {
+	v1beta1 := apiextensions.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true, Storage: true}
+	v1alpha1 := apiextensions.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: true}
+	crds := []apiextensions.CustomResourceDefinition{
+		newCRD("ec2.aws.upbound.io", "Instance", "instances", v1beta1, v1alpha1),
+		newCRD("ec2.aws.upbound.io", "VPC", "vpcs", v1beta1),
+		newCRD("ec2.aws.upbound.io", "Subnet", "subnets", v1beta1),
+		newCRD("rds.aws.upbound.io", "Instance", "instances", v1beta1),
+	}
+
+	type want struct {
+		resources []metricsv1.XMetricResource
+		err       bool
+	}
+	cases := map[string]struct {
+		reason string
+		res    metricsv1.XMetricResource
+		want   want
+	}{
+		"Resource": {
+			reason: "Should return a resource with version as is.",
+			res:    metricsv1.XMetricResource{MetricName: "configmaps", Version: "v1", Resource: "configmaps"},
+			want:   want{resources: []metricsv1.XMetricResource{{MetricName: "configmaps", Version: "v1", Resource: "configmaps"}}},
+		},
+		"StorageVersion": {
+			reason: "Should default the version of a resource to the storage version of its CRD.",
+			res:    metricsv1.XMetricResource{MetricName: "rds", Group: "rds.aws.upbound.io", Resource: "instances"},
+			want:   want{resources: []metricsv1.XMetricResource{{MetricName: "rds", Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}}},
+		},
+		"NoCRD": {
+			reason: "Should return an error for resources without version and CRD.",
+			res:    metricsv1.XMetricResource{MetricName: "configmaps", Resource: "configmaps"},
+			want:   want{err: true},
+		},
+		"Wildcard": {
+			reason: "Should expand a wildcard to the resources of its group except the excluded ones.",
+			res:    metricsv1.XMetricResource{MetricName: "ec2", Group: "ec2.aws.upbound.io", Resource: "*", Exclude: []string{"subnets"}},
+			want: want{resources: []metricsv1.XMetricResource{
+				{MetricName: "ec2_instance", Group: "ec2.aws.upbound.io", Version: "v1beta1", Resource: "instances"},
+				{MetricName: "ec2_vpc", Group: "ec2.aws.upbound.io", Version: "v1beta1", Resource: "vpcs"},
+			}},
+		},
+		"WildcardVersion": {
+			reason: "Should only expand a wildcard with version to the resources serving the version.",
+			res:    metricsv1.XMetricResource{MetricName: "ec2", Group: "ec2.aws.upbound.io", Version: "v1alpha1", Resource: "*"},
+			want: want{resources: []metricsv1.XMetricResource{
+				{MetricName: "ec2_instance", Group: "ec2.aws.upbound.io", Version: "v1alpha1", Resource: "instances"},
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := expandResource(tc.res, crds)
+			if diff := cmp.Diff(tc.want, want{resources: got, err: err != nil}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nexpandResource(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
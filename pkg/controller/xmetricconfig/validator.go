@@ -0,0 +1,155 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xmetricconfig
+
+import (
+	"context"
+	"fmt"
+
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// Validator rejects XMetricConfigs with invalid or duplicate metric names,
+// unknown resources and info mappings whose field paths are malformed or not
+// part of the schema of the resource. Wildcards are validated by the resources
+// they register.
+type Validator struct {
+	Client client.Reader
+	Mapper meta.RESTMapper
+}
+
+//+kubebuilder:webhook:path=/validate-metrics-crossplane-io-v1-xmetricconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=metrics.crossplane.io,resources=xmetricconfigs,verbs=create;update,versions=v1,name=vxmetricconfig.metrics.crossplane.io,admissionReviewVersions=v1
+
+// ValidateCreate validates a created XMetricConfig.
+func (v *Validator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(ctx, obj)
+}
+
+// ValidateUpdate validates an updated XMetricConfig.
+func (v *Validator) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) error {
+	return v.validate(ctx, newObj)
+}
+
+// ValidateDelete allows all deletions.
+func (v *Validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func (v *Validator) validate(ctx context.Context, obj runtime.Object) error {
+	config, ok := obj.(*metricsv1.XMetricConfig)
+	if !ok {
+		return fmt.Errorf("expected a XMetricConfig but got %T", obj)
+	}
+	crds := &apiextensions.CustomResourceDefinitionList{}
+	if err := v.Client.List(ctx, crds); err != nil {
+		return fmt.Errorf("cannot list CRDs: %w", err)
+	}
+	taken, err := v.metricNames(ctx, config.GetName(), crds.Items)
+	if err != nil {
+		return err
+	}
+
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i, res := range config.Spec.Resources {
+		path := field.NewPath("spec", "resources").Index(i)
+		if !xmetrics.ValidLabelName(res.MetricName) {
+			errs = append(errs, field.Invalid(path.Child("metricName"), res.MetricName, "must consist of letters, digits and underscores and must not start with a digit or two underscores"))
+		}
+		if len(res.Exclude) > 0 && res.Resource != wildcard {
+			errs = append(errs, field.Forbidden(path.Child("exclude"), "only allowed for the wildcard resource *"))
+		}
+		labels := map[string]bool{}
+		for j, m := range res.InfoMappings {
+			labelPath := path.Child("infoMappings").Index(j).Child("label")
+			switch {
+			case !xmetrics.ValidLabelName(m.Label):
+				errs = append(errs, field.Invalid(labelPath, m.Label, "must be a valid Prometheus label name"))
+			case xmetrics.ReservedInfoLabel(m.Label):
+				errs = append(errs, field.Invalid(labelPath, m.Label, "is reserved for a label set by x-metrics"))
+			case labels[m.Label]:
+				errs = append(errs, field.Duplicate(labelPath, m.Label))
+			}
+			labels[m.Label] = true
+		}
+
+		expanded, err := expandResource(res, crds.Items)
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child("version"), res.Version, err.Error()))
+			continue
+		}
+		for _, r := range expanded {
+			switch {
+			case seen[r.MetricName]:
+				errs = append(errs, field.Duplicate(path.Child("metricName"), r.MetricName))
+			case taken[r.MetricName] != "":
+				errs = append(errs, field.Invalid(path.Child("metricName"), r.MetricName, fmt.Sprintf("already used by XMetricConfig %s", taken[r.MetricName])))
+			}
+			seen[r.MetricName] = true
+
+			gvr := schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+			if _, err := v.Mapper.KindFor(gvr); err != nil {
+				errs = append(errs, field.Invalid(path, gvr.String(), fmt.Sprintf("unknown resource: %v", err)))
+				continue
+			}
+			var s *apiextensions.JSONSchemaProps
+			if crd := findCRD(crds.Items, r.Group, r.Resource); crd != nil {
+				s = xmetrics.VersionSchema(crd, r.Version)
+			}
+			for _, err := range resourceConfig(r).ValidateSchema(s) {
+				errs = append(errs, field.Invalid(path, gvr.String(), err.Error()))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return kerrors.NewInvalid(metricsv1.GroupVersion.WithKind("XMetricConfig").GroupKind(), config.GetName(), errs)
+	}
+	return nil
+}
+
+// metricNames returns the metric names of the resources registered by all
+// XMetricConfigs except the one named exclude, mapped to the name of their
+// XMetricConfig.
+func (v *Validator) metricNames(ctx context.Context, exclude string, crds []apiextensions.CustomResourceDefinition) (map[string]string, error) {
+	l := &metricsv1.XMetricConfigList{}
+	if err := v.Client.List(ctx, l); err != nil {
+		return nil, fmt.Errorf("cannot list XMetricConfigs: %w", err)
+	}
+	names := map[string]string{}
+	for _, c := range l.Items {
+		if c.GetName() == exclude {
+			continue
+		}
+		for _, res := range c.Spec.Resources {
+			// Resources which cannot be expanded are not registered
+			expanded, _ := expandResource(res, crds)
+			for _, r := range expanded {
+				names[r.MetricName] = c.GetName()
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xmetricconfig
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+)
+
+func TestValidate(t *testing.T) {
+	crd := &apiextensions.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "instances.rds.aws.upbound.io"},
+		Spec: apiextensions.CustomResourceDefinitionSpec{
+			Group: "rds.aws.upbound.io",
+			Names: apiextensions.CustomResourceDefinitionNames{Kind: "Instance", Plural: "instances", Singular: "instance"},
+			Versions: []apiextensions.CustomResourceDefinitionVersion{{
+				Name:    "v1beta1",
+				Served:  true,
+				Storage: true,
+				Schema: &apiextensions.CustomResourceValidation{OpenAPIV3Schema: &apiextensions.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensions.JSONSchemaProps{
+						"spec": {Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+							"forProvider": {Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+								"region": {Type: "string"},
+							}},
+						}},
+					},
+				}},
+			}},
+		},
+	}
+	other := &metricsv1.XMetricConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "other"},
+		Spec: metricsv1.XMetricConfigSpec{Resources: []metricsv1.XMetricResource{
+			{MetricName: "buckets", Group: "s3.aws.upbound.io", Version: "v1beta1", Resource: "buckets"},
+			{MetricName: "rds", Group: "rds.aws.upbound.io", Resource: "*", Exclude: []string{"clusters"}},
+		}},
+	}
+	instances := metricsv1.XMetricResource{MetricName: "instances", Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+
+	cases := map[string]struct {
+		reason    string
+		resources func() []metricsv1.XMetricResource
+		want      []metav1.StatusCause
+	}{
+		"Valid": {
+			reason: "Should accept valid resources with info mappings on fields of the schema.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.InfoMappings = []metricsv1.InfoMapping{{FieldPath: "spec.forProvider.region", Label: "region"}, {FieldPath: "metadata.labels[team]", Label: "team"}}
+				return []metricsv1.XMetricResource{r, {MetricName: "configmaps", Version: "v1", Resource: "configmaps"}}
+			},
+		},
+		"InvalidMetricName": {
+			reason: "Should reject metric names which are not valid Prometheus names.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.MetricName = "rds-instances"
+				return []metricsv1.XMetricResource{r}
+			},
+			want: []metav1.StatusCause{{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0].metricName"}},
+		},
+		"DuplicateMetricName": {
+			reason: "Should reject metric names used twice in the config.",
+			resources: func() []metricsv1.XMetricResource {
+				return []metricsv1.XMetricResource{instances, instances}
+			},
+			want: []metav1.StatusCause{{Type: metav1.CauseTypeFieldValueDuplicate, Field: "spec.resources[1].metricName"}},
+		},
+		"MetricNameOfOtherConfig": {
+			reason: "Should reject metric names used by another XMetricConfig.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.MetricName = "buckets"
+				return []metricsv1.XMetricResource{r}
+			},
+			want: []metav1.StatusCause{{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0].metricName"}},
+		},
+		"UnknownResource": {
+			reason: "Should reject resources unknown to the API server.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.Version = "v1"
+				return []metricsv1.XMetricResource{r}
+			},
+			want: []metav1.StatusCause{{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0]"}},
+		},
+		"Wildcard": {
+			reason: "Should accept a wildcard registering the resources of a group.",
+			resources: func() []metricsv1.XMetricResource {
+				return []metricsv1.XMetricResource{{MetricName: "aws_rds", Group: "rds.aws.upbound.io", Resource: "*"}}
+			},
+		},
+		"WildcardMetricNameOfOtherConfig": {
+			reason: "Should reject a metric name used by another XMetricConfig for a resource of a wildcard.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.MetricName = "rds_instance"
+				return []metricsv1.XMetricResource{r}
+			},
+			want: []metav1.StatusCause{{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0].metricName"}},
+		},
+		"ExcludeWithoutWildcard": {
+			reason: "Should reject exclusions of resources which are not a wildcard.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.Exclude = []string{"clusters"}
+				return []metricsv1.XMetricResource{r}
+			},
+			want: []metav1.StatusCause{{Type: metav1.CauseType(field.ErrorTypeForbidden), Field: "spec.resources[0].exclude"}},
+		},
+		"NoVersion": {
+			reason: "Should reject resources without version which are not defined by a CRD.",
+			resources: func() []metricsv1.XMetricResource {
+				return []metricsv1.XMetricResource{{MetricName: "configmaps", Resource: "configmaps"}}
+			},
+			want: []metav1.StatusCause{{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0].version"}},
+		},
+		"InvalidInfoMappings": {
+			reason: "Should reject malformed field paths, fields missing in the schema and invalid labels.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.InfoMappings = []metricsv1.InfoMapping{
+					{FieldPath: "spec.forProvider[", Label: "region"},
+					{FieldPath: "spec.forProvider.zone", Label: "zone"},
+					{FieldPath: "spec.forProvider.region", Label: "aws-region"},
+				}
+				return []metricsv1.XMetricResource{r}
+			},
+			want: []metav1.StatusCause{
+				{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0].infoMappings[2].label"},
+				{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0]"},
+				{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0]"},
+			},
+		},
+		"ReservedInfoLabels": {
+			reason: "Should reject info mapping labels colliding with labels set by x-metrics or other mappings.",
+			resources: func() []metricsv1.XMetricResource {
+				r := instances
+				r.InfoMappings = []metricsv1.InfoMapping{
+					{FieldPath: "spec.forProvider.region", Label: "name"},
+					{FieldPath: "spec.forProvider.region", Label: "provider_config"},
+					{FieldPath: "spec.forProvider.region", Label: "region"},
+					{FieldPath: "spec.forProvider.region", Label: "region"},
+				}
+				return []metricsv1.XMetricResource{r}
+			},
+			want: []metav1.StatusCause{
+				{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0].infoMappings[0].label"},
+				{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.resources[0].infoMappings[1].label"},
+				{Type: metav1.CauseTypeFieldValueDuplicate, Field: "spec.resources[0].infoMappings[3].label"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			if err := apiextensions.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			if err := metricsv1.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(schema.GroupVersionKind{Group: "rds.aws.upbound.io", Version: "v1beta1", Kind: "Instance"}, meta.RESTScopeRoot)
+			mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+			v := &Validator{
+				Client: fake.NewClientBuilder().WithScheme(s).WithObjects(crd, other).Build(),
+				Mapper: mapper,
+			}
+
+			config := &metricsv1.XMetricConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "config"},
+				Spec:       metricsv1.XMetricConfigSpec{Resources: tc.resources()},
+			}
+			err := v.ValidateCreate(context.Background(), config)
+			var got []metav1.StatusCause
+			if err != nil {
+				status, ok := err.(*kerrors.StatusError)
+				if !ok {
+					t.Fatalf("ValidateCreate(...): %v", err)
+				}
+				got = status.ErrStatus.Details.Causes
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(metav1.StatusCause{}, "Message")); diff != "" {
+				t.Errorf("\n%s\nValidateCreate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
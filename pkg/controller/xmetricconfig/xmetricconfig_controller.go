@@ -0,0 +1,316 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xmetricconfig
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+const (
+	finalizerName = "metrics.crossplane.io/finalizer"
+	// statusInterval is the interval the status of the metric stores is
+	// refreshed in
+	statusInterval = time.Minute
+	// reasonConflict is the reason of the conditions of resources whose metric
+	// name is used by another XMetricConfig
+	reasonConflict = "Conflict"
+)
+
+// storeStates returns the state of registered metric stores. It is
+// implemented by xmetrics.ManagedMetricsHandler.
+type storeStates interface {
+	Store(name string) (xmetrics.StoreDebug, bool)
+}
+
+// XMetricConfigReconciler reconciles a XMetricConfig object
+type XMetricConfigReconciler struct {
+	client.Client
+	Scheme    *runtime.Scheme
+	MmHandler xmetrics.IManagedMetricsHandler
+
+	// registered holds the metric stores registered per XMetricConfig, keyed by metric name
+	registered map[string]map[string]*registration
+	// owners maps the metric names of registered stores to their XMetricConfig
+	owners map[string]string
+}
+
+type registration struct {
+	resource metricsv1.XMetricResource
+	// conflict is the XMetricConfig owning the metric name of a resource
+	// which is not registered because of it
+	conflict string
+}
+
+// +kubebuilder:rbac:groups=metrics.crossplane.io,resources=xmetricconfigs,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=metrics.crossplane.io,resources=xmetricconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metrics.crossplane.io,resources=xmetricconfigs/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+func (r *XMetricConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	config := &metricsv1.XMetricConfig{}
+	if err := r.Get(ctx, req.NamespacedName, config); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !config.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(config, finalizerName) {
+			r.removeAll(config.GetName())
+			controllerutil.RemoveFinalizer(config, finalizerName)
+			if err := r.Update(ctx, config); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(config, finalizerName) {
+		controllerutil.AddFinalizer(config, finalizerName)
+		if err := r.Update(ctx, config); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	current := r.registered[config.GetName()]
+	if current == nil {
+		current = map[string]*registration{}
+		r.registered[config.GetName()] = current
+	}
+
+	crds := &apiextensions.CustomResourceDefinitionList{}
+	if err := r.List(ctx, crds); err != nil {
+		return ctrl.Result{}, err
+	}
+	desired := map[string]metricsv1.XMetricResource{}
+	for _, spec := range config.Spec.Resources {
+		expanded, err := expandResource(spec, crds.Items)
+		if err != nil {
+			log.Error(err, "unable to register metric store", "metricName", spec.MetricName)
+			continue
+		}
+		for _, res := range expanded {
+			desired[res.MetricName] = res
+		}
+	}
+
+	// Remove stores which are not configured anymore or whose configuration
+	// changed, and conflicts to check them again
+	for name, reg := range current {
+		if res, ok := desired[name]; !ok || !reflect.DeepEqual(res, reg.resource) || reg.conflict != "" {
+			r.remove(current, name)
+		}
+	}
+
+	for name, res := range desired {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		// Stores are registered by metric name, so a store of another
+		// config is neither replaced nor stopped by this one
+		if owner, ok := r.owners[name]; ok && owner != config.GetName() {
+			log.Info("metric name is already used by another XMetricConfig", "metricName", name, "owner", owner)
+			current[name] = &registration{resource: res, conflict: owner}
+			continue
+		}
+		gvr := schema.GroupVersionResource{Group: res.Group, Version: res.Version, Resource: res.Resource}
+		var namespace string
+		if res.Namespace != nil {
+			namespace = *res.Namespace
+		}
+		log.Info("registering metric store", "metricName", name, "gvr", gvr.String())
+		// Failures of the store are emitted as events on the config
+		r.MmHandler.RegisterAndAddMetricStore(xmetrics.WithEventObject(ctx, config), name, gvr, namespace, resourceConfig(res))
+		current[name] = &registration{resource: res}
+		r.owners[name] = config.GetName()
+	}
+
+	states, _ := r.MmHandler.(storeStates)
+	resources := resourceStatus(config, current, states)
+	if !equality.Semantic.DeepEqual(resources, config.Status.Resources) {
+		config.Status.Resources = resources
+		if err := r.Status().Update(ctx, config); err != nil {
+			log.Error(err, "unable to update xmetricconfig status")
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: statusInterval}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *XMetricConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.registered = map[string]map[string]*registration{}
+	r.owners = map[string]string{}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&metricsv1.XMetricConfig{}).
+		// Wildcards and resources without version depend on the CRDs
+		Watches(&source.Kind{Type: &apiextensions.CustomResourceDefinition{}}, handler.EnqueueRequestsFromMapFunc(r.configsOfCRD)).
+		Complete(r)
+}
+
+// configsOfCRD returns the XMetricConfigs with a wildcard or a resource
+// without version in the group of the CRD.
+func (r *XMetricConfigReconciler) configsOfCRD(obj client.Object) []reconcile.Request {
+	crd, ok := obj.(*apiextensions.CustomResourceDefinition)
+	if !ok {
+		return nil
+	}
+	configs := &metricsv1.XMetricConfigList{}
+	if err := r.List(context.Background(), configs); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, c := range configs.Items {
+		for _, res := range c.Spec.Resources {
+			if res.Group == crd.Spec.Group && (res.Resource == wildcard || res.Version == "") {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: c.GetName()}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// remove stops the store of name unless it is registered by another config.
+func (r *XMetricConfigReconciler) remove(current map[string]*registration, name string) {
+	if current[name].conflict == "" {
+		r.MmHandler.Stop(name)
+		delete(r.owners, name)
+	}
+	delete(current, name)
+}
+
+func (r *XMetricConfigReconciler) removeAll(configName string) {
+	current := r.registered[configName]
+	for name := range current {
+		r.remove(current, name)
+	}
+	delete(r.registered, configName)
+}
+
+func resourceConfig(res metricsv1.XMetricResource) xmetrics.ResourceConfig {
+	c := xmetrics.ResourceConfig{
+		Group:    res.Group,
+		Version:  res.Version,
+		Resource: res.Resource,
+	}
+	for _, m := range res.InfoMappings {
+		c.InfoMappings = append(c.InfoMappings, xmetrics.InfoMappings{FieldPath: m.FieldPath, Label: m.Label})
+	}
+	return c
+}
+
+// resourceStatus returns the status of the metric stores of config,
+// including their conditions, objects and last sync if states is not nil.
+func resourceStatus(config *metricsv1.XMetricConfig, current map[string]*registration, states storeStates) []metricsv1.XMetricResourceStatus {
+	previous := map[string][]metav1.Condition{}
+	for _, st := range config.Status.Resources {
+		previous[st.MetricName] = st.Conditions
+	}
+	status := make([]metricsv1.XMetricResourceStatus, 0, len(current))
+	for name, reg := range current {
+		st := metricsv1.XMetricResourceStatus{
+			MetricName: name,
+			Group:      reg.resource.Group,
+			Version:    reg.resource.Version,
+			Resource:   reg.resource.Resource,
+			Namespace:  reg.resource.Namespace,
+			Conditions: append([]metav1.Condition(nil), previous[name]...),
+		}
+		if reg.conflict != "" {
+			message := "The metric name is already used by XMetricConfig " + reg.conflict
+			meta.SetStatusCondition(&st.Conditions, metav1.Condition{
+				Type:               metricsv1.ConditionRegistered,
+				Status:             metav1.ConditionFalse,
+				Reason:             reasonConflict,
+				Message:            message,
+				ObservedGeneration: config.Generation,
+			})
+			meta.SetStatusCondition(&st.Conditions, metav1.Condition{
+				Type:               metricsv1.ConditionFailed,
+				Status:             metav1.ConditionTrue,
+				Reason:             reasonConflict,
+				Message:            message,
+				ObservedGeneration: config.Generation,
+			})
+			status = append(status, st)
+			continue
+		}
+		meta.SetStatusCondition(&st.Conditions, metav1.Condition{
+			Type:               metricsv1.ConditionRegistered,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Registered",
+			Message:            "The metric store is registered",
+			ObservedGeneration: config.Generation,
+		})
+		if states != nil {
+			if state, ok := states.Store(name); ok {
+				st.Objects = state.Objects
+				if state.LastEvent != nil {
+					// The status is serialized in seconds
+					t := metav1.NewTime(state.LastEvent.Truncate(time.Second))
+					st.LastSyncTime = &t
+				}
+				meta.SetStatusCondition(&st.Conditions, failedCondition(state, config.Generation))
+			}
+		}
+		status = append(status, st)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].MetricName < status[j].MetricName
+	})
+	return status
+}
+
+// failedCondition returns the Failed condition of a metric store in state.
+func failedCondition(state xmetrics.StoreDebug, generation int64) metav1.Condition {
+	c := metav1.Condition{
+		Type:               metricsv1.ConditionFailed,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Synced",
+		Message:            "The objects of the resource are listed and watched",
+		ObservedGeneration: generation,
+	}
+	switch {
+	case state.Error != "":
+		c.Status, c.Reason, c.Message = metav1.ConditionTrue, xmetrics.ReasonListWatchFailed, state.Error
+	case !state.Synced:
+		c.Reason, c.Message = "Pending", "Waiting for the first list of the resource"
+	}
+	return c
+}
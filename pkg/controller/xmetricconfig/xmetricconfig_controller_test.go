@@ -0,0 +1,210 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xmetricconfig
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+type fakeStates map[string]xmetrics.StoreDebug
+
+func (s fakeStates) Store(name string) (xmetrics.StoreDebug, bool) {
+	d, ok := s[name]
+	return d, ok
+}
+
+func TestResourceStatus(t *testing.T) {
+	resource := metricsv1.XMetricResource{MetricName: "instances", Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	current := map[string]*registration{"instances": {resource: resource}}
+	lastSync := time.Date(2023, 5, 4, 10, 15, 0, 0, time.UTC)
+	registered := metav1.Condition{Type: metricsv1.ConditionRegistered, Status: metav1.ConditionTrue, Reason: "Registered", Message: "The metric store is registered", ObservedGeneration: 2}
+
+	cases := map[string]struct {
+		reason string
+		states storeStates
+		want   metricsv1.XMetricResourceStatus
+	}{
+		"NoStates": {
+			reason: "Should only report the registration without the state of the stores.",
+			want: metricsv1.XMetricResourceStatus{
+				MetricName: "instances", Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances",
+				Conditions: []metav1.Condition{registered},
+			},
+		},
+		"Synced": {
+			reason: "Should report the objects and last sync of a synced store.",
+			states: fakeStates{"instances": {Synced: true, Objects: 3, LastEvent: &lastSync}},
+			want: metricsv1.XMetricResourceStatus{
+				MetricName: "instances", Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances",
+				Objects: 3, LastSyncTime: &metav1.Time{Time: lastSync},
+				Conditions: []metav1.Condition{registered, {Type: metricsv1.ConditionFailed, Status: metav1.ConditionFalse, Reason: "Synced", Message: "The objects of the resource are listed and watched", ObservedGeneration: 2}},
+			},
+		},
+		"Pending": {
+			reason: "Should report stores before their first list as pending.",
+			states: fakeStates{"instances": {}},
+			want: metricsv1.XMetricResourceStatus{
+				MetricName: "instances", Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances",
+				Conditions: []metav1.Condition{registered, {Type: metricsv1.ConditionFailed, Status: metav1.ConditionFalse, Reason: "Pending", Message: "Waiting for the first list of the resource", ObservedGeneration: 2}},
+			},
+		},
+		"Failed": {
+			reason: "Should report the failure of a store.",
+			states: fakeStates{"instances": {Error: "instances.rds.aws.upbound.io is forbidden"}},
+			want: metricsv1.XMetricResourceStatus{
+				MetricName: "instances", Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances",
+				Conditions: []metav1.Condition{registered, {Type: metricsv1.ConditionFailed, Status: metav1.ConditionTrue, Reason: xmetrics.ReasonListWatchFailed, Message: "instances.rds.aws.upbound.io is forbidden", ObservedGeneration: 2}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := &metricsv1.XMetricConfig{ObjectMeta: metav1.ObjectMeta{Name: "config", Generation: 2}}
+			got := resourceStatus(config, current, tc.states)
+			if diff := cmp.Diff([]metricsv1.XMetricResourceStatus{tc.want}, got, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("\n%s\nresourceStatus(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResourceStatusTransition(t *testing.T) {
+	current := map[string]*registration{"instances": {resource: metricsv1.XMetricResource{MetricName: "instances"}}}
+	config := &metricsv1.XMetricConfig{}
+	config.Status.Resources = resourceStatus(config, current, fakeStates{"instances": {Synced: true}})
+	registeredAt := config.Status.Resources[0].Conditions[0].LastTransitionTime
+
+	time.Sleep(10 * time.Millisecond)
+	got := resourceStatus(config, current, fakeStates{"instances": {Synced: true, Objects: 1}})
+	if diff := cmp.Diff(registeredAt, got[0].Conditions[0].LastTransitionTime); diff != "" {
+		t.Errorf("resourceStatus(...): want the transition time of unchanged conditions kept, -want, +got:\n%s", diff)
+	}
+}
+
+// fakeHandler records the metric stores registered and stopped.
+type fakeHandler struct {
+	xmetrics.IManagedMetricsHandler
+	registered []string
+	stopped    []string
+}
+
+func (h *fakeHandler) RegisterAndAddMetricStore(_ context.Context, metricName string, _ schema.GroupVersionResource, _ string, _ xmetrics.ResourceConfig) chan struct{} {
+	h.registered = append(h.registered, metricName)
+	return nil
+}
+
+func (h *fakeHandler) Stop(name string) {
+	h.stopped = append(h.stopped, name)
+}
+
+func (h *fakeHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func TestReconcileConflict(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := apiextensions.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := metricsv1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	newConfig := func(name string) *metricsv1.XMetricConfig {
+		return &metricsv1.XMetricConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: metricsv1.XMetricConfigSpec{Resources: []metricsv1.XMetricResource{
+				{MetricName: "configmaps", Version: "v1", Resource: "configmaps"},
+			}},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(newConfig("a"), newConfig("b")).Build()
+	h := &fakeHandler{}
+	r := &XMetricConfigReconciler{Client: c, MmHandler: h, registered: map[string]map[string]*registration{}, owners: map[string]string{}}
+	reconcile := func(name string) *metricsv1.XMetricConfig {
+		t.Helper()
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+			t.Fatal(err)
+		}
+		config := &metricsv1.XMetricConfig{}
+		if err := c.Get(context.Background(), client.ObjectKey{Name: name}, config); client.IgnoreNotFound(err) != nil {
+			t.Fatal(err)
+		}
+		return config
+	}
+	remove := func(name string) {
+		t.Helper()
+		config := &metricsv1.XMetricConfig{}
+		if err := c.Get(context.Background(), client.ObjectKey{Name: name}, config); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Delete(context.Background(), config); err != nil {
+			t.Fatal(err)
+		}
+		reconcile(name)
+	}
+
+	reconcile("a")
+	b := reconcile("b")
+	if diff := cmp.Diff([]string{"configmaps"}, h.registered); diff != "" {
+		t.Errorf("Reconcile(...): want the metric name registered by the first config only, -want, +got:\n%s", diff)
+	}
+	want := []metav1.Condition{
+		{Type: metricsv1.ConditionRegistered, Status: metav1.ConditionFalse, Reason: reasonConflict, Message: "The metric name is already used by XMetricConfig a"},
+		{Type: metricsv1.ConditionFailed, Status: metav1.ConditionTrue, Reason: reasonConflict, Message: "The metric name is already used by XMetricConfig a"},
+	}
+	if diff := cmp.Diff(want, b.Status.Resources[0].Conditions, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+		t.Errorf("Reconcile(...): want a conflict of the second config, -want, +got:\n%s", diff)
+	}
+
+	remove("b")
+	if len(h.stopped) != 0 {
+		t.Errorf("Reconcile(...): want the store of another config kept, got stopped %v", h.stopped)
+	}
+
+	b = newConfig("b")
+	if err := c.Create(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	reconcile("b")
+	remove("a")
+	if diff := cmp.Diff([]string{"configmaps"}, h.stopped); diff != "" {
+		t.Errorf("Reconcile(...): want the store of the deleted owner stopped, -want, +got:\n%s", diff)
+	}
+	b = reconcile("b")
+	if diff := cmp.Diff([]string{"configmaps", "configmaps"}, h.registered); diff != "" {
+		t.Errorf("Reconcile(...): want the metric name registered by the second config once released, -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(metav1.ConditionTrue, b.Status.Resources[0].Conditions[0].Status); diff != "" {
+		t.Errorf("Reconcile(...): want the resource of the second config registered, -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package exporter is the API to embed x-metrics into other controllers.
+//
+// A Handler watches the resources of its registered stores and serves their
+// metric families in the Prometheus text format. Stores are registered by the
+// Registry interface, e.g. by a controller reconciling the CRDs to export.
+// Families in addition to the built-in families are added by FamilyGenerators:
+//
+//	h := exporter.New(dc, exporter.Config{}, exporter.WithGenerators(
+//		exporter.NewFamilyGenerator("_replicas", "Replicas of the {kind}", replicas),
+//	))
+//	h.RegisterAndAddMetricStoreForGVR(ctx, "database", gvr, "")
+//	http.Handle("/x-metrics", h)
+//
+// Controllers built with controller-runtime add the Handler to their manager
+// by a Runnable instead, which serves it on the metrics endpoint of the
+// manager and closes its stores on shutdown:
+//
+//	err := (&exporter.Runnable{Handler: h}).SetupWithManager(mgr)
+package exporter
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// Handler serves the metric families of the registered stores.
+type Handler = handler.ManagedMetricsHandler
+
+// Registry registers and removes the stores of resources. It is implemented
+// by Handler.
+type Registry = handler.IManagedMetricsHandler
+
+var _ Registry = &Handler{}
+
+// Config configures the stores of a Handler, see LoadConfig.
+type Config = handler.Config
+
+// ResourceConfig configures the store of a resource.
+type ResourceConfig = handler.ResourceConfig
+
+// ResourceOptions configure the families generated for a resource.
+type ResourceOptions = handler.ResourceOptions
+
+// InfoMappings maps a field path to a label of the _info family.
+type InfoMappings = handler.InfoMappings
+
+// GaugeMappings exports a numeric field path as gauge family.
+type GaugeMappings = handler.GaugeMappings
+
+// ExpressionMappings exports the result of a CEL expression as gauge family.
+type ExpressionMappings = handler.ExpressionMappings
+
+// ConditionValues map the status of conditions to the values of their families.
+type ConditionValues = handler.ConditionValues
+
+// RelabelConfig rewrites the labels of the generated series.
+type RelabelConfig = handler.RelabelConfig
+
+// StoreLimits cap the objects exported by a store.
+type StoreLimits = handler.StoreLimits
+
+// ResourceMapper resolves kinds and short names to resources, see
+// Handler.RegisterAndAddMetricStoreForKind.
+type ResourceMapper = handler.ResourceMapper
+
+// Option configures a Handler on top of its Config.
+type Option = handler.Option
+
+// FamilyGenerator generates a family in addition to the built-in families.
+type FamilyGenerator = handler.FamilyGenerator
+
+// Runnable embeds a Handler into a controller-runtime manager.
+type Runnable = handler.Runnable
+
+// Metric is a series generated by a FamilyGenerator.
+type Metric = metric.Metric
+
+// New returns a Handler of the resources of dc.
+func New(dc dynamic.Interface, config Config, opts ...Option) *Handler {
+	h := handler.NewManagedMetricsHandler(dc, config, opts...)
+	return &h
+}
+
+// NewResourceMapper returns a ResourceMapper discovering the resources by d.
+func NewResourceMapper(d discovery.DiscoveryInterface) *ResourceMapper {
+	return handler.NewResourceMapper(d)
+}
+
+// LoadConfig reads and validates the configuration file at path.
+func LoadConfig(path string) (Config, error) {
+	return handler.LoadConfig(path)
+}
+
+// Options of a Handler, see the functions of the same name of package handler.
+var (
+	WithNamespace          = handler.WithNamespace
+	WithLabelAllowlist     = handler.WithLabelAllowlist
+	WithInfoMappings       = handler.WithInfoMappings
+	WithDisabledFamilies   = handler.WithDisabledFamilies
+	WithMetricPrefix       = handler.WithMetricPrefix
+	WithLabels             = handler.WithLabels
+	WithConditionEncoding  = handler.WithConditionEncoding
+	WithResync             = handler.WithResync
+	WithDebugStores        = handler.WithDebugStores
+	WithGenerators         = handler.WithGenerators
+	WithResourceGenerators = handler.WithResourceGenerators
+)
+
+// NewFamilyGenerator returns a FamilyGenerator of the family with the given
+// suffix and HELP text, whose series are returned by generate.
+func NewFamilyGenerator(suffix, help string, generate func(obj *unstructured.Unstructured) []*Metric) FamilyGenerator {
+	return handler.NewFamilyGenerator(suffix, help, generate)
+}
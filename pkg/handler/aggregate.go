@@ -0,0 +1,228 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+// The aggregated families are computed on scrape from the objects of all
+// stores, sparing count() and similar queries over the object families of
+// large fleets.
+const (
+	resourcesFamily = "x_metrics_resources_total"
+	ageFamily       = "x_metrics_resource_age_seconds"
+)
+
+var (
+	resourcesHeader = header(resourcesFamily, "Number of objects of a kind by the status of their Ready and Synced conditions")
+	ageHeader       = "# TYPE " + ageFamily + " histogram\n# HELP " + ageFamily + " Age of the objects of a resource"
+)
+
+// ageBuckets are the upper bounds of the age histogram: 1h, 6h, 1d, 7d, 30d,
+// 90d, 180d and 365d.
+var ageBuckets = []float64{3600, 6 * 3600, 86400, 7 * 86400, 30 * 86400, 90 * 86400, 180 * 86400, 365 * 86400}
+
+type aggregateKey struct {
+	cluster string
+	group   string
+	kind    string
+	ready   corev1.ConditionStatus
+	synced  corev1.ConditionStatus
+}
+
+type ageKey struct {
+	cluster string
+	gvr     schema.GroupVersionResource
+}
+
+// ageHistogram counts the objects per bucket of ageBuckets, non-cumulative.
+type ageHistogram struct {
+	buckets []int
+	sum     float64
+	count   int
+}
+
+func (h *ageHistogram) observe(age float64) {
+	for i, b := range ageBuckets {
+		if age <= b {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.sum += age
+	h.count++
+}
+
+// aggregates are the aggregated families of the stores of a handler.
+type aggregates struct {
+	counts map[aggregateKey]int
+	ages   map[ageKey]*ageHistogram
+}
+
+// aggregateWriter writes the aggregated families of the stores of a handler,
+// with the age of objects relative to now. Nothing is written if the stores
+// hold no objects.
+type aggregateWriter struct {
+	m   *ManagedMetricsHandler
+	now time.Time
+}
+
+func newAggregateWriter(m *ManagedMetricsHandler) aggregateWriter {
+	return aggregateWriter{m: m, now: time.Now()}
+}
+
+func (a aggregateWriter) WriteAll(w io.Writer) {
+	agg := a.m.aggregate(a.now)
+	if len(agg.counts) > 0 {
+		writeCounts(w, agg.counts)
+	}
+	if len(agg.ages) > 0 {
+		writeAges(w, agg.ages)
+	}
+}
+
+func writeCounts(w io.Writer, counts map[aggregateKey]int) {
+	keys := make([]aggregateKey, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case a.cluster != b.cluster:
+			return a.cluster < b.cluster
+		case a.group != b.group:
+			return a.group < b.group
+		case a.kind != b.kind:
+			return a.kind < b.kind
+		case a.ready != b.ready:
+			return a.ready < b.ready
+		default:
+			return a.synced < b.synced
+		}
+	})
+
+	f := metric.Family{Name: resourcesFamily}
+	for _, k := range keys {
+		labelKeys := []string{"group", "kind", "ready", "synced"}
+		labelValues := []string{k.group, k.kind, string(k.ready), string(k.synced)}
+		if k.cluster != "" {
+			labelKeys = append(labelKeys, "cluster")
+			labelValues = append(labelValues, k.cluster)
+		}
+		f.Metrics = append(f.Metrics, &metric.Metric{LabelKeys: labelKeys, LabelValues: labelValues, Value: float64(counts[k])})
+	}
+	_, _ = io.WriteString(w, resourcesHeader+"\n")
+	_, _ = w.Write(f.ByteSlice())
+}
+
+func writeAges(w io.Writer, ages map[ageKey]*ageHistogram) {
+	keys := make([]ageKey, 0, len(ages))
+	for k := range ages {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.cluster != b.cluster {
+			return a.cluster < b.cluster
+		}
+		return a.gvr.String() < b.gvr.String()
+	})
+
+	_, _ = io.WriteString(w, ageHeader+"\n")
+	for _, k := range keys {
+		h := ages[k]
+		labelKeys := gvrLabels
+		labelValues := []string{k.gvr.Group, k.gvr.Version, k.gvr.Resource}
+		if k.cluster != "" {
+			labelKeys = appendLabels(labelKeys, "cluster")
+			labelValues = append(labelValues, k.cluster)
+		}
+		buckets := metric.Family{Name: ageFamily + "_bucket"}
+		var cumulative int
+		for i, b := range ageBuckets {
+			cumulative += h.buckets[i]
+			buckets.Metrics = append(buckets.Metrics, &metric.Metric{
+				LabelKeys:   appendLabels(labelKeys, "le"),
+				LabelValues: appendLabels(labelValues, strconv.FormatFloat(b, 'f', -1, 64)),
+				Value:       float64(cumulative),
+			})
+		}
+		buckets.Metrics = append(buckets.Metrics, &metric.Metric{
+			LabelKeys:   appendLabels(labelKeys, "le"),
+			LabelValues: appendLabels(labelValues, "+Inf"),
+			Value:       float64(h.count),
+		})
+		_, _ = w.Write(buckets.ByteSlice())
+		sum := metric.Family{Name: ageFamily + "_sum", Metrics: []*metric.Metric{{LabelKeys: labelKeys, LabelValues: labelValues, Value: h.sum}}}
+		_, _ = w.Write(sum.ByteSlice())
+		count := metric.Family{Name: ageFamily + "_count", Metrics: []*metric.Metric{{LabelKeys: labelKeys, LabelValues: labelValues, Value: float64(h.count)}}}
+		_, _ = w.Write(count.ByteSlice())
+	}
+}
+
+// aggregate aggregates the objects of all stores, except stores dropped as
+// stale. Objects held by several stores are counted once per kind and once per
+// resource. m.mu must not be held.
+func (m *ManagedMetricsHandler) aggregate(now time.Time) aggregates {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	type objectKey struct {
+		cluster string
+		uid     types.UID
+	}
+	seen := map[objectKey]struct{}{}
+	seenAge := map[ageKey]map[types.UID]struct{}{}
+	agg := aggregates{counts: map[aggregateKey]int{}, ages: map[ageKey]*ageHistogram{}}
+	for _, rs := range m.metricsWriter {
+		if m.config.Staleness.drops(rs.lastSync(), now) {
+			continue
+		}
+		for _, s := range rs.stores {
+			ak := ageKey{cluster: s.cluster, gvr: s.gvr}
+			s.statuses(func(uid types.UID, o objectStatus) {
+				k := objectKey{cluster: s.cluster, uid: uid}
+				if _, ok := seen[k]; !ok {
+					seen[k] = struct{}{}
+					agg.counts[aggregateKey{cluster: s.cluster, group: s.gvr.Group, kind: o.kind, ready: o.ready, synced: o.synced}]++
+				}
+				if o.created.IsZero() {
+					return
+				}
+				if seenAge[ak] == nil {
+					seenAge[ak] = map[types.UID]struct{}{}
+					agg.ages[ak] = &ageHistogram{buckets: make([]int, len(ageBuckets))}
+				}
+				if _, ok := seenAge[ak][uid]; ok {
+					return
+				}
+				seenAge[ak][uid] = struct{}{}
+				agg.ages[ak].observe(now.Sub(o.created).Seconds())
+			})
+		}
+	}
+	return agg
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestAggregate(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	newMR := func(name, ready, synced string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"status": map[string]any{"conditions": []any{
+				map[string]any{"type": "Ready", "status": ready},
+				map[string]any{"type": "Synced", "status": synced},
+			}},
+		}}
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		return u
+	}
+
+	cases := map[string]struct {
+		reason    string
+		objects   map[string][]any
+		cluster   string
+		staleness Staleness
+		// stale stores were synced an hour ago
+		stale map[string]bool
+		want  []string
+	}{
+		"Counts": {
+			reason: "Should count the objects by kind and condition status, counting objects held by several stores once.",
+			objects: map[string][]any{
+				"a": {newMR("a", "True", "True"), newMR("b", "True", "True"), newMR("c", "False", "True")},
+				"b": {newMR("a", "True", "True")},
+			},
+			want: []string{
+				"# TYPE x_metrics_resources_total gauge",
+				`x_metrics_resources_total{group="rds.aws.upbound.io",kind="Instance",ready="False",synced="True"} 1`,
+				`x_metrics_resources_total{group="rds.aws.upbound.io",kind="Instance",ready="True",synced="True"} 2`,
+			},
+		},
+		"Cluster": {
+			reason:  "Should label the counts of named clusters with the cluster.",
+			objects: map[string][]any{"a": {newMR("a", "True", "Unknown")}},
+			cluster: "spoke",
+			want: []string{
+				"# TYPE x_metrics_resources_total gauge",
+				`x_metrics_resources_total{group="rds.aws.upbound.io",kind="Instance",ready="True",synced="Unknown",cluster="spoke"} 1`,
+			},
+		},
+		"Stale": {
+			reason: "Should not count the objects of stores dropped as stale.",
+			objects: map[string][]any{
+				"a": {newMR("a", "True", "True")},
+				"b": {newMR("b", "False", "True")},
+			},
+			staleness: Staleness{After: metav1.Duration{Duration: 15 * time.Minute}},
+			stale:     map[string]bool{"b": true},
+			want: []string{
+				"# TYPE x_metrics_resources_total gauge",
+				`x_metrics_resources_total{group="rds.aws.upbound.io",kind="Instance",ready="True",synced="True"} 1`,
+			},
+		},
+		"StaleLabeled": {
+			reason: "Should count the objects of stale stores which are served with the stale label.",
+			objects: map[string][]any{
+				"a": {newMR("a", "True", "True")},
+				"b": {newMR("b", "False", "True")},
+			},
+			staleness: Staleness{After: metav1.Duration{Duration: 15 * time.Minute}, Action: StaleLabel},
+			stale:     map[string]bool{"b": true},
+			want: []string{
+				"# TYPE x_metrics_resources_total gauge",
+				`x_metrics_resources_total{group="rds.aws.upbound.io",kind="Instance",ready="False",synced="True"} 1`,
+				`x_metrics_resources_total{group="rds.aws.upbound.io",kind="Instance",ready="True",synced="True"} 1`,
+			},
+		},
+		"Empty": {
+			reason: "Should not write the family without objects.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{Staleness: tc.staleness})
+			for store, objs := range tc.objects {
+				s := newInstrumentedStore(newMetricsStore(store, "", "", ResourceConfig{}, nil, nil), gvr)
+				s.cluster = tc.cluster
+				_ = s.Replace(objs, "1")
+				if tc.stale[store] {
+					s.syncTime = time.Now().Add(-time.Hour)
+				}
+				m.addMetricStore(store, func() {}, s)
+			}
+
+			buf := &bytes.Buffer{}
+			newAggregateWriter(&m).WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if line != "" && !strings.HasPrefix(line, "# HELP") {
+					got = append(got, line)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nWriteAll(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestAgeHistogram(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	newMR := func(name string, age time.Duration) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance"}}
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		u.SetCreationTimestamp(metav1.NewTime(now.Add(-age)))
+		return u
+	}
+
+	m := NewManagedMetricsHandler(nil, Config{})
+	s := newInstrumentedStore(newMetricsStore("test", "", "", ResourceConfig{}, nil, nil), gvr)
+	_ = s.Replace([]any{newMR("new", 30*time.Minute), newMR("day", 20*time.Hour), newMR("old", 400*24*time.Hour)}, "1")
+	m.addMetricStore("test", func() {}, s)
+
+	buf := &bytes.Buffer{}
+	aggregateWriter{m: &m, now: now}.WriteAll(buf)
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "x_metrics_resource_age_seconds") || strings.HasPrefix(line, "# TYPE x_metrics_resource_age_seconds") {
+			got = append(got, line)
+		}
+	}
+	labels := `group="rds.aws.upbound.io",version="v1beta1",resource="instances"`
+	want := []string{
+		"# TYPE x_metrics_resource_age_seconds histogram",
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="3600"} 1`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="21600"} 1`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="86400"} 2`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="604800"} 2`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="2592000"} 2`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="7776000"} 2`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="15552000"} 2`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="31536000"} 2`,
+		`x_metrics_resource_age_seconds_bucket{` + labels + `,le="+Inf"} 3`,
+		`x_metrics_resource_age_seconds_sum{` + labels + `} 3.46338e+07`,
+		`x_metrics_resource_age_seconds_count{` + labels + `} 3`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WriteAll(...): -want, +got:\n%s", diff)
+	}
+}
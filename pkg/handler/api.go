@@ -0,0 +1,173 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ResourceSummary is the state of the objects of a resource.
+type ResourceSummary struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+	Total    int    `json:"total"`
+	Ready    int    `json:"ready"`
+	Synced   int    `json:"synced"`
+	// NotReady are the objects without a Ready condition with status True
+	NotReady []ObjectSummary `json:"notReady"`
+}
+
+// ObjectSummary is the state of a single object.
+type ObjectSummary struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Ready     string `json:"ready"`
+	Synced    string `json:"synced"`
+	// Reason and Message of the Ready condition
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// objectStatus is the part of an object kept by the stores for the summary
+// and the composed families.
+type objectStatus struct {
+	name      string
+	namespace string
+	kind      string
+	ready     corev1.ConditionStatus
+	synced    corev1.ConditionStatus
+	reason    string
+	message   string
+	created   time.Time
+	// composite is true for composite resources, refs are their composed
+	// resources
+	composite bool
+	refs      []objectRef
+}
+
+func newObjectStatus(u *unstructured.Unstructured) objectStatus {
+	o := objectStatus{name: u.GetName(), namespace: u.GetNamespace(), kind: u.GetKind(), created: u.GetCreationTimestamp().Time, ready: corev1.ConditionUnknown, synced: corev1.ConditionUnknown}
+	o.refs, o.composite = resourceRefs(u)
+	for _, c := range getCrossplaneStatus(u).conditions {
+		switch c.Type {
+		case xpv1.TypeReady:
+			o.ready, o.reason, o.message = c.Status, string(c.Reason), c.Message
+		case xpv1.TypeSynced:
+			o.synced = c.Status
+		}
+	}
+	return o
+}
+
+// Summary returns the state of the objects of each watched resource, sorted
+// by group, resource and version.
+func (m *ManagedMetricsHandler) Summary() []ResourceSummary {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type key struct {
+		cluster string
+		uid     types.UID
+	}
+	summaries := map[schema.GroupVersionResource]*ResourceSummary{}
+	// The same object can be held by several stores, e.g. by stores
+	// registered by a metric and by the discovery of CRDs.
+	seen := map[schema.GroupVersionResource]map[key]struct{}{}
+	for _, rs := range m.metricsWriter {
+		for _, s := range rs.stores {
+			sum, ok := summaries[s.gvr]
+			if !ok {
+				sum = &ResourceSummary{Group: s.gvr.Group, Version: s.gvr.Version, Resource: s.gvr.Resource, NotReady: []ObjectSummary{}}
+				summaries[s.gvr] = sum
+				seen[s.gvr] = map[key]struct{}{}
+			}
+			s.statuses(func(uid types.UID, o objectStatus) {
+				k := key{cluster: s.cluster, uid: uid}
+				if _, ok := seen[s.gvr][k]; ok {
+					return
+				}
+				seen[s.gvr][k] = struct{}{}
+				sum.add(s.cluster, o)
+			})
+		}
+	}
+
+	out := make([]ResourceSummary, 0, len(summaries))
+	for _, sum := range summaries {
+		sort.Slice(sum.NotReady, func(i, j int) bool {
+			a, b := sum.NotReady[i], sum.NotReady[j]
+			if a.Cluster != b.Cluster {
+				return a.Cluster < b.Cluster
+			}
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			return a.Name < b.Name
+		})
+		out = append(out, *sum)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Version < b.Version
+	})
+	return out
+}
+
+func (s *ResourceSummary) add(cluster string, o objectStatus) {
+	s.Total++
+	if o.synced == corev1.ConditionTrue {
+		s.Synced++
+	}
+	if o.ready == corev1.ConditionTrue {
+		s.Ready++
+		return
+	}
+	s.NotReady = append(s.NotReady, ObjectSummary{
+		Cluster:   cluster,
+		Namespace: o.namespace,
+		Name:      o.name,
+		Ready:     string(o.ready),
+		Synced:    string(o.synced),
+		Reason:    o.reason,
+		Message:   o.message,
+	})
+}
+
+// ResourcesHandler serves the Summary as JSON.
+func (m *ManagedMetricsHandler) ResourcesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(m.Summary())
+	})
+}
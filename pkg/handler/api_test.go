@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSummary(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "test.x-metrics.io", Version: "v1", Resource: "tests"}
+	newWithConditions := func(name string, conditions ...any) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{"status": map[string]any{"conditions": conditions}}}
+		u.SetName(name)
+		u.SetNamespace("default")
+		u.SetUID(types.UID(name))
+		return u
+	}
+	ready := newWithConditions("ready",
+		map[string]any{"type": "Ready", "status": "True"},
+		map[string]any{"type": "Synced", "status": "True"})
+	failing := newWithConditions("failing",
+		map[string]any{"type": "Ready", "status": "False", "reason": "Unavailable", "message": "instance is down"},
+		map[string]any{"type": "Synced", "status": "True"})
+	pending := newWithConditions("pending")
+
+	cases := map[string]struct {
+		reason string
+		stores map[string][]any
+		want   []ResourceSummary
+	}{
+		"Counts": {
+			reason: "Should count ready and synced objects and list the not ready objects with their reason.",
+			stores: map[string][]any{"a": {ready, failing, pending}},
+			want: []ResourceSummary{{
+				Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource,
+				Total: 3, Ready: 1, Synced: 2,
+				NotReady: []ObjectSummary{
+					{Namespace: "default", Name: "failing", Ready: "False", Synced: "True", Reason: "Unavailable", Message: "instance is down"},
+					{Namespace: "default", Name: "pending", Ready: "Unknown", Synced: "Unknown"},
+				},
+			}},
+		},
+		"Deduplicated": {
+			reason: "Should count objects held by several stores once.",
+			stores: map[string][]any{"a": {ready}, "b": {ready}},
+			want: []ResourceSummary{{
+				Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource,
+				Total: 1, Ready: 1, Synced: 1,
+				NotReady: []ObjectSummary{},
+			}},
+		},
+		"Empty": {
+			reason: "Should return an empty summary without stores.",
+			want:   []ResourceSummary{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{})
+			for n, objs := range tc.stores {
+				s := newInstrumentedStore(newMetricsStore(n, "", "", ResourceConfig{}, nil, nil), gvr)
+				_ = s.Replace(objs, "1")
+				m.addMetricStore(n, func() {}, s)
+			}
+			if diff := cmp.Diff(tc.want, m.Summary()); diff != "" {
+				t.Errorf("\n%s\nSummary(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	"k8s.io/utils/pointer"
+)
+
+// object is an object added to a store together with the state derived from
+// it once for all of its families.
+type object struct {
+	*unstructured.Unstructured
+	paved             *fieldpath.Paved
+	status            crossplaneStatus
+	readyTransitions  int
+	syncedTransitions int
+	// firstReady is the time of the first observed transition to Ready=True,
+	// zero if the object wasn't observed ready yet
+	firstReady time.Time
+	// keys interns the label keys of the store, nil outside of stores
+	keys *keyCache
+}
+
+// newStoreObject returns obj with its derived state, counting the changes of
+// its conditions in t.
+func newStoreObject(obj *unstructured.Unstructured, t *transitions) *object {
+	o := &object{Unstructured: obj, paved: fieldpath.Pave(obj.Object), status: getCrossplaneStatus(obj)}
+	state := t.observe(obj.GetUID(), o.status.ready, o.status.synced, o.status.readyTime)
+	o.readyTransitions, o.syncedTransitions, o.firstReady = state.readyCount, state.syncedCount, state.firstReady
+	return o
+}
+
+// condition returns the condition of type typ, false if the object lacks it.
+func (o *object) condition(typ xpv1.ConditionType) (xpv1.Condition, bool) {
+	for _, c := range o.status.conditions {
+		if c.Type == typ {
+			return c, true
+		}
+	}
+	return xpv1.Condition{}, false
+}
+
+// builtin is a built-in family together with its generator. Like the series
+// of a FamilyGenerator, the generated series lack the labels identifying the
+// object.
+type builtin struct {
+	family
+	generate func(o *object, rc *ResourceConfig) []*metric.Metric
+}
+
+// series returns a single series of the given value, with the given labels.
+func series(value float64, keysAndValues ...string) []*metric.Metric {
+	m := &metric.Metric{Value: value}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		m.LabelKeys = append(m.LabelKeys, keysAndValues[i])
+		m.LabelValues = append(m.LabelValues, keysAndValues[i+1])
+	}
+	return []*metric.Metric{m}
+}
+
+// conditionFamilies are the built-in families of conditions, whose help
+// describes the configured condition values instead of the defaults.
+var conditionFamilies = map[string]xpv1.ConditionType{
+	"_ready":  xpv1.TypeReady,
+	"_synced": xpv1.TypeSynced,
+}
+
+// builtins lists the built-in families of each store in the order of the
+// generated families: the core families of all objects, followed by their
+// labels, info and conditions and the families specific to crossplane.
+var builtins = []builtin{
+	{family{"", "A metrics series for each object"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		return series(1)
+	}},
+	{family{"_created", "Unix creation timestamp"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		return series(float64(o.GetCreationTimestamp().Unix()))
+	}},
+	{family{"_labels", "Labels from the kubernetes object"}, generateLabels},
+	{family{"_annotations", "Allowlisted annotations from the kubernetes object"}, generateAnnotations},
+	{family{"_info", "A metrics series exposing parameters as labels"}, generateInfo},
+	{family{"_ready", "A metrics series mapping the Ready status condition to a value (True=1,False=0,other=-1)"}, func(o *object, rc *ResourceConfig) []*metric.Metric {
+		return conditionSeries(o, rc, xpv1.TypeReady)
+	}},
+	{family{"_ready_time", "Unix timestamp of last ready change"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		return series(float64(o.status.readyTime.Unix()))
+	}},
+	{family{"_synced", "A metrics series mapping the Synced status condition to a value (True=1,False=0,other=-1)"}, func(o *object, rc *ResourceConfig) []*metric.Metric {
+		return conditionSeries(o, rc, xpv1.TypeSynced)
+	}},
+	{family{"_synced_time", "Unix timestamp of last synced change"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		return series(float64(o.status.syncedTime.Unix()))
+	}},
+	{family{"_condition", "A metrics series for each status condition of the object with its type and status as labels"}, generateConditions},
+	{family{"_status_reason", "A metrics series for each status condition of the object with its type and reason as labels"}, generateStatusReasons},
+	{family{"_paused", "Whether reconciliation of the object is paused by the crossplane.io/paused annotation (paused=1,otherwise=0)"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		if meta.IsPaused(o) {
+			return series(1)
+		}
+		return series(0)
+	}},
+	{family{"_generation", "The generation of the desired state of the object (metadata.generation)"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		return series(float64(o.GetGeneration()))
+	}},
+	{family{"_observed_generation", "The generation last reconciled by the controller (status.observedGeneration)"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		// not every controller reports the observed generation, omit the series instead of reporting a drift
+		if observed, err := o.paved.GetInteger("status.observedGeneration"); err == nil {
+			return series(float64(observed))
+		}
+		return nil
+	}},
+	{family{"_management_policy", "A metrics series for each management policy of a managed resource (enabled=1,disabled=0)"}, generateManagementPolicies},
+	{family{"_spec_drift", "Number of fields of spec.forProvider differing from status.atProvider of a managed resource (in sync=0)"}, func(o *object, rc *ResourceConfig) []*metric.Metric {
+		n, ok := driftedFields(o, rc.DriftFields)
+		if !ok {
+			return nil
+		}
+		return series(float64(n))
+	}},
+	{family{"_bound", "Whether the claim is bound to a composite resource, with the composite resource as label (bound=1,unbound=0)"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		composite, ok := getClaimBinding(o.paved, o.GetNamespace())
+		if !ok {
+			return nil
+		}
+		if composite != "" {
+			return series(1, "composite", composite)
+		}
+		return series(0, "composite", composite)
+	}},
+	{family{"_time_to_ready_seconds", "Seconds from the creation of the object to the first observed transition of its Ready condition to True"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		// objects observed ready keep their time to ready when the Ready
+		// condition flaps later
+		if o.firstReady.IsZero() {
+			return nil
+		}
+		return series(o.firstReady.Sub(o.GetCreationTimestamp().Time).Seconds())
+	}},
+	{family{"_ready_transitions_total", "Number of changes of the Ready condition of the object observed since the store was registered"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		return series(float64(o.readyTransitions))
+	}},
+	{family{"_synced_transitions_total", "Number of changes of the Synced condition of the object observed since the store was registered"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		return series(float64(o.syncedTransitions))
+	}},
+	{family{"_connection_secret", "The connection secret the object writes to (spec.writeConnectionSecretToRef)"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		secretNamespace, secretName, ok := getConnectionSecret(o.paved, o.GetNamespace())
+		if !ok {
+			return nil
+		}
+		return series(1, "secret_namespace", secretNamespace, "secret_name", secretName)
+	}},
+	{family{"_connection_details_published", "Whether the connection details of the composite resource or claim were published (published=1,otherwise=0)"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		if _, _, ok := getConnectionSecret(o.paved, o.GetNamespace()); !ok {
+			return nil
+		}
+		// managed resources don't report whether they published their
+		// connection details
+		if _, err := o.paved.GetValue("spec.forProvider"); err == nil {
+			return nil
+		}
+		if _, err := o.paved.GetString("status.connectionDetails.lastPublishedTime"); err == nil {
+			return series(1)
+		}
+		return series(0)
+	}},
+	{family{"_finalizers", "A metrics series for each finalizer of the object with the finalizer as label"}, func(o *object, _ *ResourceConfig) []*metric.Metric {
+		var ms []*metric.Metric
+		for _, f := range o.GetFinalizers() {
+			ms = append(ms, series(1, "finalizer", f)...)
+		}
+		return ms
+	}},
+}
+
+func generateLabels(o *object, rc *ResourceConfig) []*metric.Metric {
+	m := &metric.Metric{Value: 1}
+	labels := o.GetLabels()
+	for _, k := range sortedKeys(labels) {
+		if !allowed(k, rc.LabelsAllowlist, rc.LabelsDenylist) {
+			continue
+		}
+		m.LabelKeys = append(m.LabelKeys, o.keys.name("label_", k))
+		m.LabelValues = append(m.LabelValues, labels[k])
+	}
+	return []*metric.Metric{m}
+}
+
+func generateAnnotations(o *object, rc *ResourceConfig) []*metric.Metric {
+	if len(rc.AnnotationsAllowlist) == 0 {
+		return nil
+	}
+	keys, values := allowedAnnotations(o.GetAnnotations(), rc.AnnotationsAllowlist, o.keys)
+	return []*metric.Metric{{LabelKeys: keys, LabelValues: values, Value: 1}}
+}
+
+func generateInfo(o *object, rc *ResourceConfig) []*metric.Metric {
+	m := &metric.Metric{Value: 1}
+	if externalName := meta.GetExternalName(o); externalName != "" {
+		m.LabelKeys = append(m.LabelKeys, "external_name")
+		m.LabelValues = append(m.LabelValues, externalName)
+	}
+	for _, r := range referenceLabels {
+		if v := getFieldValue(o.paved, r.FieldPath); v != "" {
+			m.LabelKeys = append(m.LabelKeys, r.Label)
+			m.LabelValues = append(m.LabelValues, v)
+		}
+	}
+	if owner := metav1.GetControllerOfNoCopy(o); owner != nil {
+		m.LabelKeys = append(m.LabelKeys, "owner_kind", "owner_name")
+		m.LabelValues = append(m.LabelValues, owner.Kind, owner.Name)
+	}
+	for _, i := range rc.InfoMappings {
+		value := redactedValue
+		if !rc.sensitive(i.FieldPath) {
+			value = getFieldValue(o.paved, i.FieldPath)
+		} else if rc.Redaction == RedactionDrop {
+			continue
+		}
+		m.LabelKeys = append(m.LabelKeys, o.keys.name("", i.Label))
+		m.LabelValues = append(m.LabelValues, value)
+	}
+	return []*metric.Metric{m}
+}
+
+// conditionSeries returns the series of the _ready and _synced families,
+// labeled with the object UID if enabled and not part of the object labels.
+func conditionSeries(o *object, rc *ResourceConfig, typ xpv1.ConditionType) []*metric.Metric {
+	var labels []string
+	if pointer.BoolDeref(rc.ConditionUID, false) && !pointer.BoolDeref(rc.UIDLabel, false) {
+		labels = []string{"uid", string(o.GetUID())}
+	}
+	if rc.ConditionEncoding == ConditionEncodingStateSet {
+		c, ok := o.condition(typ)
+		if !ok && rc.ConditionValues != nil && rc.ConditionValues.OmitMissing {
+			return nil
+		}
+		return stateSet(c, labels...)
+	}
+	value, ok := rc.ConditionValues.value(o, typ)
+	if !ok {
+		return nil
+	}
+	return series(value, labels...)
+}
+
+// stateSet returns a series for each of the statuses True, False and Unknown,
+// with the value 1 for the status of c and 0 for the others. Other statuses
+// and missing conditions are Unknown.
+func stateSet(c xpv1.Condition, keysAndValues ...string) []*metric.Metric {
+	current := "Unknown"
+	if c.Status == "True" || c.Status == "False" {
+		current = string(c.Status)
+	}
+	ms := make([]*metric.Metric, 0, 3)
+	for _, status := range []string{"True", "False", "Unknown"} {
+		var value float64
+		if status == current {
+			value = 1
+		}
+		ms = append(ms, series(value, appendLabels(keysAndValues, "status", status)...)...)
+	}
+	return ms
+}
+
+func generateConditions(o *object, _ *ResourceConfig) []*metric.Metric {
+	var ms []*metric.Metric
+	for _, c := range o.status.conditions {
+		ms = append(ms, series(1, "type", string(c.Type), "status", string(c.Status))...)
+	}
+	return ms
+}
+
+func generateStatusReasons(o *object, rc *ResourceConfig) []*metric.Metric {
+	var ms []*metric.Metric
+	for _, c := range o.status.conditions {
+		m := series(1, "type", string(c.Type), "reason", string(c.Reason))[0]
+		if pointer.BoolDeref(rc.ConditionMessages, false) {
+			m.LabelKeys = append(m.LabelKeys, "message")
+			m.LabelValues = append(m.LabelValues, c.Message)
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+func generateManagementPolicies(o *object, _ *ResourceConfig) []*metric.Metric {
+	policies, ok := getManagementPolicies(o.paved)
+	if !ok {
+		return nil
+	}
+	ms := make([]*metric.Metric, 0, len(managementPolicies))
+	for _, p := range managementPolicies {
+		var enabled float64
+		if policies[p] || policies["*"] {
+			enabled = 1
+		}
+		ms = append(ms, series(enabled, "policy", p)...)
+	}
+	return ms
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClusterConfig configures a remote cluster whose objects are exported.
+type ClusterConfig struct {
+	// Name is the value of the cluster label of the objects of the cluster
+	Name string `json:"name"`
+	// Kubeconfig is the path of the kubeconfig file of the cluster
+	Kubeconfig string `json:"kubeconfig"`
+	// Context of the kubeconfig file. Defaults to its current context
+	Context string `json:"context,omitempty"`
+}
+
+// Cluster holds the clients of a remote cluster.
+type Cluster struct {
+	Name           string
+	Client         dynamic.Interface
+	MetadataClient metadata.Interface
+}
+
+// NewCluster returns the clients of the cluster configured by c.
+func NewCluster(c ClusterConfig) (Cluster, error) {
+	conf, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.Kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: c.Context},
+	).ClientConfig()
+	if err != nil {
+		return Cluster{}, fmt.Errorf("cannot load kubeconfig of cluster %s: %w", c.Name, err)
+	}
+	dc, err := dynamic.NewForConfig(conf)
+	if err != nil {
+		return Cluster{}, err
+	}
+	mc, err := metadata.NewForConfig(conf)
+	if err != nil {
+		return Cluster{}, err
+	}
+	return Cluster{Name: c.Name, Client: dc, MetadataClient: mc}, nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"io"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+// composedFamilies count the composed resources of composite resources. They
+// depend on the state of the composed resources held by other stores, so they
+// are generated on scrape instead of by the metric stores.
+var composedFamilies = []family{
+	{"_composed_resources", "Number of resources composed by the composite resource (spec.resourceRefs)"},
+	{"_composed_ready", "Number of resources composed by the composite resource which are ready"},
+}
+
+// composition holds the composed families of the stores of a registration.
+type composition struct {
+	family  string
+	labels  objectLabels
+	relabel relabeling
+	// headers of composedFamilies, empty for disabled families
+	headers []string
+}
+
+func newComposition(family string, labels objectLabels, resourceConfig ResourceConfig) *composition {
+	disabled := map[string]bool{}
+	for _, f := range resourceConfig.DisabledFamilies {
+		disabled[f] = true
+	}
+	c := &composition{family: family, labels: labels, relabel: newRelabeling(resourceConfig.RelabelConfigs), headers: make([]string, len(composedFamilies))}
+	for i, f := range composedFamilies {
+		if disabled[f.key()] {
+			continue
+		}
+		help := f.help
+		if h, ok := resourceConfig.Help[f.key()]; ok {
+			help = h
+		}
+		c.headers[i] = header(family+f.suffix, resourceConfig.expandHelp(help))
+	}
+	return c
+}
+
+// objectRef references a composed resource. The version is omitted, as the
+// composed resource may be watched in another version.
+type objectRef struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// resourceRefs returns the composed resources of a composite resource, and
+// false if the object is no composite resource.
+func resourceRefs(u *unstructured.Unstructured) ([]objectRef, bool) {
+	v, err := fieldpath.Pave(u.Object).GetValue("spec.resourceRefs")
+	if err != nil {
+		return nil, false
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	refs := make([]objectRef, 0, len(list))
+	for _, item := range list {
+		r, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		apiVersion, _ := r["apiVersion"].(string)
+		gv, _ := schema.ParseGroupVersion(apiVersion)
+		ref := objectRef{group: gv.Group}
+		ref.kind, _ = r["kind"].(string)
+		ref.namespace, _ = r["namespace"].(string)
+		ref.name, _ = r["name"].(string)
+		refs = append(refs, ref)
+	}
+	return refs, true
+}
+
+type readyKey struct {
+	cluster string
+	objectRef
+}
+
+// readyObjects returns the objects of all stores which are ready. m.mu must
+// not be held.
+func (m *ManagedMetricsHandler) readyObjects() map[readyKey]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ready := map[readyKey]bool{}
+	for _, rs := range m.metricsWriter {
+		for _, s := range rs.stores {
+			s.statuses(func(_ types.UID, o objectStatus) {
+				if o.ready == corev1.ConditionTrue {
+					ready[readyKey{cluster: s.cluster, objectRef: objectRef{group: s.gvr.Group, kind: o.kind, namespace: o.namespace, name: o.name}}] = true
+				}
+			})
+		}
+	}
+	return ready
+}
+
+// composedWriter writes the composed families of the stores of a
+// registration. Nothing is written if the stores hold no composite resources.
+type composedWriter struct {
+	stores []*instrumentedStore
+	// ready returns the ready objects of all stores, built once per scrape
+	ready func() map[readyKey]bool
+}
+
+func (c composedWriter) WriteAll(w io.Writer) {
+	type composite struct {
+		store *instrumentedStore
+		uid   types.UID
+		objectStatus
+	}
+	var composites []composite
+	for _, s := range c.stores {
+		if s.composition == nil {
+			continue
+		}
+		s.statuses(func(uid types.UID, o objectStatus) {
+			if o.composite {
+				composites = append(composites, composite{store: s, uid: uid, objectStatus: o})
+			}
+		})
+	}
+	if len(composites) == 0 {
+		return
+	}
+
+	ready := c.ready()
+	comp := composites[0].store.composition
+	families := make([]metric.Family, len(composedFamilies))
+	for i, f := range composedFamilies {
+		families[i].Name = comp.family + f.suffix
+	}
+	for _, o := range composites {
+		var n int
+		for _, ref := range o.refs {
+			if ready[readyKey{cluster: o.store.cluster, objectRef: ref}] {
+				n++
+			}
+		}
+		labels := o.store.composition.labels
+		keys, values := labels.keys(), labels.values(o.name, o.namespace, o.uid)
+		for i, v := range []float64{float64(len(o.refs)), float64(n)} {
+			if m := o.store.composition.relabel.series(families[i].Name, keys, values, v); m != nil {
+				families[i].Metrics = append(families[i].Metrics, m)
+			}
+		}
+	}
+	for i, f := range families {
+		if comp.headers[i] == "" {
+			continue
+		}
+		_, _ = io.WriteString(w, comp.headers[i]+"\n")
+		_, _ = w.Write(f.ByteSlice())
+	}
+}
+
+// storeWriter returns a writer of the metric, composed and event families of
+// s.
+func storeWriter(s *registeredStore, ready func() map[readyKey]bool) metricsstore.MetricsWriter {
+	return multiWriter{s.writer, composedWriter{stores: s.stores, ready: ready}, eventWriter{stores: s.stores}}
+}
+
+// multiWriter writes the families of several writers.
+type multiWriter []metricsstore.MetricsWriter
+
+func (m multiWriter) WriteAll(w io.Writer) {
+	for _, mw := range m {
+		mw.WriteAll(w)
+	}
+}
+
+// lazyReadyObjects returns a function building the ready objects of m on its
+// first call.
+func (m *ManagedMetricsHandler) lazyReadyObjects() func() map[readyKey]bool {
+	var once sync.Once
+	var ready map[readyKey]bool
+	return func() map[readyKey]bool {
+		once.Do(func() { ready = m.readyObjects() })
+		return ready
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+func TestComposedFamilies(t *testing.T) {
+	newMR := func(name, ready string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"status":     map[string]any{"conditions": []any{map[string]any{"type": "Ready", "status": ready}}},
+		}}
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		return u
+	}
+	ref := func(name string) map[string]any {
+		// composed resources are matched regardless of their version
+		return map[string]any{"apiVersion": "rds.aws.upbound.io/v1beta2", "kind": "Instance", "name": name}
+	}
+	newXR := func(refs ...any) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{"resourceRefs": refs}}}
+		u.SetName("xr")
+		u.SetUID("xr")
+		return u
+	}
+
+	cases := map[string]struct {
+		reason   string
+		xr       *unstructured.Unstructured
+		disabled []string
+		relabel  []RelabelConfig
+		want     []string
+	}{
+		"Composite": {
+			reason: "Should count the composed resources and those which are ready.",
+			xr:     newXR(ref("ready"), ref("unready"), ref("missing")),
+			want: []string{
+				"# TYPE xr_composed_resources gauge",
+				`xr_composed_resources{name="xr"} 3`,
+				"# TYPE xr_composed_ready gauge",
+				`xr_composed_ready{name="xr"} 1`,
+			},
+		},
+		"Disabled": {
+			reason:   "Should not write disabled composed families.",
+			xr:       newXR(ref("ready")),
+			disabled: []string{"composed_resources"},
+			want: []string{
+				"# TYPE xr_composed_ready gauge",
+				`xr_composed_ready{name="xr"} 1`,
+			},
+		},
+		"Relabeled": {
+			reason:  "Should apply the relabel configs of the resource to the composed families.",
+			xr:      newXR(ref("ready")),
+			relabel: []RelabelConfig{{SourceLabels: []string{"__name__"}, Regex: "xr_composed_resources", Action: RelabelDrop}, {TargetLabel: "team", Replacement: pointer.String("a")}},
+			want: []string{
+				"# TYPE xr_composed_resources gauge",
+				"# TYPE xr_composed_ready gauge",
+				`xr_composed_ready{name="xr",team="a"} 1`,
+			},
+		},
+		"NoComposite": {
+			reason: "Should not write the composed families of objects without resourceRefs.",
+			xr:     newObject(map[string]any{}),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{})
+
+			mrs := newInstrumentedStore(newMetricsStore("mr", "", "", ResourceConfig{}, nil, nil), schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"})
+			_ = mrs.Replace([]any{newMR("ready", "True"), newMR("unready", "False")}, "1")
+			m.addMetricStore("mr", func() {}, mrs)
+
+			config := ResourceConfig{ResourceOptions: ResourceOptions{DisabledFamilies: tc.disabled, RelabelConfigs: tc.relabel}}
+			xrs := newInstrumentedStore(newMetricsStore("xr", "", "", config, nil, nil), schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "xdatabases"})
+			xrs.composition = newComposition("xr", objectLabels{}, config)
+			_ = xrs.Replace([]any{tc.xr}, "1")
+			m.addMetricStore("xr", func() {}, xrs)
+
+			buf := &bytes.Buffer{}
+			m.WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.Contains(line, "_composed_") && !strings.HasPrefix(line, "# HELP") {
+					got = append(got, line)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nWriteAll(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
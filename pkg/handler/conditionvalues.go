@@ -0,0 +1,151 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// ConditionValue is the value of a condition status. It is given as number,
+// or as one of the strings NaN, +Inf and -Inf.
+type ConditionValue float64
+
+// UnmarshalJSON accepts numbers and numeric strings, including NaN.
+func (v *ConditionValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var f float64
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("condition value must be a number or NaN: %s", data)
+		}
+		*v = ConditionValue(f)
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("condition value must be a number or NaN: %q", s)
+	}
+	*v = ConditionValue(f)
+	return nil
+}
+
+// MarshalJSON writes NaN and infinite values as strings.
+func (v ConditionValue) MarshalJSON() ([]byte, error) {
+	f := float64(v)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Marshal(f)
+}
+
+// ConditionEncoding is the encoding of the condition families.
+type ConditionEncoding string
+
+const (
+	// ConditionEncodingValue maps the status of a condition to the value of
+	// a single series, as configured by ConditionValues
+	ConditionEncodingValue ConditionEncoding = "Value"
+	// ConditionEncodingStateSet writes a series for each of the statuses
+	// True, False and Unknown, labeled by status, with the value 1 for the
+	// current status and 0 for the others, like an OpenMetrics StateSet
+	ConditionEncodingStateSet ConditionEncoding = "StateSet"
+)
+
+// ConditionValues map the status of the conditions of the _ready, _synced and
+// extra condition families to their values. Unset values default to True=1,
+// False=0 and other=-1.
+type ConditionValues struct {
+	True  *ConditionValue `json:"true,omitempty"`
+	False *ConditionValue `json:"false,omitempty"`
+	// Unknown is the value of all other statuses
+	Unknown *ConditionValue `json:"unknown,omitempty"`
+	// Missing is the value of the _ready and _synced families of objects
+	// without the condition. Defaults to Unknown
+	Missing *ConditionValue `json:"missing,omitempty"`
+	// OmitMissing omits the series of objects without the condition instead,
+	// e.g. for kinds never reporting the Synced condition. Extra conditions
+	// are always omitted
+	OmitMissing bool `json:"omitMissing,omitempty"`
+}
+
+// value returns the value of the condition of type typ of o and false if its
+// series is omitted.
+func (v *ConditionValues) value(o *object, typ xpv1.ConditionType) (float64, bool) {
+	if c, ok := o.condition(typ); ok {
+		return v.status(c), true
+	}
+	if v == nil {
+		return -1, true
+	}
+	if v.OmitMissing {
+		return 0, false
+	}
+	if v.Missing != nil {
+		return float64(*v.Missing), true
+	}
+	return valueOr(v.Unknown, -1), true
+}
+
+// status returns the value of the status of c.
+func (v *ConditionValues) status(c xpv1.Condition) float64 {
+	if v == nil {
+		return conditionValue(c)
+	}
+	switch c.Status {
+	case "True":
+		return valueOr(v.True, 1)
+	case "False":
+		return valueOr(v.False, 0)
+	default:
+		return valueOr(v.Unknown, -1)
+	}
+}
+
+// help returns the HELP text of the family of the condition typ, describing
+// the values of its statuses in the given encoding. withMissing adds the value
+// of objects without the condition, if configured.
+func (v *ConditionValues) help(typ string, encoding ConditionEncoding, withMissing bool) string {
+	if encoding == ConditionEncodingStateSet {
+		return "A metrics series for each status of the " + typ + " status condition, labeled by status (current=1,other=0)"
+	}
+	var d ConditionValues
+	if v != nil {
+		d = *v
+	}
+	values := "True=" + formatValue(valueOr(d.True, 1)) + ",False=" + formatValue(valueOr(d.False, 0)) + ",other=" + formatValue(valueOr(d.Unknown, -1))
+	if withMissing && d.Missing != nil && !d.OmitMissing {
+		values += ",missing=" + formatValue(float64(*d.Missing))
+	}
+	return "A metrics series mapping the " + typ + " status condition to a value (" + values + ")"
+}
+
+// formatValue formats a condition value like the series values.
+func formatValue(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func valueOr(v *ConditionValue, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return float64(*v)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConditionValueJSON(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		"Number": {
+			reason: "Should accept numbers.",
+			data:   `2.5`,
+			want:   `2.5`,
+		},
+		"NumericString": {
+			reason: "Should accept numeric strings.",
+			data:   `"-1"`,
+			want:   `-1`,
+		},
+		"NaN": {
+			reason: "Should accept NaN and write it as string.",
+			data:   `"NaN"`,
+			want:   `"NaN"`,
+		},
+		"Invalid": {
+			reason:  "Should reject other strings.",
+			data:    `"omit"`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var v ConditionValue
+			err := json.Unmarshal([]byte(tc.data), &v)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("\n%s\nUnmarshalJSON(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+			if tc.wantErr {
+				return
+			}
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("\n%s\nMarshalJSON(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("\n%s\nMarshalJSON(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
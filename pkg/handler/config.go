@@ -0,0 +1,542 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Config configures the metric stores created by a ManagedMetricsHandler.
+type Config struct {
+	// Defaults apply to all resources, unless overridden by an entry in Resources
+	Defaults ResourceOptions `json:"defaults,omitempty"`
+	// Resources holds the configuration for individual resources
+	Resources []ResourceConfig `json:"resources,omitempty"`
+
+	// ListPageSize is the number of objects fetched per request when listing
+	// resources. Defaults to 500, a negative value lists all objects at once
+	// from the watch cache of the API server
+	ListPageSize int64 `json:"listPageSize,omitempty"`
+
+	// Naming configures the names of the exported families
+	Naming Naming `json:"naming,omitempty"`
+
+	// ClusterName is the value of the cluster label of the objects of the
+	// cluster x-metrics is running in. The label is only added if set
+	ClusterName string `json:"clusterName,omitempty"`
+	// Clusters lists remote clusters whose objects are exported in addition to
+	// those of the local cluster. Requires ClusterName
+	Clusters []ClusterConfig `json:"clusters,omitempty"`
+
+	// Labels are static labels added to every series, e.g. environment: prod,
+	// to tell the series of multiple x-metrics instances apart. Labels of the
+	// series take precedence
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Staleness configures how the series of stores not synced for a while
+	// are served
+	Staleness Staleness `json:"staleness,omitempty"`
+}
+
+const defaultListPageSize = 500
+
+// ResourceConfig configures the metric store of a single resource.
+type ResourceConfig struct {
+	Group string `json:"group"`
+	// Version of the resource. If empty, the configuration applies to all versions
+	Version  string `json:"version,omitempty"`
+	Resource string `json:"resource"`
+	// Kind of the resource, used in HELP texts. Defaults to the singular resource
+	Kind string `json:"kind,omitempty"`
+	// Watch registers a store of the resource, instead of only configuring the
+	// stores registered for it, e.g. by Metrics. The store is named
+	// <group>_<kind>_<version> and added or removed when the configuration
+	// is reloaded. Requires Version
+	Watch bool `json:"watch,omitempty"`
+
+	ResourceOptions `json:",inline"`
+}
+
+// ResourceOptions configure the metric families generated for a resource.
+// Unset options are taken from the defaults, so booleans are pointers to let
+// a resource disable an option enabled by the defaults.
+type ResourceOptions struct {
+	// InfoMappings lists field paths exposed as labels on the _info family
+	InfoMappings []InfoMappings `json:"infoMappings,omitempty"`
+	// SensitiveFields lists glob patterns of field paths, matched ignoring
+	// case, whose info mappings are redacted so that secrets don't leak into
+	// labels. Defaults to DefaultSensitiveFields, except for Secret references,
+	// set to [] to redact nothing
+	SensitiveFields []string `json:"sensitiveFields,omitempty"`
+	// Redaction is how info mappings of sensitive fields are exported, Redact
+	// or Drop. Defaults to Redact
+	Redaction Redaction `json:"redaction,omitempty"`
+
+	// ConditionMessages adds the condition message as label to the _status_reason family.
+	// Messages often contain unique error details, so enabling this increases cardinality
+	ConditionMessages *bool `json:"conditionMessages,omitempty"`
+
+	// ConditionEncoding is the encoding of the _ready, _synced and extra
+	// condition families, Value or StateSet. Defaults to Value
+	ConditionEncoding ConditionEncoding `json:"conditionEncoding,omitempty"`
+	// ConditionValues customize the values of the condition statuses of the
+	// _ready, _synced and extra condition families
+	ConditionValues *ConditionValues `json:"conditionValues,omitempty"`
+
+	// ConditionUID adds the object UID as uid label to the _ready and _synced
+	// families, so that alerts identify an object even after it was recreated
+	ConditionUID *bool `json:"conditionUID,omitempty"`
+	// UIDLabel adds the object UID as uid label to the series of all families,
+	// so that objects recreated under the same name are told apart. Every
+	// recreation starts new series, which increases cardinality
+	UIDLabel *bool `json:"uidLabel,omitempty"`
+
+	// AnnotationsAllowlist lists glob patterns of the annotations exposed as
+	// labels on the _annotations family. Use "*" to expose all annotations
+	AnnotationsAllowlist []string `json:"annotationsAllowlist,omitempty"`
+
+	// LabelsAllowlist lists glob patterns of the labels exposed on the _labels
+	// family. If empty, all labels are exposed
+	LabelsAllowlist []string `json:"labelsAllowlist,omitempty"`
+	// LabelsDenylist lists glob patterns of labels never exposed on the _labels family
+	LabelsDenylist []string `json:"labelsDenylist,omitempty"`
+
+	// Gauges lists numeric field paths exported as dedicated gauge families
+	Gauges []GaugeMappings `json:"gauges,omitempty"`
+
+	// Expressions lists CEL expressions exported as dedicated gauge families
+	Expressions []ExpressionMappings `json:"expressions,omitempty"`
+
+	// RelabelConfigs rewrite the labels of the generated series, e.g. to
+	// rename or drop labels, applied in order like Prometheus relabel configs
+	RelabelConfigs []RelabelConfig `json:"relabelConfigs,omitempty"`
+
+	// DriftFields lists the field paths compared between spec.forProvider and
+	// status.atProvider of managed resources for the _spec_drift family, e.g.
+	// instanceClass or tags. If empty, all top-level fields but references
+	// and selectors are compared
+	DriftFields []string `json:"driftFields,omitempty"`
+
+	// ExtraConditions lists status condition types beyond Ready and Synced,
+	// e.g. LastAsyncOperation, exported as <metric>_condition_<type> families
+	// mapping their status like the _ready family
+	ExtraConditions []string `json:"extraConditions,omitempty"`
+
+	// DropSpec removes the spec of objects before metrics are generated, to
+	// save memory for resources with large specs. Families derived from the
+	// spec, like info mappings of spec fields, are empty then
+	DropSpec *bool `json:"dropSpec,omitempty"`
+
+	// MetadataOnly watches only the metadata of objects, for resources where
+	// only metadata derived families are needed. Families derived from spec
+	// and status are empty then
+	MetadataOnly *bool `json:"metadataOnly,omitempty"`
+
+	// LabelSelector restricts the watched objects to those matching the
+	// selector, e.g. environment=prod
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// FieldSelector restricts the watched objects to those matching the
+	// selector. Custom resources only support metadata.name and metadata.namespace
+	FieldSelector string `json:"fieldSelector,omitempty"`
+
+	// Namespaces restricts cluster wide registrations to the listed namespaces.
+	// The objects of all namespaces are exported by a single set of families
+	Namespaces []string `json:"namespaces,omitempty"`
+	// ExcludeNamespaces lists namespaces whose objects are not exported by
+	// cluster wide registrations
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	// NamespaceLabel always adds the namespace label, empty for cluster
+	// scoped objects, so that the families of all stores have the same labels
+	NamespaceLabel *bool `json:"namespaceLabel,omitempty"`
+
+	// Resync is the period in which all objects are listed again, so that
+	// missed events don't persist, e.g. 1h. Defaults to the resync period of
+	// the handler; objects are not listed again if zero
+	Resync *metav1.Duration `json:"resync,omitempty"`
+
+	// Priority orders the initial lists of resources waiting for the limit of
+	// concurrent initial lists, e.g. on startup. Resources of a higher
+	// priority are listed first, so that their families are served earlier
+	Priority int `json:"priority,omitempty"`
+
+	// WarningEvents watches the Warning events of the objects and exports
+	// their count by reason as _warning_events_total family. Each store
+	// watches the events of its namespace, selected by the kind of the
+	// resource if it is known
+	WarningEvents *bool `json:"warningEvents,omitempty"`
+
+	// Limits cap the objects exported by each store of the resource
+	Limits *StoreLimits `json:"limits,omitempty"`
+
+	// DisabledFamilies lists families which are not exported, keyed like Help,
+	// e.g. labels or created, to reduce the series of a resource
+	DisabledFamilies []string `json:"disabledFamilies,omitempty"`
+
+	// Help overrides the HELP texts of families, keyed by the family suffix
+	// without leading underscore (e.g. ready) or object for the <metric> family.
+	// The placeholders {group}, {version}, {resource} and {kind} are replaced
+	// in all HELP texts, including those of gauges and expressions
+	Help map[string]string `json:"help,omitempty"`
+}
+
+// LoadConfig reads a Config from the YAML file at path.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("cannot read config file: %w", err)
+	}
+	return ParseConfig(b)
+}
+
+// ParseConfig parses and validates a Config in YAML.
+func ParseConfig(b []byte) (Config, error) {
+	c := Config{}
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return c, fmt.Errorf("cannot parse config file: %w", err)
+	}
+	return c, c.Validate()
+}
+
+// Validate returns an error if the configuration is incomplete.
+func (c Config) Validate() error {
+	switch c.Naming.Strategy {
+	case "", NamingMetricName, NamingGroupResource:
+	default:
+		return fmt.Errorf("naming.strategy: unknown strategy %q", c.Naming.Strategy)
+	}
+	switch c.Staleness.Action {
+	case "", StaleDrop, StaleLabel:
+	default:
+		return fmt.Errorf("staleness.action: unknown action %q", c.Staleness.Action)
+	}
+	if c.Staleness.After.Duration < 0 {
+		return fmt.Errorf("staleness.after: must not be negative")
+	}
+	if c.Staleness.After.Duration > 0 && c.Staleness.After.Duration < MinStaleAfter {
+		return fmt.Errorf("staleness.after: must be at least %s", MinStaleAfter)
+	}
+	if len(c.Clusters) > 0 && c.ClusterName == "" {
+		return fmt.Errorf("clusterName: must not be empty if clusters are configured")
+	}
+	for k := range c.Labels {
+		if !ValidLabelName(k) {
+			return fmt.Errorf("labels: invalid label name %q", k)
+		}
+	}
+	names := map[string]bool{c.ClusterName: true}
+	for i, cl := range c.Clusters {
+		if cl.Name == "" || cl.Kubeconfig == "" {
+			return fmt.Errorf("clusters[%d]: name and kubeconfig must not be empty", i)
+		}
+		if names[cl.Name] {
+			return fmt.Errorf("clusters[%d]: duplicate cluster name %q", i, cl.Name)
+		}
+		names[cl.Name] = true
+	}
+	for i, r := range c.Resources {
+		if r.Resource == "" {
+			return fmt.Errorf("resources[%d]: resource must not be empty", i)
+		}
+		if r.Watch && r.Version == "" {
+			return fmt.Errorf("resources[%d]: watch requires a version", i)
+		}
+		if err := r.ResourceOptions.validate(); err != nil {
+			return fmt.Errorf("resources[%d].%w", i, err)
+		}
+	}
+	if err := c.Defaults.validate(); err != nil {
+		return fmt.Errorf("defaults.%w", err)
+	}
+	return nil
+}
+
+func (o ResourceOptions) validate() error {
+	infoLabels := map[string]bool{}
+	for j, m := range o.InfoMappings {
+		if m.FieldPath == "" || m.Label == "" {
+			return fmt.Errorf("infoMappings[%d]: fieldPath and label must not be empty", j)
+		}
+		if ReservedInfoLabel(m.Label) {
+			return fmt.Errorf("infoMappings[%d]: label %q is reserved", j, m.Label)
+		}
+		if infoLabels[m.Label] {
+			return fmt.Errorf("infoMappings[%d]: duplicate label %q", j, m.Label)
+		}
+		infoLabels[m.Label] = true
+	}
+	switch o.Redaction {
+	case "", RedactionRedact, RedactionDrop:
+	default:
+		return fmt.Errorf("redaction: unknown redaction %q", o.Redaction)
+	}
+	for j, g := range o.Gauges {
+		if g.FieldPath == "" || g.Suffix == "" {
+			return fmt.Errorf("gauges[%d]: fieldPath and suffix must not be empty", j)
+		}
+	}
+	for j, c := range o.ExtraConditions {
+		if c == "" {
+			return fmt.Errorf("extraConditions[%d]: must not be empty", j)
+		}
+	}
+	switch o.ConditionEncoding {
+	case "", ConditionEncodingValue, ConditionEncodingStateSet:
+	default:
+		return fmt.Errorf("conditionEncoding: unknown encoding %q", o.ConditionEncoding)
+	}
+	for j, r := range o.RelabelConfigs {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("relabelConfigs[%d]: %w", j, err)
+		}
+	}
+	if _, err := labels.Parse(o.LabelSelector); err != nil {
+		return fmt.Errorf("labelSelector: %w", err)
+	}
+	if _, err := fields.ParseSelector(o.FieldSelector); err != nil {
+		return fmt.Errorf("fieldSelector: %w", err)
+	}
+	for j, f := range o.DisabledFamilies {
+		if !knownFamily(f) {
+			return fmt.Errorf("disabledFamilies[%d]: unknown family %q", j, f)
+		}
+	}
+	if o.Resync != nil && o.Resync.Duration < 0 {
+		return fmt.Errorf("resync: must not be negative")
+	}
+	if o.Limits != nil {
+		if err := o.Limits.validate(); err != nil {
+			return fmt.Errorf("limits.%w", err)
+		}
+	}
+	for j, e := range o.Expressions {
+		if e.Expression == "" || e.Suffix == "" {
+			return fmt.Errorf("expressions[%d]: expression and suffix must not be empty", j)
+		}
+		if _, err := CompileExpression(e.Expression); err != nil {
+			return fmt.Errorf("expressions[%d]: %w", j, err)
+		}
+	}
+	return nil
+}
+
+func (c Config) listPageSize() int64 {
+	if c.ListPageSize == 0 {
+		return defaultListPageSize
+	}
+	return c.ListPageSize
+}
+
+// watchedResources returns the resources to register a store for, keyed by
+// the name of the store.
+func (c Config) watchedResources() map[string]schema.GroupVersionResource {
+	watched := map[string]schema.GroupVersionResource{}
+	for _, r := range c.Resources {
+		if !r.Watch {
+			continue
+		}
+		kind := r.Kind
+		if kind == "" {
+			kind = r.Resource
+		}
+		watched[GetValidLabel(r.Group+"_"+kind+"_"+r.Version)] = schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+	}
+	return watched
+}
+
+// ResourceConfigFor returns the configuration of the given resource. The first
+// entry matching group, resource and version wins. If no entry matches, the
+// defaults are returned.
+func (c Config) ResourceConfigFor(gvr schema.GroupVersionResource) ResourceConfig {
+	for _, r := range c.Resources {
+		if r.Matches(gvr) {
+			r.ResourceOptions = r.ResourceOptions.withDefaults(c.Defaults)
+			return r
+		}
+	}
+	return ResourceConfig{ResourceOptions: c.Defaults}
+}
+
+// ResourceConfigWithBase returns base with the options of the first entry
+// matching the resource of base taking precedence. It is used for built-in
+// stores, whose options can be overridden by the configuration.
+func (c Config) ResourceConfigWithBase(base ResourceConfig) ResourceConfig {
+	gvr := schema.GroupVersionResource{Group: base.Group, Version: base.Version, Resource: base.Resource}
+	for _, r := range c.Resources {
+		if r.Matches(gvr) {
+			base.ResourceOptions = r.ResourceOptions.withDefaults(base.ResourceOptions)
+			break
+		}
+	}
+	return base
+}
+
+// Matches returns true if the configuration applies to the given resource.
+func (r ResourceConfig) Matches(gvr schema.GroupVersionResource) bool {
+	return r.Group == gvr.Group && r.Resource == gvr.Resource && (r.Version == "" || r.Version == gvr.Version)
+}
+
+// withDefaults returns the options with all unset fields taken from d.
+func (o ResourceOptions) withDefaults(d ResourceOptions) ResourceOptions {
+	if o.InfoMappings == nil {
+		o.InfoMappings = d.InfoMappings
+	}
+	if o.SensitiveFields == nil {
+		o.SensitiveFields = d.SensitiveFields
+	}
+	if o.Redaction == "" {
+		o.Redaction = d.Redaction
+	}
+	if o.ConditionMessages == nil {
+		o.ConditionMessages = d.ConditionMessages
+	}
+	if o.ConditionUID == nil {
+		o.ConditionUID = d.ConditionUID
+	}
+	if o.UIDLabel == nil {
+		o.UIDLabel = d.UIDLabel
+	}
+	if o.AnnotationsAllowlist == nil {
+		o.AnnotationsAllowlist = d.AnnotationsAllowlist
+	}
+	if o.LabelsAllowlist == nil {
+		o.LabelsAllowlist = d.LabelsAllowlist
+	}
+	if o.LabelsDenylist == nil {
+		o.LabelsDenylist = d.LabelsDenylist
+	}
+	if o.Gauges == nil {
+		o.Gauges = d.Gauges
+	}
+	if o.Expressions == nil {
+		o.Expressions = d.Expressions
+	}
+	if o.ExtraConditions == nil {
+		o.ExtraConditions = d.ExtraConditions
+	}
+	if o.DriftFields == nil {
+		o.DriftFields = d.DriftFields
+	}
+	if o.ConditionEncoding == "" {
+		o.ConditionEncoding = d.ConditionEncoding
+	}
+	if o.ConditionValues == nil {
+		o.ConditionValues = d.ConditionValues
+	}
+	if o.RelabelConfigs == nil {
+		o.RelabelConfigs = d.RelabelConfigs
+	}
+	if o.DropSpec == nil {
+		o.DropSpec = d.DropSpec
+	}
+	if o.MetadataOnly == nil {
+		o.MetadataOnly = d.MetadataOnly
+	}
+	if o.WarningEvents == nil {
+		o.WarningEvents = d.WarningEvents
+	}
+	if o.NamespaceLabel == nil {
+		o.NamespaceLabel = d.NamespaceLabel
+	}
+	if o.LabelSelector == "" {
+		o.LabelSelector = d.LabelSelector
+	}
+	if o.FieldSelector == "" {
+		o.FieldSelector = d.FieldSelector
+	}
+	if o.Namespaces == nil {
+		o.Namespaces = d.Namespaces
+	}
+	if o.ExcludeNamespaces == nil {
+		o.ExcludeNamespaces = d.ExcludeNamespaces
+	}
+	if o.Limits == nil {
+		o.Limits = d.Limits
+	}
+	if o.DisabledFamilies == nil {
+		o.DisabledFamilies = d.DisabledFamilies
+	}
+	if o.Resync == nil {
+		o.Resync = d.Resync
+	}
+	if o.Priority == 0 {
+		o.Priority = d.Priority
+	}
+	if len(d.Help) > 0 {
+		help := make(map[string]string, len(d.Help)+len(o.Help))
+		for k, v := range d.Help {
+			help[k] = v
+		}
+		for k, v := range o.Help {
+			help[k] = v
+		}
+		o.Help = help
+	}
+	return o
+}
+
+// expandHelp replaces the placeholders of a HELP text.
+func (r ResourceConfig) expandHelp(help string) string {
+	if !strings.Contains(help, "{") {
+		return help
+	}
+	kind := r.Kind
+	if kind == "" {
+		kind = singular(r.Resource)
+	}
+	return strings.NewReplacer("{group}", r.Group, "{version}", r.Version, "{resource}", r.Resource, "{kind}", kind).Replace(help)
+}
+
+// multiNamespace returns true if the options restrict the watched namespaces.
+func (o ResourceOptions) multiNamespace() bool {
+	return len(o.Namespaces) > 0 || len(o.ExcludeNamespaces) > 0
+}
+
+// watchedNamespaces returns the namespaces watched by cluster wide
+// registrations and the field selector to watch them with. Excluded
+// namespaces are removed from Namespaces, or excluded by the field selector
+// if all namespaces are watched.
+func (o ResourceOptions) watchedNamespaces() ([]string, string) {
+	excluded := map[string]bool{}
+	for _, ns := range o.ExcludeNamespaces {
+		excluded[ns] = true
+	}
+	if len(o.Namespaces) > 0 {
+		namespaces := []string{}
+		for _, ns := range o.Namespaces {
+			if !excluded[ns] {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		return namespaces, o.FieldSelector
+	}
+	selectors := []string{}
+	if o.FieldSelector != "" {
+		selectors = append(selectors, o.FieldSelector)
+	}
+	for _, ns := range o.ExcludeNamespaces {
+		selectors = append(selectors, "metadata.namespace!="+ns)
+	}
+	return []string{""}, strings.Join(selectors, ",")
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+)
+
+func TestResourceConfigFor(t *testing.T) {
+	regionMapping := []InfoMappings{{FieldPath: "spec.forProvider.region", Label: "region"}}
+	idMapping := []InfoMappings{{FieldPath: "status.atProvider.id", Label: "id"}}
+
+	config := Config{
+		Resources: []ResourceConfig{
+			{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances", ResourceOptions: ResourceOptions{InfoMappings: idMapping}},
+			{Group: "rds.aws.upbound.io", Resource: "instances", ResourceOptions: ResourceOptions{InfoMappings: regionMapping}},
+			{Group: "s3.aws.upbound.io", Resource: "buckets", ResourceOptions: ResourceOptions{DropSpec: pointer.Bool(false), WarningEvents: pointer.Bool(false)}},
+		},
+	}
+
+	cases := map[string]struct {
+		reason   string
+		defaults ResourceOptions
+		gvr      schema.GroupVersionResource
+		want     ResourceOptions
+	}{
+		"ExactVersion": {
+			reason: "Should return the first entry matching the version.",
+			gvr:    schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"},
+			want:   ResourceOptions{InfoMappings: idMapping},
+		},
+		"AnyVersion": {
+			reason: "Should return entries without version for all versions.",
+			gvr:    schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta2", Resource: "instances"},
+			want:   ResourceOptions{InfoMappings: regionMapping},
+		},
+		"NoMatch": {
+			reason: "Should return an empty configuration if no entry matches.",
+			gvr:    schema.GroupVersionResource{Group: "ec2.aws.upbound.io", Version: "v1beta1", Resource: "instances"},
+			want:   ResourceOptions{},
+		},
+		"Defaults": {
+			reason:   "Should return the defaults if no entry matches.",
+			defaults: ResourceOptions{AnnotationsAllowlist: []string{"team"}},
+			gvr:      schema.GroupVersionResource{Group: "ec2.aws.upbound.io", Version: "v1beta1", Resource: "instances"},
+			want:     ResourceOptions{AnnotationsAllowlist: []string{"team"}},
+		},
+		"MergeDefaults": {
+			reason:   "Should take unset options of a matching entry from the defaults.",
+			defaults: ResourceOptions{InfoMappings: idMapping, AnnotationsAllowlist: []string{"team"}, Priority: 10},
+			gvr:      schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta2", Resource: "instances"},
+			want:     ResourceOptions{InfoMappings: regionMapping, AnnotationsAllowlist: []string{"team"}, Priority: 10},
+		},
+		"OverrideBooleanDefaults": {
+			reason:   "Should keep options of a matching entry disabled although enabled by the defaults.",
+			defaults: ResourceOptions{DropSpec: pointer.Bool(true), WarningEvents: pointer.Bool(true), NamespaceLabel: pointer.Bool(true)},
+			gvr:      schema.GroupVersionResource{Group: "s3.aws.upbound.io", Version: "v1beta1", Resource: "buckets"},
+			want:     ResourceOptions{DropSpec: pointer.Bool(false), WarningEvents: pointer.Bool(false), NamespaceLabel: pointer.Bool(true)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config.Defaults = tc.defaults
+			got := config.ResourceConfigFor(tc.gvr)
+			if diff := cmp.Diff(tc.want, got.ResourceOptions); diff != "" {
+				t.Errorf("\n%s\nResourceConfigFor(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResourceConfigWithBase(t *testing.T) {
+	regionMapping := []InfoMappings{{FieldPath: "data.region", Label: "region"}}
+	base := ResourceConfig{
+		Group:           "apiextensions.crossplane.io",
+		Version:         "v1alpha1",
+		Resource:        "environmentconfigs",
+		ResourceOptions: ResourceOptions{DisabledFamilies: []string{"ready"}},
+	}
+
+	cases := map[string]struct {
+		reason string
+		config Config
+		want   ResourceOptions
+	}{
+		"Override": {
+			reason: "Should take the options of a matching entry, and unset options from the base.",
+			config: Config{Resources: []ResourceConfig{
+				{Group: "apiextensions.crossplane.io", Resource: "environmentconfigs", ResourceOptions: ResourceOptions{InfoMappings: regionMapping}},
+			}},
+			want: ResourceOptions{InfoMappings: regionMapping, DisabledFamilies: []string{"ready"}},
+		},
+		"NoMatch": {
+			reason: "Should return the base if no entry matches.",
+			config: Config{Resources: []ResourceConfig{
+				{Group: "apiextensions.crossplane.io", Version: "v1beta1", Resource: "environmentconfigs", ResourceOptions: ResourceOptions{InfoMappings: regionMapping}},
+			}},
+			want: ResourceOptions{DisabledFamilies: []string{"ready"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.config.ResourceConfigWithBase(base)
+			if diff := cmp.Diff(tc.want, got.ResourceOptions); diff != "" {
+				t.Errorf("\n%s\nResourceConfigWithBase(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+defaults:
+  annotationsAllowlist: ["team"]
+  conditionValues:
+    unknown: 2
+    missing: "-2"
+resources:
+  - group: rds.aws.upbound.io
+    resource: instances
+    infoMappings:
+      - fieldPath: spec.forProvider.region
+        label: region
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown, missing := ConditionValue(2), ConditionValue(-2)
+	want := Config{
+		Defaults: ResourceOptions{AnnotationsAllowlist: []string{"team"}, ConditionValues: &ConditionValues{Unknown: &unknown, Missing: &missing}},
+		Resources: []ResourceConfig{
+			{
+				Group:           "rds.aws.upbound.io",
+				Resource:        "instances",
+				ResourceOptions: ResourceOptions{InfoMappings: []InfoMappings{{FieldPath: "spec.forProvider.region", Label: "region"}}},
+			},
+		},
+	}
+	got, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig(...): %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LoadConfig(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		config  Config
+		wantErr bool
+	}{
+		"Valid": {
+			reason: "Should accept a valid configuration.",
+			config: Config{Resources: []ResourceConfig{{Resource: "instances", ResourceOptions: ResourceOptions{LabelSelector: "environment in (prod, staging)"}}}},
+		},
+		"EmptyExtraCondition": {
+			reason:  "Should reject empty extra condition types.",
+			config:  Config{Defaults: ResourceOptions{ExtraConditions: []string{""}}},
+			wantErr: true,
+		},
+		"UnknownStaleAction": {
+			reason:  "Should reject unknown staleness actions.",
+			config:  Config{Staleness: Staleness{Action: "Hide"}},
+			wantErr: true,
+		},
+		"ShortStaleness": {
+			reason:  "Should reject staleness thresholds below the bookmark interval of the API server.",
+			config:  Config{Staleness: Staleness{After: metav1.Duration{Duration: 30 * time.Second}}},
+			wantErr: true,
+		},
+		"WatchWithoutVersion": {
+			reason:  "Should reject watched resources without a version.",
+			config:  Config{Resources: []ResourceConfig{{Group: "s3.aws.upbound.io", Resource: "buckets", Watch: true}}},
+			wantErr: true,
+		},
+		"InvalidStaticLabel": {
+			reason:  "Should reject invalid static label names.",
+			config:  Config{Labels: map[string]string{"app.kubernetes.io/name": "x"}},
+			wantErr: true,
+		},
+		"InvalidRelabelConfig": {
+			reason:  "Should reject relabel configs without target label.",
+			config:  Config{Defaults: ResourceOptions{RelabelConfigs: []RelabelConfig{{SourceLabels: []string{"name"}}}}},
+			wantErr: true,
+		},
+		"UnknownConditionEncoding": {
+			reason:  "Should reject unknown condition encodings.",
+			config:  Config{Defaults: ResourceOptions{ConditionEncoding: "Boolean"}},
+			wantErr: true,
+		},
+		"ReservedInfoLabel": {
+			reason:  "Should reject info mapping labels set by x-metrics itself.",
+			config:  Config{Defaults: ResourceOptions{InfoMappings: []InfoMappings{{FieldPath: "spec.forProvider.name", Label: "name"}}}},
+			wantErr: true,
+		},
+		"ReservedReferenceLabel": {
+			reason:  "Should reject info mapping labels of the references exported on _info.",
+			config:  Config{Resources: []ResourceConfig{{Resource: "instances", ResourceOptions: ResourceOptions{InfoMappings: []InfoMappings{{FieldPath: "spec.compositionRef.name", Label: "composition_name"}}}}}},
+			wantErr: true,
+		},
+		"DuplicateInfoLabel": {
+			reason: "Should reject info mappings of the same label.",
+			config: Config{Defaults: ResourceOptions{InfoMappings: []InfoMappings{
+				{FieldPath: "spec.forProvider.region", Label: "region"},
+				{FieldPath: "status.atProvider.region", Label: "region"},
+			}}},
+			wantErr: true,
+		},
+		"UnknownRedaction": {
+			reason:  "Should reject unknown redactions.",
+			config:  Config{Defaults: ResourceOptions{Redaction: "Hash"}},
+			wantErr: true,
+		},
+		"MissingResource": {
+			reason:  "Should require the resource.",
+			config:  Config{Resources: []ResourceConfig{{Group: "rds.aws.upbound.io"}}},
+			wantErr: true,
+		},
+		"InvalidLabelSelector": {
+			reason:  "Should reject invalid label selectors.",
+			config:  Config{Defaults: ResourceOptions{LabelSelector: "environment in prod"}},
+			wantErr: true,
+		},
+		"ClustersWithoutClusterName": {
+			reason:  "Should require the name of the local cluster if remote clusters are configured.",
+			config:  Config{Clusters: []ClusterConfig{{Name: "spoke", Kubeconfig: "/etc/spoke/kubeconfig"}}},
+			wantErr: true,
+		},
+		"DuplicateCluster": {
+			reason:  "Should reject duplicate cluster names.",
+			config:  Config{ClusterName: "hub", Clusters: []ClusterConfig{{Name: "hub", Kubeconfig: "/etc/spoke/kubeconfig"}}},
+			wantErr: true,
+		},
+		"InvalidExpression": {
+			reason:  "Should reject expressions which don't compile.",
+			config:  Config{Defaults: ResourceOptions{Expressions: []ExpressionMappings{{Expression: "has(", Suffix: "broken"}}}},
+			wantErr: true,
+		},
+		"NegativeResync": {
+			reason:  "Should reject negative resync periods.",
+			config:  Config{Defaults: ResourceOptions{Resync: &metav1.Duration{Duration: -time.Minute}}},
+			wantErr: true,
+		},
+		"UnknownShedFamily": {
+			reason:  "Should reject shed families which don't exist.",
+			config:  Config{Defaults: ResourceOptions{Limits: &StoreLimits{ShedAbove: 1000, ShedFamilies: []string{"label"}}}},
+			wantErr: true,
+		},
+		"UnknownDisabledFamily": {
+			reason:  "Should reject disabled families which don't exist.",
+			config:  Config{Defaults: ResourceOptions{DisabledFamilies: []string{"_labels"}}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("\n%s\nValidate(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWatchedNamespaces(t *testing.T) {
+	type want struct {
+		namespaces    []string
+		fieldSelector string
+	}
+	cases := map[string]struct {
+		reason string
+		opts   ResourceOptions
+		want   want
+	}{
+		"AllNamespaces": {
+			reason: "Should watch all namespaces by default.",
+			want:   want{namespaces: []string{""}},
+		},
+		"Namespaces": {
+			reason: "Should watch the listed namespaces without excluded ones.",
+			opts:   ResourceOptions{Namespaces: []string{"team-a", "team-b"}, ExcludeNamespaces: []string{"team-b"}},
+			want:   want{namespaces: []string{"team-a"}},
+		},
+		"ExcludeNamespaces": {
+			reason: "Should exclude namespaces by field selector if all namespaces are watched.",
+			opts:   ResourceOptions{FieldSelector: "metadata.name!=db", ExcludeNamespaces: []string{"kube-system", "crossplane-system"}},
+			want: want{
+				namespaces:    []string{""},
+				fieldSelector: "metadata.name!=db,metadata.namespace!=kube-system,metadata.namespace!=crossplane-system",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			namespaces, fieldSelector := tc.opts.watchedNamespaces()
+			if diff := cmp.Diff(tc.want, want{namespaces: namespaces, fieldSelector: fieldSelector}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nwatchedNamespaces(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"time"
+)
+
+// StoreDebug is the state of a registered store, for investigations of the
+// memory and CPU usage of large installations.
+type StoreDebug struct {
+	Name     string `json:"name"`
+	Family   string `json:"family,omitempty"`
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+	// Reflectors is the number of namespaces and clusters the store watches
+	Reflectors int  `json:"reflectors"`
+	Synced     bool `json:"synced"`
+	Objects    int  `json:"objects"`
+	// Dropped are the objects ignored beyond limits.maxObjects
+	Dropped  int  `json:"dropped,omitempty"`
+	Shedding bool `json:"shedding,omitempty"`
+	// LastEvent is the time of the last list, watch or event, the oldest of
+	// the namespaces and clusters of the store
+	LastEvent *time.Time `json:"lastEvent,omitempty"`
+	// Error is the last failure of the lists and watches of the store since
+	// its last list, e.g. missing permissions
+	Error string `json:"error,omitempty"`
+}
+
+// StoresDebug returns the state of the registered stores, sorted by name.
+func (m *ManagedMetricsHandler) StoresDebug() []StoreDebug {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]StoreDebug, 0, len(m.metricsWriter))
+	for name, rs := range m.metricsWriter {
+		out = append(out, rs.debug(name))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Store returns the state of the registered store of name, false if no
+// store of name is registered.
+func (m *ManagedMetricsHandler) Store(name string) (StoreDebug, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rs, ok := m.metricsWriter[name]
+	if !ok {
+		return StoreDebug{}, false
+	}
+	return rs.debug(name), true
+}
+
+func (s *registeredStore) debug(name string) StoreDebug {
+	d := StoreDebug{Name: name, Family: s.family, Reflectors: len(s.stores), Synced: true}
+	var last time.Time
+	for i, st := range s.stores {
+		d.Group, d.Version, d.Resource = st.gvr.Group, st.gvr.Version, st.gvr.Resource
+		st.mu.Lock()
+		d.Objects += len(st.objects)
+		d.Dropped += len(st.dropped)
+		d.Shedding = d.Shedding || st.shedding
+		d.Synced = d.Synced && st.synced
+		if d.Error == "" {
+			d.Error = st.lastError
+		}
+		if i == 0 || st.syncTime.Before(last) {
+			last = st.syncTime
+		}
+		st.mu.Unlock()
+	}
+	if !last.IsZero() {
+		d.LastEvent = &last
+	}
+	return d
+}
+
+// StoresDebugHandler serves the StoresDebug as JSON.
+func (m *ManagedMetricsHandler) StoresDebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(m.StoresDebug())
+	})
+}
+
+// PprofHandlers returns the handlers of the runtime profiles keyed by their
+// path. net/http/pprof only serves the profiles below /debug/pprof/.
+func PprofHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/debug/pprof/":        http.HandlerFunc(pprof.Index),
+		"/debug/pprof/cmdline": http.HandlerFunc(pprof.Cmdline),
+		"/debug/pprof/profile": http.HandlerFunc(pprof.Profile),
+		"/debug/pprof/symbol":  http.HandlerFunc(pprof.Symbol),
+		"/debug/pprof/trace":   http.HandlerFunc(pprof.Trace),
+	}
+}
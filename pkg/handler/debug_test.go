@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStoresDebug(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "test.x-metrics.io", Version: "v1", Resource: "tests"}
+	newNamed := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{}}
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		return u
+	}
+	a, b := newNamed("a"), newNamed("b")
+
+	cases := map[string]struct {
+		reason string
+		stores map[string][][]any
+		want   []StoreDebug
+	}{
+		"Stores": {
+			reason: "Should list the stores sorted by name with their objects.",
+			stores: map[string][][]any{"b": {{a, b}}, "a": {{a}}},
+			want: []StoreDebug{
+				{Name: "a", Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Reflectors: 1, Synced: true, Objects: 1},
+				{Name: "b", Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Reflectors: 1, Synced: true, Objects: 2},
+			},
+		},
+		"Reflectors": {
+			reason: "Should sum the objects of the namespaces and clusters of a store.",
+			stores: map[string][][]any{"a": {{a}, {b}}},
+			want: []StoreDebug{
+				{Name: "a", Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Reflectors: 2, Synced: true, Objects: 2},
+			},
+		},
+		"NotSynced": {
+			reason: "Should report stores before their first list as not synced.",
+			stores: map[string][][]any{"a": {{a}, nil}},
+			want: []StoreDebug{
+				{Name: "a", Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Reflectors: 2, Objects: 1},
+			},
+		},
+		"Empty": {
+			reason: "Should return an empty list without stores.",
+			want:   []StoreDebug{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{})
+			for n, lists := range tc.stores {
+				stores := make([]*instrumentedStore, 0, len(lists))
+				for _, objs := range lists {
+					s := newInstrumentedStore(newMetricsStore(n, "", "", ResourceConfig{}, nil, nil), gvr)
+					if objs != nil {
+						_ = s.Replace(objs, "1")
+					}
+					stores = append(stores, s)
+				}
+				m.addMetricStore(n, func() {}, stores...)
+			}
+			if diff := cmp.Diff(tc.want, m.StoresDebug(), cmpopts.IgnoreFields(StoreDebug{}, "LastEvent")); diff != "" {
+				t.Errorf("\n%s\nStoresDebug(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStoreError(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "test.x-metrics.io", Version: "v1", Resource: "tests"}
+	m := NewManagedMetricsHandler(nil, Config{})
+	s := newInstrumentedStore(newMetricsStore("test", "", "", ResourceConfig{}, nil, nil), gvr)
+	i := &sharedInformer{Store: cache.NewStore(cache.MetaNamespaceKeyFunc), stores: map[*instrumentedStore]bool{s: true}}
+	m.addMetricStore("test", func() {}, s)
+
+	i.setError(errors.New("tests.test.x-metrics.io is forbidden"))
+	if got, _ := m.Store("test"); got.Error != "tests.test.x-metrics.io is forbidden" {
+		t.Errorf("Store(...): want the failure of the reflector, got %q", got.Error)
+	}
+	_ = i.Replace(nil, "1")
+	if got, _ := m.Store("test"); got.Error != "" || !got.Synced {
+		t.Errorf("Store(...): want the failure cleared by a list, got %+v", got)
+	}
+	if _, ok := m.Store("other"); ok {
+		t.Errorf("Store(...): want no state of unregistered stores")
+	}
+}
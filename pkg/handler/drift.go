@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+const (
+	forProviderPath = "spec.forProvider"
+	atProviderPath  = "status.atProvider"
+)
+
+// driftedFields returns the number of fields of spec.forProvider whose value
+// differs from status.atProvider, comparing the given field paths relative to
+// both, or all top-level fields if empty. Fields missing on either side are
+// skipped, as providers don't observe every parameter. Objects without
+// spec.forProvider or status.atProvider return false.
+func driftedFields(o *object, fields []string) (int, bool) {
+	forProvider, err := o.paved.GetValue(forProviderPath)
+	if err != nil {
+		return 0, false
+	}
+	if _, err := o.paved.GetValue(atProviderPath); err != nil {
+		return 0, false
+	}
+	if len(fields) == 0 {
+		fields = comparedFields(forProvider)
+	}
+	var n int
+	for _, f := range fields {
+		spec, err := o.paved.GetValue(fieldPath(forProviderPath, f))
+		if err != nil {
+			continue
+		}
+		observed, err := o.paved.GetValue(fieldPath(atProviderPath, f))
+		if err != nil {
+			continue
+		}
+		if hashValue(spec) != hashValue(observed) {
+			n++
+		}
+	}
+	return n, true
+}
+
+// comparedFields returns the sorted top-level fields of forProvider, except
+// references and selectors, which are resolved into other fields and never
+// observed.
+func comparedFields(forProvider any) []string {
+	m, ok := forProvider.(map[string]any)
+	if !ok {
+		return nil
+	}
+	fields := make([]string, 0, len(m))
+	for k := range m {
+		if strings.HasSuffix(k, "Ref") || strings.HasSuffix(k, "Refs") || strings.HasSuffix(k, "Selector") {
+			continue
+		}
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// fieldPath returns the path of field relative to base.
+func fieldPath(base, field string) string {
+	if strings.HasPrefix(field, "[") {
+		return base + field
+	}
+	return base + "." + field
+}
+
+// hashValue returns a hash of the JSON encoding of v. Maps are encoded with
+// sorted keys and integers like floats of the same value, so that equal values
+// hash equally regardless of how they were decoded.
+func hashValue(v any) uint64 {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return h.Sum64()
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDriftedFields(t *testing.T) {
+	managed := func(forProvider, atProvider map[string]any) *object {
+		return newStoreObject(&unstructured.Unstructured{Object: map[string]any{
+			"spec":   map[string]any{"forProvider": forProvider},
+			"status": map[string]any{"atProvider": atProvider},
+		}}, newTransitions())
+	}
+
+	type want struct {
+		n  int
+		ok bool
+	}
+	cases := map[string]struct {
+		reason string
+		o      *object
+		fields []string
+		want   want
+	}{
+		"NoManagedResource": {
+			reason: "Should return false for objects without spec.forProvider.",
+			o:      newStoreObject(&unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{}}}, newTransitions()),
+		},
+		"NotObserved": {
+			reason: "Should return false for managed resources without status.atProvider.",
+			o: newStoreObject(&unstructured.Unstructured{Object: map[string]any{
+				"spec": map[string]any{"forProvider": map[string]any{"region": "eu-central-1"}},
+			}}, newTransitions()),
+		},
+		"InSync": {
+			reason: "Should not count fields of equal values, regardless of the encoding of numbers and the order of keys.",
+			o: managed(
+				map[string]any{"size": int64(10), "tags": map[string]any{"a": "1", "b": "2"}},
+				map[string]any{"size": float64(10), "tags": map[string]any{"b": "2", "a": "1"}, "arn": "arn:aws:rds"},
+			),
+			want: want{ok: true},
+		},
+		"Drifted": {
+			reason: "Should count the fields whose values differ.",
+			o: managed(
+				map[string]any{"size": int64(10), "instanceClass": "db.t3.micro", "tags": map[string]any{"a": "1"}},
+				map[string]any{"size": int64(20), "instanceClass": "db.t3.small", "tags": map[string]any{"a": "1"}},
+			),
+			want: want{n: 2, ok: true},
+		},
+		"SkipUnobservedAndReferences": {
+			reason: "Should skip fields missing in status.atProvider, references and selectors.",
+			o: managed(
+				map[string]any{"password": "secret", "vpcId": "vpc-1", "vpcIdRef": map[string]any{"name": "a"}, "vpcIdSelector": map[string]any{}},
+				map[string]any{"vpcId": "vpc-1", "vpcIdRef": map[string]any{"name": "b"}, "vpcIdSelector": map[string]any{"matchLabels": map[string]any{}}},
+			),
+			want: want{ok: true},
+		},
+		"Fields": {
+			reason: "Should only compare the given field paths.",
+			o: managed(
+				map[string]any{"size": int64(10), "settings": []any{map[string]any{"tier": "small"}}},
+				map[string]any{"size": int64(20), "settings": []any{map[string]any{"tier": "large"}}},
+			),
+			fields: []string{"settings[0].tier", "missing"},
+			want:   want{n: 1, ok: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n, ok := driftedFields(tc.o, tc.fields)
+			if diff := cmp.Diff(tc.want, want{n: n, ok: ok}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ndriftedFields(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
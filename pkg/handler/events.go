@@ -0,0 +1,348 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var eventsGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// eventFamily counts the Warning events of objects. It is generated on scrape
+// from the events watched for the stores of a registration.
+var eventFamily = family{"_warning_events_total", "Number of Warning events of the object by reason"}
+
+// eventCounter counts the Warning events of the objects of a resource. The
+// count of an event grows when it recurs, so the increase of each event is
+// added to the count of its object and reason. The counts are kept when the
+// events expire, and start with the counts of the events existing when the
+// store is registered.
+type eventCounter struct {
+	gvr  schema.GroupVersionResource
+	kind string
+
+	mu sync.Mutex
+	// seen holds the last count of each event
+	seen map[types.UID]seenEvent
+	// counts holds the counts of each object by reason
+	counts map[types.UID]map[string]int64
+}
+
+// seenEvent is the last count of an event and the UID of its object.
+type seenEvent struct {
+	object types.UID
+	count  int64
+}
+
+func newEventCounter(gvr schema.GroupVersionResource, kind string) *eventCounter {
+	return &eventCounter{gvr: gvr, kind: kind, seen: map[types.UID]seenEvent{}, counts: map[types.UID]map[string]int64{}}
+}
+
+// observe adds the increase of the count of an event.
+func (c *eventCounter) observe(e *unstructured.Unstructured) {
+	apiVersion, _, _ := unstructured.NestedString(e.Object, "involvedObject", "apiVersion")
+	if gv, err := schema.ParseGroupVersion(apiVersion); err != nil || gv.Group != c.gvr.Group {
+		return
+	}
+	uid, _, _ := unstructured.NestedString(e.Object, "involvedObject", "uid")
+	reason, _, _ := unstructured.NestedString(e.Object, "reason")
+	count := eventCount(e)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last := c.seen[e.GetUID()].count
+	if count <= last {
+		return
+	}
+	reasons, ok := c.counts[types.UID(uid)]
+	if !ok {
+		reasons = map[string]int64{}
+		c.counts[types.UID(uid)] = reasons
+	}
+	reasons[reason] += count - last
+	c.seen[e.GetUID()] = seenEvent{object: types.UID(uid), count: count}
+}
+
+// eventCount returns the number of occurrences of an event, which are counted
+// by series.count or the deprecated count.
+func eventCount(e *unstructured.Unstructured) int64 {
+	if n, ok, _ := unstructured.NestedInt64(e.Object, "series", "count"); ok && n > 0 {
+		return n
+	}
+	if n, ok, _ := unstructured.NestedInt64(e.Object, "count"); ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// expire removes an expired event. The counts of its object are kept.
+func (c *eventCounter) expire(uid types.UID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, uid)
+}
+
+// forget removes the counts and the events of a deleted object.
+func (c *eventCounter) forget(uid types.UID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counts, uid)
+	for e, seen := range c.seen {
+		if seen.object == uid {
+			delete(c.seen, e)
+		}
+	}
+}
+
+// reasons returns the reasons of the events of an object, sorted, and their
+// counts.
+func (c *eventCounter) reasons(uid types.UID) ([]string, map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int64, len(c.counts[uid]))
+	reasons := make([]string, 0, len(c.counts[uid]))
+	for r, n := range c.counts[uid] {
+		counts[r] = n
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	return reasons, counts
+}
+
+// eventInformerKey identifies the Warning events watched by a shared event
+// informer. Events are selected by the kind of their object if it is known,
+// the group of their object is checked by the counters.
+type eventInformerKey struct {
+	cluster   string
+	namespace string
+	kind      string
+}
+
+// fieldSelector selects the Warning events of the key.
+func (k eventInformerKey) fieldSelector() string {
+	if k.kind == "" {
+		return "type=Warning"
+	}
+	return "type=Warning,involvedObject.kind=" + k.kind
+}
+
+// eventInformers shares a reflector of Warning events among the event
+// counters of the same kind, so that the events are listed and watched once
+// instead of once per store.
+type eventInformers struct {
+	mu        sync.Mutex
+	informers map[eventInformerKey]*eventInformer
+}
+
+func newEventInformers() *eventInformers {
+	return &eventInformers{informers: map[eventInformerKey]*eventInformer{}}
+}
+
+// subscribe adds c to the informer of key, which is started with client if
+// it isn't running yet.
+// +kubebuilder:rbac:groups="",resources=events,verbs=list;watch
+func (i *eventInformers) subscribe(ctx context.Context, client dynamic.Interface, key eventInformerKey, c *eventCounter) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	inf, ok := i.informers[key]
+	if !ok {
+		ictx, cancel := context.WithCancel(detach(ctx))
+		inf = newEventInformer(cancel)
+		i.informers[key] = inf
+		go func() {
+			lw := newListWatch(ictx, client.Resource(eventsGVR).Namespace(key.namespace), listWatchOptions{fieldSelector: key.fieldSelector()})
+			re := cache.NewReflector(lw, &unstructured.Unstructured{}, inf, 0)
+			runReflector(log.IntoContext(ictx, log.FromContext(ictx).WithName("events")), re, eventsGVR, 0, nil)
+		}()
+	}
+	inf.subscribe(c)
+}
+
+// unsubscribe removes c from the informer of key, stopping the informer if
+// it was its last counter.
+func (i *eventInformers) unsubscribe(key eventInformerKey, c *eventCounter) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	inf, ok := i.informers[key]
+	if !ok || inf.unsubscribe(c) > 0 {
+		return
+	}
+	inf.cancel()
+	delete(i.informers, key)
+}
+
+// eventInformer is the store of a shared reflector of events. It holds the
+// events listed and watched by the reflector and feeds each change to the
+// counters subscribed to it.
+type eventInformer struct {
+	cache.Store
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	counters map[*eventCounter]bool
+}
+
+func newEventInformer(cancel context.CancelFunc) *eventInformer {
+	return &eventInformer{
+		Store:    cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc),
+		cancel:   cancel,
+		counters: map[*eventCounter]bool{},
+	}
+}
+
+// subscribe adds c, starting with the counts of the events listed already.
+func (i *eventInformer) subscribe(c *eventCounter) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.counters[c] = true
+	for _, obj := range i.Store.List() {
+		if e, ok := obj.(*unstructured.Unstructured); ok {
+			c.observe(e)
+		}
+	}
+}
+
+// unsubscribe removes c and returns the number of remaining counters.
+func (i *eventInformer) unsubscribe(c *eventCounter) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.counters, c)
+	return len(i.counters)
+}
+
+func (i *eventInformer) Add(obj any) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.observe(obj)
+	return i.Store.Add(obj)
+}
+
+func (i *eventInformer) Update(obj any) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.observe(obj)
+	return i.Store.Update(obj)
+}
+
+func (i *eventInformer) Delete(obj any) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if e, ok := obj.(*unstructured.Unstructured); ok {
+		for c := range i.counters {
+			c.expire(e.GetUID())
+		}
+	}
+	return i.Store.Delete(obj)
+}
+
+func (i *eventInformer) Replace(list []any, resourceVersion string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, obj := range list {
+		i.observe(obj)
+	}
+	return i.Store.Replace(list, resourceVersion)
+}
+
+// observe feeds an event to the counters. i.mu must be held.
+func (i *eventInformer) observe(obj any) {
+	e, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	for c := range i.counters {
+		c.observe(e)
+	}
+}
+
+// eventWriter writes the event family of the stores of a registration.
+// Nothing is written if no store counts events.
+type eventWriter struct {
+	stores []*instrumentedStore
+}
+
+func (e eventWriter) WriteAll(w io.Writer) {
+	var header string
+	f := metric.Family{}
+	for _, s := range e.stores {
+		if s.events == nil {
+			continue
+		}
+		header = s.events.header
+		f.Name = s.events.family
+		s.statuses(func(uid types.UID, o objectStatus) {
+			reasons, counts := s.events.counter.reasons(uid)
+			for _, reason := range reasons {
+				keys := appendLabels(s.events.labels.keys(), "reason")
+				values := appendLabels(s.events.labels.values(o.name, o.namespace, uid), reason)
+				if m := s.events.relabel.series(f.Name, keys, values, float64(counts[reason])); m != nil {
+					f.Metrics = append(f.Metrics, m)
+				}
+			}
+		})
+	}
+	if header == "" {
+		return
+	}
+	_, _ = io.WriteString(w, header+"\n")
+	_, _ = w.Write(f.ByteSlice())
+}
+
+// storeEvents holds the event counter of a store and its event family.
+type storeEvents struct {
+	counter *eventCounter
+	family  string
+	header  string
+	labels  objectLabels
+	relabel relabeling
+}
+
+// newStoreEvents returns the event counter of a store, nil if the resource
+// doesn't count events or the event family is disabled.
+func newStoreEvents(family string, labels objectLabels, gvr schema.GroupVersionResource, resourceConfig ResourceConfig) *storeEvents {
+	if !pointer.BoolDeref(resourceConfig.WarningEvents, false) {
+		return nil
+	}
+	for _, f := range resourceConfig.DisabledFamilies {
+		if f == eventFamily.key() {
+			return nil
+		}
+	}
+	help := eventFamily.help
+	if h, ok := resourceConfig.Help[eventFamily.key()]; ok {
+		help = h
+	}
+	return &storeEvents{
+		counter: newEventCounter(gvr, resourceConfig.Kind),
+		family:  family + eventFamily.suffix,
+		header:  eventFamily.header(family, resourceConfig.expandHelp(help)),
+		labels:  labels,
+		relabel: newRelabeling(resourceConfig.RelabelConfigs),
+	}
+}
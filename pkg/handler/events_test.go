@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/utils/pointer"
+)
+
+func TestWarningEvents(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	newEvent := func(uid, apiVersion, reason string, count int64) *unstructured.Unstructured {
+		e := &unstructured.Unstructured{Object: map[string]any{
+			"involvedObject": map[string]any{"apiVersion": apiVersion, "kind": "Instance", "name": "obj", "uid": "uid"},
+			"reason":         reason,
+			"type":           "Warning",
+			"count":          count,
+		}}
+		e.SetUID(types.UID(uid))
+		return e
+	}
+	type update struct {
+		event   *unstructured.Unstructured
+		deleted bool
+	}
+
+	cases := map[string]struct {
+		reason   string
+		updates  []update
+		disabled []string
+		relabel  []RelabelConfig
+		want     []string
+	}{
+		"Recurring": {
+			reason: "Should add the increase of the count of recurring events.",
+			updates: []update{
+				{event: newEvent("e1", "rds.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 1)},
+				{event: newEvent("e1", "rds.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 3)},
+				{event: newEvent("e2", "rds.aws.upbound.io/v1beta1", "CannotObserveExternalResource", 1)},
+			},
+			want: []string{
+				`test_warning_events_total{name="obj",reason="CannotCreateExternalResource"} 3`,
+				`test_warning_events_total{name="obj",reason="CannotObserveExternalResource"} 1`,
+			},
+		},
+		"Expired": {
+			reason: "Should keep the counts of expired events and count recreated events again.",
+			updates: []update{
+				{event: newEvent("e1", "rds.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 2)},
+				{event: newEvent("e1", "rds.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 2), deleted: true},
+				{event: newEvent("e3", "rds.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 1)},
+			},
+			want: []string{
+				`test_warning_events_total{name="obj",reason="CannotCreateExternalResource"} 3`,
+			},
+		},
+		"OtherGroup": {
+			reason: "Should ignore events of objects of other groups.",
+			updates: []update{
+				{event: newEvent("e1", "ec2.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 1)},
+			},
+		},
+		"Relabeled": {
+			reason:  "Should apply the relabel configs of the resource to the event family.",
+			relabel: []RelabelConfig{{SourceLabels: []string{"reason"}, Regex: "CannotObserve.*", Action: RelabelDrop}},
+			updates: []update{
+				{event: newEvent("e1", "rds.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 1)},
+				{event: newEvent("e2", "rds.aws.upbound.io/v1beta1", "CannotObserveExternalResource", 1)},
+			},
+			want: []string{
+				`test_warning_events_total{name="obj",reason="CannotCreateExternalResource"} 1`,
+			},
+		},
+		"Disabled": {
+			reason:   "Should not count events if the family is disabled.",
+			disabled: []string{"warning_events_total"},
+			updates: []update{
+				{event: newEvent("e1", "rds.aws.upbound.io/v1beta1", "CannotCreateExternalResource", 1)},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := ResourceConfig{Group: gvr.Group, Resource: gvr.Resource, Kind: "Instance", ResourceOptions: ResourceOptions{WarningEvents: pointer.Bool(true), DisabledFamilies: tc.disabled, RelabelConfigs: tc.relabel}}
+			s := newInstrumentedStore(newMetricsStore("test", "", "", config, nil, nil), gvr)
+			s.events = newStoreEvents("test", objectLabels{}, gvr, config)
+			_ = s.Add(newObject(map[string]any{}))
+			if s.events != nil {
+				es := newEventInformer(func() {})
+				es.subscribe(s.events.counter)
+				for _, u := range tc.updates {
+					if u.deleted {
+						_ = es.Delete(u.event)
+						continue
+					}
+					_ = es.Update(u.event)
+				}
+			}
+
+			buf := &bytes.Buffer{}
+			eventWriter{stores: []*instrumentedStore{s}}.WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(line, "test_warning_events_total{") {
+					got = append(got, line)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_warning_events_total: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestEventInformers(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{eventsGVR: "EventList"})
+	key := eventInformerKey{kind: "Instance"}
+	a, b := newEventCounter(gvr, "Instance"), newEventCounter(gvr, "Instance")
+
+	i := newEventInformers()
+	i.subscribe(context.Background(), dc, key, a)
+	i.subscribe(context.Background(), dc, key, b)
+	if got := len(i.informers); got != 1 {
+		t.Errorf("subscribe(...): want counters of the same kind sharing 1 informer, got %d", got)
+	}
+	i.unsubscribe(key, a)
+	if got := len(i.informers); got != 1 {
+		t.Errorf("unsubscribe(...): want informer kept for the remaining counter, got %d informers", got)
+	}
+	i.unsubscribe(key, b)
+	if got := len(i.informers); got != 0 {
+		t.Errorf("unsubscribe(...): want informer stopped after the last counter, got %d informers", got)
+	}
+}
+
+func TestEventInformer(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	event := &unstructured.Unstructured{Object: map[string]any{
+		"involvedObject": map[string]any{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance", "name": "obj", "uid": "uid"},
+		"reason":         "CannotCreateExternalResource",
+		"type":           "Warning",
+		"count":          int64(2),
+	}}
+	event.SetName("e1")
+	event.SetUID("e1")
+
+	inf := newEventInformer(func() {})
+	_ = inf.Add(event)
+	c := newEventCounter(gvr, "Instance")
+	inf.subscribe(c)
+	_, counts := c.reasons("uid")
+	if diff := cmp.Diff(map[string]int64{"CannotCreateExternalResource": 2}, counts); diff != "" {
+		t.Errorf("subscribe(...): want counts of the events listed already, -want, +got:\n%s", diff)
+	}
+
+	c.forget("uid")
+	if got := len(c.seen); got != 0 {
+		t.Errorf("forget(...): want events of the deleted object removed, got %d", got)
+	}
+}
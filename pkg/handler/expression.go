@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+)
+
+// Expressions are Common Expression Language (CEL) expressions evaluated by
+// cel-go with its standard library. The top level fields of the object, like
+// metadata, spec and status, are available as variables of dynamic type, and
+// self refers to the whole object.
+
+// expressionEnv is the CEL environment of all expressions.
+var expressionEnv = func() *cel.Env {
+	env, err := cel.NewEnv()
+	if err != nil {
+		panic(fmt.Sprintf("cannot create CEL environment: %v", err))
+	}
+	return env
+}()
+
+// Expression is a compiled expression.
+type Expression struct {
+	source  string
+	program cel.Program
+}
+
+// CompileExpression parses source into an Expression. The expression is not
+// type checked, as the fields of objects are only known when evaluated.
+func CompileExpression(source string) (*Expression, error) {
+	ast, issues := expressionEnv.Parse(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("cannot compile expression %q: %w", source, issues.Err())
+	}
+	program, err := expressionEnv.Program(ast, cel.EvalOptions(cel.OptOptimize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile expression %q: %w", source, err)
+	}
+	return &Expression{source: source, program: program}, nil
+}
+
+// Eval evaluates the expression against obj. Lists and maps are returned as
+// []any and map[string]any.
+func (e *Expression) Eval(obj map[string]any) (any, error) {
+	v, _, err := e.program.Eval(objectActivation(obj))
+	if err != nil {
+		return nil, err
+	}
+	switch v.Type() {
+	case types.ListType:
+		return v.ConvertToNative(reflect.TypeOf([]any{}))
+	case types.MapType:
+		return v.ConvertToNative(reflect.TypeOf(map[string]any{}))
+	}
+	return v.Value(), nil
+}
+
+// EvalFloat evaluates the expression against obj and returns its value as
+// metric value. Booleans map to 1 and 0. Errors, null and non-numeric results
+// return false.
+func (e *Expression) EvalFloat(obj map[string]any) (float64, bool) {
+	v, _, err := e.program.Eval(objectActivation(obj))
+	if err != nil {
+		return 0, false
+	}
+	switch t := v.(type) {
+	case types.Int:
+		return float64(t), true
+	case types.Uint:
+		return float64(t), true
+	case types.Double:
+		return float64(t), true
+	case types.Bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// objectActivation resolves the variables of an expression to the top level
+// fields of an object, and self to the object.
+type objectActivation map[string]any
+
+func (a objectActivation) ResolveName(name string) (any, bool) {
+	if name == "self" {
+		return map[string]any(a), true
+	}
+	v, ok := a[name]
+	return v, ok
+}
+
+func (a objectActivation) Parent() interpreter.Activation {
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpressionEval(t *testing.T) {
+	obj := map[string]any{
+		"metadata": map[string]any{
+			"name":        "db",
+			"labels":      map[string]any{"team": "platform"},
+			"annotations": map[string]any{"description": "Größe" + "n"},
+		},
+		"spec": map[string]any{
+			"forProvider": map[string]any{"size": int64(20), "zones": []any{"a", "b"}},
+			"versions": []any{
+				map[string]any{"name": "v1alpha1", "served": false},
+				map[string]any{"name": "v1", "served": true},
+			},
+		},
+		"status": map[string]any{
+			"atProvider": map[string]any{"endpoint": "db.example.org", "load": 0.5},
+		},
+	}
+
+	cases := map[string]struct {
+		reason     string
+		expression string
+		want       any
+		wantErr    bool
+	}{
+		"Has": {
+			reason:     "has() should test for the presence of a field.",
+			expression: "has(status.atProvider.endpoint) ? 1 : 0",
+			want:       int64(1),
+		},
+		"HasMissing": {
+			reason:     "has() should return false for missing fields.",
+			expression: "has(status.atProvider.address)",
+			want:       false,
+		},
+		"Arithmetic": {
+			reason:     "Should respect operator precedence.",
+			expression: "spec.forProvider.size + 2 * 3",
+			want:       int64(26),
+		},
+		"MixedNumbers": {
+			reason:     "Should compare integers and doubles.",
+			expression: "status.atProvider.load < 1 && spec.forProvider.size >= 20",
+			want:       true,
+		},
+		"MixedArithmetic": {
+			reason:     "Should fail on arithmetic of integers and doubles, as CEL has no such overloads.",
+			expression: "spec.forProvider.size * status.atProvider.load",
+			wantErr:    true,
+		},
+		"Conversion": {
+			reason:     "Should compute with numbers converted explicitly.",
+			expression: "double(spec.forProvider.size) * status.atProvider.load",
+			want:       10.0,
+		},
+		"IntegerDivisionByZero": {
+			reason:     "Should fail on integer division by zero.",
+			expression: "spec.forProvider.size / 0",
+			wantErr:    true,
+		},
+		"Unicode": {
+			reason:     "Should count the code points of strings.",
+			expression: `metadata.annotations["description"].size() == 6 && metadata.annotations["description"].startsWith("Größ")`,
+			want:       true,
+		},
+		"Index": {
+			reason:     "Should index lists and maps.",
+			expression: `spec.forProvider.zones[1] == "b" && metadata.labels["team"] == 'platform'`,
+			want:       true,
+		},
+		"Functions": {
+			reason:     "Should support size and string functions.",
+			expression: `size(spec.forProvider.zones) == 2 && status.atProvider.endpoint.endsWith(".org")`,
+			want:       true,
+		},
+		"In": {
+			reason:     "Should test list membership.",
+			expression: `"c" in spec.forProvider.zones`,
+			want:       false,
+		},
+		"Self": {
+			reason:     "self should refer to the whole object.",
+			expression: "self.metadata.name",
+			want:       "db",
+		},
+		"AbsorbError": {
+			reason:     "|| should absorb errors if the other side is true.",
+			expression: "status.missing.field || true",
+			want:       true,
+		},
+		"Filter": {
+			reason:     "filter() should keep the elements matching the predicate.",
+			expression: "spec.versions.filter(v, v.served).size()",
+			want:       int64(1),
+		},
+		"ExistsAll": {
+			reason:     "exists() and all() should test the elements of a list.",
+			expression: `spec.versions.exists(v, v.name == "v1") && !spec.versions.all(v, v.served)`,
+			want:       true,
+		},
+		"Map": {
+			reason:     "map() should transform the elements of a list.",
+			expression: "spec.forProvider.zones.map(z, z + '1')",
+			want:       []any{"a1", "b1"},
+		},
+		"NoSuchKey": {
+			reason:     "Should fail on missing fields.",
+			expression: "status.missing",
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e, err := CompileExpression(tc.expression)
+			if err != nil {
+				t.Fatalf("CompileExpression(...): %v", err)
+			}
+			got, err := e.Eval(obj)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("\n%s\nEval(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nEval(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCompileExpression(t *testing.T) {
+	cases := map[string]struct {
+		reason     string
+		expression string
+	}{
+		"Unbalanced": {
+			reason:     "Should reject unbalanced parentheses.",
+			expression: "(1 + 2",
+		},
+		"Trailing": {
+			reason:     "Should reject trailing tokens.",
+			expression: "1 2",
+		},
+		"HasWithoutSelection": {
+			reason:     "has() should require a field selection.",
+			expression: "has(status)",
+		},
+		"UnterminatedString": {
+			reason:     "Should reject unterminated strings.",
+			expression: `"abc`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := CompileExpression(tc.expression); err == nil {
+				t.Errorf("\n%s\nCompileExpression(%q): expected error", tc.reason, tc.expression)
+			}
+		})
+	}
+}
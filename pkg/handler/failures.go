@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// failureEventRetries is the number of consecutive failed lists and watches
+// of a store after which a failure event is emitted.
+const failureEventRetries = 3
+
+// Reasons of the events emitted on store failures
+const (
+	ReasonListWatchFailed    = "ListWatchFailed"
+	ReasonMissingPermissions = "MissingPermissions"
+)
+
+type eventObjectKey struct{}
+
+// WithEventObject returns a context registering stores whose failures are
+// emitted as events on obj, e.g. the XMetricConfig configuring them.
+func WithEventObject(ctx context.Context, obj runtime.Object) context.Context {
+	return context.WithValue(ctx, eventObjectKey{}, obj)
+}
+
+// failureEvents emits the failures of a store as Kubernetes events.
+type failureEvents struct {
+	recorder record.EventRecorder
+	object   runtime.Object
+	// store describes the failing store in the messages of the events
+	store string
+}
+
+type failureEventsKey struct{}
+
+// withFailureEvents returns a context emitting the failures of the store of
+// metricName in namespace and cluster on the object of WithEventObject, or
+// on m.EventObject. Failures are not emitted without m.EventRecorder.
+func (m *ManagedMetricsHandler) withFailureEvents(ctx context.Context, metricName, namespace, cluster string) context.Context {
+	obj, _ := ctx.Value(eventObjectKey{}).(runtime.Object)
+	if obj == nil {
+		obj = m.EventObject
+	}
+	if m.EventRecorder == nil || obj == nil {
+		return ctx
+	}
+	store := fmt.Sprintf("store %s", metricName)
+	if namespace != "" {
+		store += fmt.Sprintf(" in namespace %s", namespace)
+	}
+	if cluster != "" {
+		store += fmt.Sprintf(" of cluster %s", cluster)
+	}
+	return context.WithValue(ctx, failureEventsKey{}, &failureEvents{recorder: m.EventRecorder, object: obj, store: store})
+}
+
+// warn emits a Warning event of the store of ctx if it emits failure events.
+func warn(ctx context.Context, reason, format string, args ...any) {
+	e, ok := ctx.Value(failureEventsKey{}).(*failureEvents)
+	if !ok {
+		return
+	}
+	e.recorder.Event(e.object, corev1.EventTypeWarning, reason, e.store+": "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// recordedEvent is an event emitted on the object of the given name.
+type recordedEvent struct {
+	object  string
+	reason  string
+	message string
+}
+
+// eventRecorder records the events emitted on objects.
+type eventRecorder struct {
+	events []recordedEvent
+}
+
+func (r *eventRecorder) Event(obj runtime.Object, _, reason, message string) {
+	r.events = append(r.events, recordedEvent{object: obj.(metav1.Object).GetName(), reason: reason, message: message})
+}
+
+func (r *eventRecorder) Eventf(obj runtime.Object, eventtype, reason, messageFmt string, args ...any) {
+	r.Event(obj, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *eventRecorder) AnnotatedEventf(obj runtime.Object, _ map[string]string, eventtype, reason, messageFmt string, args ...any) {
+	r.Eventf(obj, eventtype, reason, messageFmt, args...)
+}
+
+func TestFailureEvents(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "x-metrics-0", Namespace: "x-metrics"}}
+	config := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config"}}
+	message := "store instances in namespace team-a: missing permissions to watch rds.aws.upbound.io/v1beta1, Resource=instances, waiting for them to be granted"
+
+	cases := map[string]struct {
+		reason      string
+		recorder    bool
+		eventObject runtime.Object
+		ctx         context.Context
+		want        []recordedEvent
+	}{
+		"NoRecorder": {
+			reason:      "Should not emit events without recorder.",
+			eventObject: pod,
+			ctx:         context.Background(),
+		},
+		"NoObject": {
+			reason:   "Should not emit events without an object to emit them on.",
+			recorder: true,
+			ctx:      context.Background(),
+		},
+		"EventObject": {
+			reason:      "Should emit the failures of stores on the event object of the handler.",
+			recorder:    true,
+			eventObject: pod,
+			ctx:         context.Background(),
+			want:        []recordedEvent{{object: "x-metrics-0", reason: ReasonMissingPermissions, message: message}},
+		},
+		"RegistrationObject": {
+			reason:      "Should emit the failures of stores on the object of their registration.",
+			recorder:    true,
+			eventObject: pod,
+			ctx:         WithEventObject(context.Background(), config),
+			want:        []recordedEvent{{object: "config", reason: ReasonMissingPermissions, message: message}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &eventRecorder{}
+			m := NewManagedMetricsHandler(nil, Config{})
+			if tc.recorder {
+				m.EventRecorder = r
+			}
+			m.EventObject = tc.eventObject
+
+			ctx, cancel := context.WithCancel(m.withFailureEvents(tc.ctx, "instances", "team-a", ""))
+			cancel()
+			waitForPermissions(ctx, reviewingClient(map[string]bool{"list": true}, nil), gvr, "team-a", nil)
+			if diff := cmp.Diff(tc.want, r.events, cmp.AllowUnexported(recordedEvent{})); diff != "" {
+				t.Errorf("\n%s\nwaitForPermissions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
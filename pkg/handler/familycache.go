@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+// familyCache holds the rendered families of the objects of a store by UID and
+// resourceVersion, so that updates and relists of unchanged objects don't
+// regenerate their families.
+type familyCache struct {
+	// shed are the indexes of the families dropped while shedding
+	shed []int
+
+	mu       sync.Mutex
+	objects  map[types.UID]cachedFamilies
+	shedding bool
+}
+
+type cachedFamilies struct {
+	resourceVersion string
+	families        []metric.FamilyInterface
+}
+
+func newFamilyCache() *familyCache {
+	return &familyCache{objects: map[types.UID]cachedFamilies{}}
+}
+
+// renderedFamily is a family rendered by its ByteSlice.
+type renderedFamily []byte
+
+func (f renderedFamily) Inspect(func(metric.Family)) {}
+
+func (f renderedFamily) ByteSlice() []byte {
+	return f
+}
+
+// generate wraps fn to return the cached families of an object unless its
+// resourceVersion changed. Objects without resourceVersion are not cached.
+func (c *familyCache) generate(fn func(any) []metric.FamilyInterface) func(any) []metric.FamilyInterface {
+	return func(obj any) []metric.FamilyInterface {
+		o, err := meta.Accessor(obj)
+		if err != nil || o.GetResourceVersion() == "" {
+			return c.drop(fn(obj))
+		}
+		c.mu.Lock()
+		cached, ok := c.objects[o.GetUID()]
+		c.mu.Unlock()
+		if ok && cached.resourceVersion == o.GetResourceVersion() {
+			return cached.families
+		}
+		generated := c.drop(fn(obj))
+		families := make([]metric.FamilyInterface, len(generated))
+		for i, f := range generated {
+			families[i] = renderedFamily(f.ByteSlice())
+		}
+		c.mu.Lock()
+		c.objects[o.GetUID()] = cachedFamilies{resourceVersion: o.GetResourceVersion(), families: families}
+		c.mu.Unlock()
+		return families
+	}
+}
+
+// drop empties the shed families while shedding.
+func (c *familyCache) drop(families []metric.FamilyInterface) []metric.FamilyInterface {
+	c.mu.Lock()
+	shedding := c.shedding
+	c.mu.Unlock()
+	if !shedding {
+		return families
+	}
+	for _, i := range c.shed {
+		if i < len(families) {
+			families[i] = renderedFamily(nil)
+		}
+	}
+	return families
+}
+
+// setShedding starts or stops dropping the shed families. The cached families
+// are removed on change, so that they are generated again. It returns true if
+// the families of the objects have to be generated again.
+func (c *familyCache) setShedding(shedding bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shedding == shedding || len(c.shed) == 0 {
+		return false
+	}
+	c.shedding = shedding
+	c.objects = map[types.UID]cachedFamilies{}
+	return true
+}
+
+// forget removes the families of a deleted object.
+func (c *familyCache) forget(uid types.UID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.objects, uid)
+}
+
+// retain removes the families of all objects not in uids, e.g. after a relist.
+func (c *familyCache) retain(uids map[types.UID]objectStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for uid := range c.objects {
+		if _, ok := uids[uid]; !ok {
+			delete(c.objects, uid)
+		}
+	}
+}
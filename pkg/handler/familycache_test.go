@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+func TestFamilyCache(t *testing.T) {
+	newObject := func(uid, resourceVersion string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{}}
+		u.SetUID(types.UID(uid))
+		u.SetResourceVersion(resourceVersion)
+		return u
+	}
+
+	cases := map[string]struct {
+		reason string
+		ops    func(c *familyCache, generate func(any) []metric.FamilyInterface)
+		want   int
+	}{
+		"Unchanged": {
+			reason: "Should not regenerate the families of an object with the same resourceVersion.",
+			ops: func(c *familyCache, generate func(any) []metric.FamilyInterface) {
+				generate(newObject("a", "1"))
+				generate(newObject("a", "1"))
+			},
+			want: 1,
+		},
+		"Changed": {
+			reason: "Should regenerate the families of an object with a new resourceVersion.",
+			ops: func(c *familyCache, generate func(any) []metric.FamilyInterface) {
+				generate(newObject("a", "1"))
+				generate(newObject("a", "2"))
+				generate(newObject("b", "2"))
+			},
+			want: 3,
+		},
+		"NoResourceVersion": {
+			reason: "Should always generate the families of objects without resourceVersion.",
+			ops: func(c *familyCache, generate func(any) []metric.FamilyInterface) {
+				generate(newObject("a", ""))
+				generate(newObject("a", ""))
+			},
+			want: 2,
+		},
+		"Forget": {
+			reason: "Should regenerate the families of a forgotten object.",
+			ops: func(c *familyCache, generate func(any) []metric.FamilyInterface) {
+				generate(newObject("a", "1"))
+				c.forget("a")
+				generate(newObject("a", "1"))
+			},
+			want: 2,
+		},
+		"Retain": {
+			reason: "Should only keep the families of retained objects.",
+			ops: func(c *familyCache, generate func(any) []metric.FamilyInterface) {
+				generate(newObject("a", "1"))
+				generate(newObject("b", "1"))
+				c.retain(map[types.UID]objectStatus{"a": {}})
+				generate(newObject("a", "1"))
+				generate(newObject("b", "1"))
+			},
+			want: 3,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var generated int
+			c := newFamilyCache()
+			generate := c.generate(func(obj any) []metric.FamilyInterface {
+				generated++
+				return []metric.FamilyInterface{&metric.Family{Name: "test", Metrics: []*metric.Metric{{Value: 1}}}}
+			})
+			tc.ops(c, generate)
+			if diff := cmp.Diff(tc.want, generated); diff != "" {
+				t.Errorf("\n%s\ngenerate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
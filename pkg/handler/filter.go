@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// globMatch reports whether s matches pattern. A '*' in pattern matches any
+// sequence of characters, including '/' and '.', a '?' matches a single
+// character.
+func globMatch(pattern, s string) bool {
+	px, sx := 0, 0
+	// position to restart from after the last '*' if the current attempt fails
+	nextPx, nextSx := -1, -1
+	for px < len(pattern) || sx < len(s) {
+		if px < len(pattern) {
+			switch c := pattern[px]; c {
+			case '?':
+				if sx < len(s) {
+					px++
+					sx++
+					continue
+				}
+			case '*':
+				nextPx, nextSx = px, sx+1
+				px++
+				continue
+			default:
+				if sx < len(s) && s[sx] == c {
+					px++
+					sx++
+					continue
+				}
+			}
+		}
+		if nextSx > 0 && nextSx <= len(s) {
+			px, sx = nextPx, nextSx
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// matchesAny returns true if s matches one of the glob patterns.
+func matchesAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if globMatch(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns true if key matches the allowlist and does not match the
+// denylist. An empty allowlist allows all keys.
+func allowed(key string, allowlist, denylist []string) bool {
+	if len(allowlist) > 0 && !matchesAny(allowlist, key) {
+		return false
+	}
+	return !matchesAny(denylist, key)
+}
+
+// familyFilter selects the families written by a scrape by glob patterns of
+// family names.
+type familyFilter struct {
+	include []string
+	exclude []string
+}
+
+// parseFamilyFilter returns the filter given by the comma separated include
+// and exclude query parameters, which may be repeated.
+func parseFamilyFilter(q url.Values) familyFilter {
+	split := func(values []string) []string {
+		var patterns []string
+		for _, v := range values {
+			for _, p := range strings.Split(v, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					patterns = append(patterns, p)
+				}
+			}
+		}
+		return patterns
+	}
+	return familyFilter{include: split(q["include"]), exclude: split(q["exclude"])}
+}
+
+func (f familyFilter) empty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// writer returns a writer dropping the header and series of filtered
+// families written to it.
+func (f familyFilter) writer(w io.Writer) io.Writer {
+	if f.empty() {
+		return w
+	}
+	return &lineWriter{w: w, fn: (&familySelector{filter: f, keep: true}).selectLine}
+}
+
+// familySelector keeps the lines of the family of the last "# TYPE" line, if
+// it is selected by filter.
+type familySelector struct {
+	filter familyFilter
+	keep   bool
+}
+
+// selectLine returns line if its family is selected, else nil.
+func (fs *familySelector) selectLine(line []byte) []byte {
+	if name, ok := typeName(line); ok {
+		fs.keep = allowed(name, fs.filter.include, fs.filter.exclude)
+	}
+	if !fs.keep {
+		return nil
+	}
+	return line
+}
+
+// typeName returns the family name of a "# TYPE <name> <type>" line.
+func typeName(line []byte) (string, bool) {
+	rest, ok := bytes.CutPrefix(line, []byte("# TYPE "))
+	if !ok {
+		return "", false
+	}
+	if i := bytes.IndexByte(rest, ' '); i >= 0 {
+		rest = rest[:i]
+	}
+	return string(bytes.TrimSpace(rest)), true
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGlobMatch(t *testing.T) {
+	cases := map[string]struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		"Exact":            {pattern: "app", s: "app", want: true},
+		"ExactMismatch":    {pattern: "app", s: "apps", want: false},
+		"Wildcard":         {pattern: "*", s: "app.kubernetes.io/name", want: true},
+		"WildcardEmpty":    {pattern: "*", s: "", want: true},
+		"Prefix":           {pattern: "app.kubernetes.io/*", s: "app.kubernetes.io/name", want: true},
+		"PrefixMismatch":   {pattern: "app.kubernetes.io/*", s: "example.com/name", want: false},
+		"Suffix":           {pattern: "*-hash", s: "pod-template-hash", want: true},
+		"Infix":            {pattern: "*template*", s: "pod-template-hash", want: true},
+		"SingleCharacter":  {pattern: "team?", s: "team1", want: true},
+		"SingleCharacterX": {pattern: "team?", s: "team", want: false},
+		"Backtracking":     {pattern: "a*b*c", s: "aXbYbZc", want: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := globMatch(tc.pattern, tc.s); got != tc.want {
+				t.Errorf("globMatch(%q, %q): want %t, got %t", tc.pattern, tc.s, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFilteringWriter(t *testing.T) {
+	// Writes as done by MetricsStore.WriteAll
+	writes := []string{
+		"# TYPE foo_bar gauge\n# HELP foo_bar help", "\n", "foo_bar{name=\"a\"} 1\n",
+		"# TYPE foo_bar_ready gauge\n# HELP foo_bar_ready help", "\n", "foo_bar_ready{name=\"a\"} 1\n",
+		"# TYPE baz gauge\n# HELP baz help", "\n", "baz{name=", "\"a\"} 1\n",
+	}
+
+	cases := map[string]struct {
+		reason string
+		query  string
+		want   string
+	}{
+		"NoFilter": {
+			reason: "Should write all families without filter.",
+			want: "# TYPE foo_bar gauge\n# HELP foo_bar help\nfoo_bar{name=\"a\"} 1\n" +
+				"# TYPE foo_bar_ready gauge\n# HELP foo_bar_ready help\nfoo_bar_ready{name=\"a\"} 1\n" +
+				"# TYPE baz gauge\n# HELP baz help\nbaz{name=\"a\"} 1\n",
+		},
+		"Include": {
+			reason: "Should only write included families.",
+			query:  "include=foo_bar,baz",
+			want: "# TYPE foo_bar gauge\n# HELP foo_bar help\nfoo_bar{name=\"a\"} 1\n" +
+				"# TYPE baz gauge\n# HELP baz help\nbaz{name=\"a\"} 1\n",
+		},
+		"IncludePattern": {
+			reason: "Should match families by glob patterns.",
+			query:  "include=foo_*",
+			want: "# TYPE foo_bar gauge\n# HELP foo_bar help\nfoo_bar{name=\"a\"} 1\n" +
+				"# TYPE foo_bar_ready gauge\n# HELP foo_bar_ready help\nfoo_bar_ready{name=\"a\"} 1\n",
+		},
+		"Exclude": {
+			reason: "Should drop excluded families, also of included families.",
+			query:  "include=foo_*&exclude=*_ready",
+			want:   "# TYPE foo_bar gauge\n# HELP foo_bar help\nfoo_bar{name=\"a\"} 1\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			q, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			w := parseFamilyFilter(q).writer(buf)
+			for _, s := range writes {
+				_, _ = w.Write([]byte(s))
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("\n%s\nWrite(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/munnerz/goautoneg"
+)
+
+// expositionFormat is the Content-Type of a metrics response.
+type expositionFormat string
+
+const (
+	formatText              expositionFormat = "text/plain; version=0.0.4; charset=utf-8"
+	formatOpenMetrics_1_0_0 expositionFormat = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	formatOpenMetrics_0_0_1 expositionFormat = "application/openmetrics-text; version=0.0.1; charset=utf-8"
+
+	openMetricsType = "application/openmetrics-text"
+	// openMetricsEOF terminates every OpenMetrics exposition
+	openMetricsEOF = "# EOF\n"
+)
+
+// negotiateFormat returns the exposition format preferred by the Accept
+// header of a request. It falls back to the Prometheus text format.
+func negotiateFormat(h http.Header) expositionFormat {
+	for _, ac := range goautoneg.ParseAccept(h.Get("Accept")) {
+		ver := ac.Params["version"]
+		if ac.Type+"/"+ac.SubType == openMetricsType {
+			switch ver {
+			case "1.0.0", "":
+				return formatOpenMetrics_1_0_0
+			case "0.0.1":
+				return formatOpenMetrics_0_0_1
+			}
+		}
+		if ac.Type == "text" && ac.SubType == "plain" && (ver == "0.0.4" || ver == "") {
+			return formatText
+		}
+	}
+	return formatText
+}
+
+func (f expositionFormat) isOpenMetrics() bool {
+	return f == formatOpenMetrics_1_0_0 || f == formatOpenMetrics_0_0_1
+}
+
+// acceptsGzip returns true if the Accept-Encoding header of a request allows
+// gzip compressed responses.
+func acceptsGzip(h http.Header) bool {
+	for _, part := range strings.Split(h.Get("Accept-Encoding"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "gzip" || strings.HasPrefix(part, "gzip;") {
+			return true
+		}
+	}
+	return false
+}
+
+// openMetricsWriter returns a writer converting the Prometheus text format
+// written to it to OpenMetrics. The family names of counters omit the _total
+// suffix of their samples in OpenMetrics, so it is removed from the TYPE and
+// the following HELP line of counters.
+func openMetricsWriter(w io.Writer) io.Writer {
+	return &lineWriter{w: w, fn: (&omConverter{}).convert}
+}
+
+// omConverter renames the metadata of counters.
+type omConverter struct {
+	// counter is the name of the last counter family
+	counter []byte
+}
+
+var (
+	typePrefix  = []byte("# TYPE ")
+	helpPrefix  = []byte("# HELP ")
+	totalSuffix = []byte("_total")
+)
+
+// convert returns the metadata lines of counters without the _total suffix of
+// their family name. All other lines are returned unchanged.
+func (o *omConverter) convert(line []byte) []byte {
+	if name, rest, ok := metadataLine(line, typePrefix); ok {
+		if !bytes.HasSuffix(name, totalSuffix) || !bytes.Equal(rest, []byte("counter\n")) {
+			o.counter = o.counter[:0]
+			return line
+		}
+		o.counter = append(o.counter[:0], name...)
+		return renameFamily(line, typePrefix, name)
+	}
+	if name, _, ok := metadataLine(line, helpPrefix); ok && len(o.counter) > 0 && bytes.Equal(name, o.counter) {
+		return renameFamily(line, helpPrefix, name)
+	}
+	return line
+}
+
+// metadataLine returns the family name and the rest of a metadata line
+// starting with prefix.
+func metadataLine(line, prefix []byte) ([]byte, []byte, bool) {
+	if !bytes.HasPrefix(line, prefix) {
+		return nil, nil, false
+	}
+	name, rest, _ := bytes.Cut(line[len(prefix):], []byte(" "))
+	return name, rest, true
+}
+
+// renameFamily returns the metadata line of the family name with the _total
+// suffix removed from the name.
+func renameFamily(line, prefix, name []byte) []byte {
+	renamed := make([]byte, 0, len(line))
+	renamed = append(renamed, prefix...)
+	renamed = append(renamed, bytes.TrimSuffix(name, totalSuffix)...)
+	return append(renamed, line[len(prefix)+len(name):]...)
+}
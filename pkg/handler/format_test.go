@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		accept string
+		want   expositionFormat
+	}{
+		"NoHeader": {
+			reason: "Should fall back to the text format without Accept header.",
+			want:   formatText,
+		},
+		"Text": {
+			reason: "Should return the text format if requested.",
+			accept: "text/plain;version=0.0.4",
+			want:   formatText,
+		},
+		"OpenMetrics": {
+			reason: "Should prefer OpenMetrics 1.0.0 as sent by Prometheus.",
+			accept: "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			want:   formatOpenMetrics_1_0_0,
+		},
+		"OpenMetricsLegacy": {
+			reason: "Should support OpenMetrics 0.0.1.",
+			accept: "application/openmetrics-text;version=0.0.1",
+			want:   formatOpenMetrics_0_0_1,
+		},
+		"PreferText": {
+			reason: "Should respect the quality of the requested formats.",
+			accept: "application/openmetrics-text;q=0.5,text/plain",
+			want:   formatText,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := http.Header{}
+			if tc.accept != "" {
+				h.Set("Accept", tc.accept)
+			}
+			if diff := cmp.Diff(tc.want, negotiateFormat(h)); diff != "" {
+				t.Errorf("\n%s\nnegotiateFormat(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestServeHTTPOpenMetrics(t *testing.T) {
+	errorsTotal := NewFamilyGenerator("_errors_total", "Number of errors", func(*unstructured.Unstructured) []*metric.Metric {
+		return []*metric.Metric{{Value: 2}}
+	})
+	m := NewManagedMetricsHandler(nil, Config{Labels: map[string]string{"environment": "prod"}}, WithGenerators(errorsTotal))
+	store := newMetricsStore("test", "", "", ResourceConfig{}, nil, nil, errorsTotal)
+	if err := store.Add(newObject(map[string]any{
+		"status": map[string]any{"conditions": []any{map[string]any{"type": "Ready", "status": "True"}}},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	m.addMetricStore("test", func() {}, newInstrumentedStore(store, schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "tests"}))
+
+	req := httptest.NewRequest("GET", "/x-metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	lines := map[string]bool{}
+	for _, line := range strings.Split(body, "\n") {
+		lines[line] = true
+	}
+	want := []string{
+		"# TYPE test gauge",
+		"# TYPE test_ready_transitions counter",
+		"# TYPE test_errors counter",
+		"# HELP test_errors Number of errors",
+		`test_errors_total{environment="prod",name="obj"} 2`,
+		`test_ready{environment="prod",name="obj"} 1`,
+	}
+	for _, line := range want {
+		if !lines[line] {
+			t.Errorf("ServeHTTP(...): want line %q, response:\n%s", line, body)
+		}
+	}
+	if lines["# TYPE test_errors_total counter"] {
+		t.Errorf("ServeHTTP(...): want counter family without _total suffix, response:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("ServeHTTP(...): want response terminated by # EOF, response:\n%s", body)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+// FamilyGenerator generates a family of the objects of a resource in addition
+// to the built-in families.
+type FamilyGenerator interface {
+	// Family returns the suffix appended to the metric name of the store,
+	// e.g. _replicas, and the HELP text of the family. Families with a _total
+	// suffix are counters, all others gauges
+	Family() (suffix, help string)
+	// Generate returns the series of an object. The labels identifying the
+	// object, like its name, are prepended to their labels
+	Generate(obj *unstructured.Unstructured) []*metric.Metric
+}
+
+// NewFamilyGenerator returns a FamilyGenerator of the family with the given
+// suffix and HELP text, whose series are returned by generate.
+func NewFamilyGenerator(suffix, help string, generate func(obj *unstructured.Unstructured) []*metric.Metric) FamilyGenerator {
+	return funcGenerator{suffix: suffix, help: help, generate: generate}
+}
+
+type funcGenerator struct {
+	suffix, help string
+	generate     func(obj *unstructured.Unstructured) []*metric.Metric
+}
+
+func (g funcGenerator) Family() (string, string) {
+	return g.suffix, g.help
+}
+
+func (g funcGenerator) Generate(obj *unstructured.Unstructured) []*metric.Metric {
+	return g.generate(obj)
+}
+
+// generatorRegistry holds the generators of the families added to the stores
+// of resources by group and resource, those of all resources by the empty
+// group and resource.
+type generatorRegistry map[schema.GroupResource][]FamilyGenerator
+
+func (r generatorRegistry) register(gr schema.GroupResource, generators ...FamilyGenerator) {
+	r[gr] = append(r[gr], generators...)
+}
+
+// forResource returns the generators of the stores of gvr, those of all
+// resources first.
+func (r generatorRegistry) forResource(gvr schema.GroupVersionResource) []FamilyGenerator {
+	all := r[schema.GroupResource{}]
+	return append(all[:len(all):len(all)], r[gvr.GroupResource()]...)
+}
+
+// WithGenerators adds families generated by generators to the stores of all
+// resources.
+func WithGenerators(generators ...FamilyGenerator) Option {
+	return func(o *options) {
+		o.generators.register(schema.GroupResource{}, generators...)
+	}
+}
+
+// WithResourceGenerators adds families generated by generators to the stores
+// of the resource gr, in all of its versions.
+func WithResourceGenerators(gr schema.GroupResource, generators ...FamilyGenerator) Option {
+	return func(o *options) {
+		o.generators.register(gr, generators...)
+	}
+}
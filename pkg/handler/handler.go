@@ -17,254 +17,1076 @@ limitations under the License.
 package handler
 
 import (
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
 	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type IManagedMetricsHandler interface {
 	ServeHTTP(writer http.ResponseWriter, r *http.Request)
 	RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string) chan struct{}
+	RegisterAndAddMetricStore(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, config ResourceConfig) chan struct{}
 	RemoveMetricStore(name string)
+	Stop(name string)
+	Close()
 }
 
 type ManagedMetricsHandler struct {
-	metricsWriter map[string]*metricsstore.MetricsStore
+	// mu protects metricsWriter
+	mu            sync.RWMutex
+	metricsWriter map[string]*registeredStore
 	Client        dynamic.Interface
+	// MetadataClient is used for resources configured as metadata only
+	MetadataClient metadata.Interface
+	// Clusters are remote clusters whose objects are exported in addition to
+	// those of the local cluster, see Config.Clusters
+	Clusters []Cluster
+	// Sharding selects the objects exported by this instance
+	Sharding Sharding
+	// CheckPermissions reviews whether the reflectors of a store may list and
+	// watch their resource before starting them, instead of retrying failed
+	// lists until the permissions are granted
+	CheckPermissions bool
+	// ResponseCacheTTL is the time a rendered response is served to further
+	// scrapes. Responses are not cached if zero
+	ResponseCacheTTL time.Duration
+	// ListTimeout is the timeout of the list requests of the reflectors.
+	// Lists are not timed out if zero
+	ListTimeout time.Duration
+	// MaxInitialLists limits the concurrent initial lists of the reflectors,
+	// e.g. of all resources registered on startup. Unlimited if zero
+	MaxInitialLists int
+	// EventRecorder emits repeated failures of stores, e.g. missing
+	// permissions or CRDs, as Warning events. No events are emitted if nil
+	EventRecorder record.EventRecorder
+	// EventObject is the object failure events are emitted on, e.g. the Pod
+	// of x-metrics, unless the registration of a store carries its own by
+	// WithEventObject
+	EventObject runtime.Object
+	// Mapper resolves the resources registered by
+	// RegisterAndAddMetricStoreForKind
+	Mapper *ResourceMapper
+	// lists limits the initial lists to MaxInitialLists, created on the
+	// first registration
+	lists     *listLimiter
+	responses *responseCache
+	// informers share the reflectors of the stores of the same objects
+	informers *informers
+	// events share the reflectors of the Warning events of the same kind
+	events *eventInformers
+	config Config
+	// resync is the resync period of the reflectors of resources not
+	// configuring their own
+	resync time.Duration
+	// generators generate the families added to the stores of resources
+	generators generatorRegistry
+	// options are applied to each Config set
+	options []Option
+	// debugStores log their debug messages at verbosity 0
+	debugStores []string
+	// watchedMu protects watched
+	watchedMu sync.Mutex
+	// watched holds the channels stopping the stores of the watched
+	// resources of the Config, keyed by the name of the store
+	watched map[string]chan struct{}
 }
 
+// registeredStore writes the metric stores of a resource and holds the
+// function stopping their reflectors.
+type registeredStore struct {
+	writer metricsstore.MetricsWriter
+	cancel context.CancelFunc
+	stores []*instrumentedStore
+	// registration restarts the stores, nil for stores not registered by
+	// RegisterAndAddMetricStore
+	registration *registration
+	// config is the configuration the stores were started with
+	config ResourceConfig
+	// family is the base name of the families of the stores, stores of the
+	// same family are written as a single block. Empty for stores not
+	// registered by RegisterAndAddMetricStore.
+	family string
+}
+
+// registration holds the arguments of RegisterAndAddMetricStore.
+type registration struct {
+	// ctx is canceled when the store is stopped
+	ctx        context.Context
+	cancel     context.CancelFunc
+	metricName string
+	gvr        schema.GroupVersionResource
+	namespace  string
+	// config is the configuration of the registration without defaults,
+	// ignored if fromConfig is set
+	config ResourceConfig
+	// fromConfig takes the configuration of the resource from the handler's
+	// Config
+	fromConfig bool
+}
+
+func newRegisteredStore(cancel context.CancelFunc, reg *registration, stores ...*instrumentedStore) *registeredStore {
+	metricsStores := make([]*metricsstore.MetricsStore, 0, len(stores))
+	for _, s := range stores {
+		metricsStores = append(metricsStores, s.metrics)
+	}
+	return &registeredStore{writer: metricsstore.NewMultiStoreMetricsWriter(metricsStores), cancel: cancel, stores: stores, registration: reg}
+}
+
+// stop stops the reflectors of the stores and their registration.
+func (s *registeredStore) stop() {
+	s.cancel()
+	for _, st := range s.stores {
+		st.stop()
+	}
+	if s.registration != nil {
+		s.registration.cancel()
+	}
+}
+
+// pending returns true if the initial list of a store neither completed nor
+// failed yet.
+func (s *registeredStore) pending() bool {
+	for _, st := range s.stores {
+		if !st.hasSynced() && !st.failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// lastSync returns the oldest last sync of the stores, zero if none synced.
+func (s *registeredStore) lastSync() time.Time {
+	var oldest time.Time
+	for _, st := range s.stores {
+		if t := st.lastSync(); !t.IsZero() && (oldest.IsZero() || t.Before(oldest)) {
+			oldest = t
+		}
+	}
+	return oldest
+}
+
+// InfoMappings maps the value at FieldPath to the label Label of the _info family
 type InfoMappings struct {
-	FieldPath string
-	Label     string
+	FieldPath string `json:"fieldPath"`
+	Label     string `json:"label"`
+}
+
+// GaugeMappings exports the numeric value at FieldPath as gauge family <metric>_<Suffix>
+type GaugeMappings struct {
+	FieldPath string `json:"fieldPath"`
+	Suffix    string `json:"suffix"`
+	// Help is the HELP text of the family. Defaults to "Value of <FieldPath>"
+	Help string `json:"help,omitempty"`
+}
+
+// ExpressionMappings exports the result of a CEL expression evaluated against
+// each object as gauge family <metric>_<Suffix>. Boolean results map to 1 and 0,
+// objects for which the expression fails or returns null get no series.
+type ExpressionMappings struct {
+	Expression string `json:"expression"`
+	Suffix     string `json:"suffix"`
+	// Help is the HELP text of the family. Defaults to "Value of <Expression>"
+	Help string `json:"help,omitempty"`
+}
+
+// referenceLabels are exposed as labels on the _info family of objects
+// which have the field path set.
+var referenceLabels = []InfoMappings{
+	// composite resources
+	{FieldPath: "spec.compositionRef.name", Label: "composition_name"},
+	{FieldPath: "spec.compositionRevisionRef.name", Label: "composition_revision"},
+	{FieldPath: "spec.claimRef.namespace", Label: "claim_namespace"},
+	{FieldPath: "spec.claimRef.name", Label: "claim_name"},
+	// managed resources
+	{FieldPath: "spec.providerConfigRef.name", Label: "provider_config"},
 }
+
+// managementPolicies are the policies of spec.managementPolicies of managed resources
+var managementPolicies = []string{"Create", "Update", "Delete", "Observe", "LateInitialize"}
+
 type crossplaneStatus struct {
 	ready      float64
 	synced     float64
 	readyTime  time.Time
 	syncedTime time.Time
+	conditions []xpv1.Condition
 }
 
-func NewManagedMetricsHandler(dc dynamic.Interface) ManagedMetricsHandler {
+// NewManagedMetricsHandler returns a handler of the metric stores of the
+// resources of dc, configured by config and opts.
+func NewManagedMetricsHandler(dc dynamic.Interface, config Config, opts ...Option) ManagedMetricsHandler {
+	o := newOptions(config, 0, opts)
 	return ManagedMetricsHandler{
-		metricsWriter: map[string]*metricsstore.MetricsStore{},
+		metricsWriter: map[string]*registeredStore{},
 		Client:        dc,
+		responses:     newResponseCache(),
+		informers:     newInformers(),
+		events:        newEventInformers(),
+		config:        o.config,
+		resync:        o.resync,
+		generators:    o.generators,
+		debugStores:   o.debugStores,
+		options:       opts,
 	}
 }
 
-func (m *ManagedMetricsHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+func (m *ManagedMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.serveStores(w, r, m.metricStores(), newAggregateWriter(m))
+}
 
-	for _, w := range m.metricsWriter {
-		w.WriteAll(writer)
+// WriteAll writes the families of all stores in the Prometheus text format.
+func (m *ManagedMetricsHandler) WriteAll(w io.Writer) {
+	bw, release := bufferedWriter(w)
+	defer release()
+	w = m.staticLabels().writer(bw)
+	writeStores(m.metricStores(), w, familyFilter{})
+	newAggregateWriter(m).WriteAll(w)
+}
+
+// staticLabels returns the labels added to every series.
+func (m *ManagedMetricsHandler) staticLabels() staticLabels {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return newStaticLabels(m.config.Labels)
+}
+
+// StoreHandler returns a handler serving the store named by the request path
+// after prefix, e.g. /x-metrics/<name> for the prefix /x-metrics/.
+func (m *ManagedMetricsHandler) StoreHandler(prefix string) http.Handler {
+	return http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.RLock()
+		s, ok := m.metricsWriter[r.URL.Path]
+		m.mu.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		m.serveStores(w, r, map[string]metricsstore.MetricsWriter{r.URL.Path: storeWriter(s, m.lazyReadyObjects())})
+	}))
+}
+
+// serveStores writes the given stores, followed by the families of the
+// writers not belonging to a store. The response is cached for
+// ResponseCacheTTL.
+func (m *ManagedMetricsHandler) serveStores(w http.ResponseWriter, r *http.Request, stores map[string]metricsstore.MetricsWriter, writers ...metricsstore.MetricsWriter) {
+	if m.ResponseCacheTTL <= 0 || m.responses == nil {
+		m.writeResponse(w, r, stores, writers...)
+		return
+	}
+	m.responses.serve(w, r, m.ResponseCacheTTL, func(w http.ResponseWriter) {
+		m.writeResponse(w, r, stores, writers...)
+	})
+}
+
+func (m *ManagedMetricsHandler) writeResponse(w http.ResponseWriter, r *http.Request, stores map[string]metricsstore.MetricsWriter, writers ...metricsstore.MetricsWriter) {
+	start := time.Now()
+	defer func() { scrapeDuration.Observe(time.Since(start).Seconds()) }()
+
+	format := negotiateFormat(r.Header)
+	w.Header().Set("Content-Type", string(format))
+
+	var writer io.Writer = w
+	// Gzip response if requested, as done by kube-state-metrics
+	if acceptsGzip(r.Header) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Add("Vary", "Accept-Encoding")
+		writer = gzip.NewWriter(w)
+	}
+
+	bw, release := bufferedWriter(writer)
+	var out io.Writer = bw
+	if format.isOpenMetrics() {
+		out = openMetricsWriter(out)
 	}
+	out = m.staticLabels().writer(out)
+	filter := parseFamilyFilter(r.URL.Query())
+	writeStores(stores, out, filter)
+	for _, s := range writers {
+		s.WriteAll(filter.writer(out))
+	}
+
+	if format.isOpenMetrics() {
+		_, _ = out.Write([]byte(openMetricsEOF))
+	}
+	release()
 
+	// In case we gzipped the response, we have to close the writer
 	if closer, ok := writer.(io.Closer); ok {
 		closer.Close()
 	}
 }
 
+// RegisterAndAddMetricStoreForGVR registers a metric store for the given
+// resource, configured by the handler's Config.
 func (m *ManagedMetricsHandler) RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string) chan struct{} {
-	reflectorStore, channel := m.registerMetricStoreForGVR(ctx, metricName, gvr, namespace)
-	m.addMetricStore(metricName, reflectorStore)
-	return channel
+	return m.register(ctx, &registration{metricName: metricName, gvr: gvr, namespace: namespace, fromConfig: true})
 }
 
-func (m *ManagedMetricsHandler) addMetricStore(name string, metricStore *metricsstore.MetricsStore) {
-	m.metricsWriter[name] = metricStore
+// RegisterAndAddMetricStoreForKind registers a metric store for the resource
+// of kind, given as <kind>.<group>, <resource>.<group> or short name, e.g.
+// Bucket.s3.aws.upbound.io, in its preferred version. The resource is resolved
+// by the Mapper, configured by the handler's Config.
+func (m *ManagedMetricsHandler) RegisterAndAddMetricStoreForKind(ctx context.Context, metricName string, kind string, namespace string) (chan struct{}, error) {
+	if m.Mapper == nil {
+		return nil, errors.New("cannot resolve kinds without a Mapper")
+	}
+	gvr, err := m.Mapper.ResourceFor(kind)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve resource of %s: %w", kind, err)
+	}
+	return m.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, namespace), nil
 }
 
-func (m *ManagedMetricsHandler) RemoveMetricStore(name string) {
-	delete(m.metricsWriter, name)
+// RegisterAndAddMetricStore registers a metric store for the given resource,
+// configured by config instead of the handler's Config. Unset options are
+// taken from the defaults of the handler's Config.
+func (m *ManagedMetricsHandler) RegisterAndAddMetricStore(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, config ResourceConfig) chan struct{} {
+	return m.register(ctx, &registration{metricName: metricName, gvr: gvr, namespace: namespace, config: config})
 }
 
-func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string) (*metricsstore.MetricsStore, chan struct{}) {
+func (m *ManagedMetricsHandler) register(ctx context.Context, reg *registration) chan struct{} {
+	ctx, cancel := context.WithCancel(ctx)
+	reg.ctx, reg.cancel = ctx, cancel
 
-	log := log.FromContext(ctx)
-
-	if namespace != "" {
-		metricName = GetValidLabel(namespace + "_" + metricName)
+	m.mu.Lock()
+	s := m.start(reg)
+	if shared := m.sharingFamily(reg.metricName, s.family); len(shared) > 0 {
+		log.FromContext(ctx).Info("Metric name collision, merging families of stores", "family", s.family, "store", reg.metricName, "stores", shared)
 	}
-	headers := []string{
-		"# TYPE %s gauge\n# HELP %s A metrics series for each object",
-		"# TYPE %s_created gauge\n# HELP %s_created Unix creation timestamp",
-		"# TYPE %s_labels gauge\n# HELP %s_labels Labels from the kubernetes object",
-		"# TYPE %s_info gauge\n# HELP %s_info A metrics series exposing parameters as labels",
-		"# TYPE %s_ready gauge\n# HELP %s_ready A metrics series mapping the Ready status condition to a value (True=1,False=0,other=-1)",
-		"# TYPE %s_ready_time gauge\n# HELP %s_ready_time Unix timestamp of last ready change",
-		"# TYPE %s_synced gauge\n# HELP %s_synced A metrics series mapping the Synced status condition to a value (True=1,False=0,other=-1)",
-		"# TYPE %s_synced_time gauge\n# HELP %s_synced_time Unix timestamp of last synced change",
+	m.setStore(reg.metricName, s)
+	m.mu.Unlock()
+
+	// Closing the channel stops the reflectors, as does canceling the context.
+	// The store is removed then, instead of serving the last state of the
+	// objects.
+	channel := make(chan struct{})
+	go func() {
+		select {
+		case <-channel:
+			cancel()
+		case <-ctx.Done():
+		}
+		m.unregister(reg.metricName, reg)
+	}()
+	return channel
+}
+
+// unregister removes the store of name if it is still the store of reg, and
+// not of a later registration with the same name.
+func (m *ManagedMetricsHandler) unregister(name string, reg *registration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.metricsWriter[name]; ok && s.registration == reg {
+		s.stop()
+		delete(m.metricsWriter, name)
+		deleteStoreMetrics(name)
 	}
-	for i, hfmt := range headers {
-		headers[i] = fmt.Sprintf(hfmt, metricName, metricName)
+	registeredStores.Set(float64(len(m.metricsWriter)))
+}
+
+// SetSharding changes the sharding of the handler. All registered stores are
+// restarted, so that the objects are redistributed among the shards.
+func (m *ManagedMetricsHandler) SetSharding(s Sharding) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Sharding == s {
+		return
 	}
-	labelKeys := []string{"name"}
-	labelValues := func(obj *unstructured.Unstructured) []string {
-		return []string{obj.GetName()}
+	m.Sharding = s
+	for name, old := range m.metricsWriter {
+		if old.registration == nil {
+			continue
+		}
+		old.cancel()
+		m.metricsWriter[name] = m.start(old.registration)
 	}
+}
 
-	if namespace != "" {
-		labelKeys = append(labelKeys, "namespace")
-		labelValues = func(obj *unstructured.Unstructured) []string {
-			return []string{obj.GetName(), obj.GetNamespace()}
+// SetConfig changes the configuration of the handler, with the options of
+// the handler applied. Registered stores whose configuration changed are
+// restarted, all of them if the naming or the list page size changed. Stores
+// of resources newly watched by config are registered with ctx, those of
+// resources no longer watched are removed. The clusters can't be changed,
+// ClusterName and Clusters of config are ignored.
+func (m *ManagedMetricsHandler) SetConfig(ctx context.Context, config Config) {
+	m.watchedMu.Lock()
+	defer m.watchedMu.Unlock()
+	m.setConfig(config)
+
+	watched := config.watchedResources()
+	for name, channel := range m.watched {
+		if _, ok := watched[name]; !ok {
+			close(channel)
+			delete(m.watched, name)
 		}
 	}
-	reflectorStore := metricsstore.NewMetricsStore(headers, func(objAny any) []metric.FamilyInterface {
-		obj := objAny.(*unstructured.Unstructured)
-		paved := fieldpath.Pave(obj.Object)
-		o := metric.Family{
-			Name: metricName,
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
-					Value:       1,
-				},
-			},
+	if m.watched == nil {
+		m.watched = map[string]chan struct{}{}
+	}
+	for name, gvr := range watched {
+		if _, ok := m.watched[name]; !ok {
+			m.watched[name] = m.RegisterAndAddMetricStoreForGVR(ctx, name, gvr, "")
 		}
+	}
+}
 
-		families := []metric.FamilyInterface{&o}
-
-		created := metric.Family{
-			Name: metricName + "_created",
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
-					Value:       float64(obj.GetCreationTimestamp().Unix()),
-				},
-			},
+// setConfig changes the configuration of the handler and restarts the
+// registered stores whose configuration changed.
+func (m *ManagedMetricsHandler) setConfig(config Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	config = newOptions(config, m.resync, m.options).config
+	config.ClusterName, config.Clusters = m.config.ClusterName, m.config.Clusters
+	restartAll := config.Naming != m.config.Naming || config.listPageSize() != m.config.listPageSize()
+	m.config = config
+	for name, old := range m.metricsWriter {
+		if old.registration == nil {
+			continue
 		}
-		families = append(families, &created)
+		if !restartAll && reflect.DeepEqual(old.config, m.resourceConfig(old.registration)) {
+			continue
+		}
+		old.cancel()
+		m.metricsWriter[name] = m.start(old.registration)
+	}
+}
 
-		labels := metric.Family{
-			Name: metricName + "_labels",
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
-					Value:       1,
-				},
-			},
+// resourceConfig returns the configuration of a registration, with the
+// defaults of the handler's Config. m.mu must be held.
+func (m *ManagedMetricsHandler) resourceConfig(reg *registration) ResourceConfig {
+	config := reg.config
+	if reg.fromConfig {
+		config = m.config.ResourceConfigFor(reg.gvr)
+	}
+	config.ResourceOptions = config.ResourceOptions.withDefaults(m.config.Defaults)
+	return config
+}
+
+// start starts the reflectors of a registration. m.mu must be held.
+func (m *ManagedMetricsHandler) start(reg *registration) *registeredStore {
+	config := m.resourceConfig(reg)
+	stores, cancel := m.registerMetricStoreForGVR(reg.ctx, reg.metricName, reg.gvr, reg.namespace, config)
+	s := newRegisteredStore(cancel, reg, stores...)
+	s.config = config
+	s.family = m.config.Naming.familyName(reg.metricName, reg.gvr, reg.namespace)
+	storeResync.WithLabelValues(reg.metricName).Set(m.resyncPeriod(config).Seconds())
+	return s
+}
+
+// resyncPeriod returns the resync period of the reflectors of a resource.
+func (m *ManagedMetricsHandler) resyncPeriod(resourceConfig ResourceConfig) time.Duration {
+	if resourceConfig.Resync != nil {
+		return resourceConfig.Resync.Duration
+	}
+	return m.resync
+}
+
+// addMetricStore adds the stores of a resource, stopping the reflectors of
+// previous stores with the same name.
+func (m *ManagedMetricsHandler) addMetricStore(name string, cancel context.CancelFunc, stores ...*instrumentedStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setStore(name, newRegisteredStore(cancel, nil, stores...))
+}
+
+// setStore adds a store, stopping a previous store with the same name. m.mu
+// must be held.
+func (m *ManagedMetricsHandler) setStore(name string, s *registeredStore) {
+	if old, ok := m.metricsWriter[name]; ok {
+		old.stop()
+	}
+	m.metricsWriter[name] = s
+	registeredStores.Set(float64(len(m.metricsWriter)))
+}
+
+// RemoveMetricStore stops the reflector of a store and removes it.
+//
+// Deprecated: Use Stop.
+func (m *ManagedMetricsHandler) RemoveMetricStore(name string) {
+	m.Stop(name)
+}
+
+// Stop stops the reflector of a store and removes the store. Callers don't
+// need to close the channel returned on registration.
+func (m *ManagedMetricsHandler) Stop(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.metricsWriter[name]; ok {
+		s.stop()
+		delete(m.metricsWriter, name)
+		deleteStoreMetrics(name)
+	}
+	registeredStores.Set(float64(len(m.metricsWriter)))
+}
+
+// Close stops and removes all stores.
+func (m *ManagedMetricsHandler) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, s := range m.metricsWriter {
+		s.stop()
+		delete(m.metricsWriter, name)
+		deleteStoreMetrics(name)
+	}
+	registeredStores.Set(float64(len(m.metricsWriter)))
+}
+
+// metricStores returns a snapshot of the registered stores, so that writing
+// them does not block registrations.
+func (m *ManagedMetricsHandler) metricStores() map[string]metricsstore.MetricsWriter {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	writers := make(map[string]metricsstore.MetricsWriter, len(m.metricsWriter))
+	ready := m.lazyReadyObjects()
+	now := time.Now()
+	names := make([]string, 0, len(m.metricsWriter))
+	for name := range m.metricsWriter {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// families holds the name of the first store of a family
+	families := map[string]string{}
+	for _, name := range names {
+		s := m.metricsWriter[name]
+		w := storeWriter(s, ready)
+		if m.config.Staleness.stale(s.lastSync(), now) {
+			if w = m.config.Staleness.writer(w); w == nil {
+				continue
+			}
 		}
-		for k, v := range obj.GetLabels() {
-			labels.Metrics[0].LabelKeys = append(labels.Metrics[0].LabelKeys, "label_"+GetValidLabel(k))
-			labels.Metrics[0].LabelValues = append(labels.Metrics[0].LabelValues, v)
+		if s.family == "" {
+			writers[name] = w
+			continue
 		}
-		families = append(families, &labels)
+		first, ok := families[s.family]
+		if !ok {
+			families[s.family] = name
+			writers[name] = w
+			continue
+		}
+		merged, ok := writers[first].(mergedWriter)
+		if !ok {
+			merged = mergedWriter{writers[first]}
+		}
+		writers[first] = append(merged, w)
+	}
+	return writers
+}
 
-		mappings := []InfoMappings{}
+// sharingFamily returns the names of the stores other than name with the
+// given family. m.mu must be held.
+func (m *ManagedMetricsHandler) sharingFamily(name, family string) []string {
+	var shared []string
+	for n, s := range m.metricsWriter {
+		if n != name && family != "" && s.family == family {
+			shared = append(shared, n)
+		}
+	}
+	sort.Strings(shared)
+	return shared
+}
 
-		var infoKeys, infoValues []string
-		for _, m := range mappings {
-			val, _ := paved.GetString(m.FieldPath)
-			infoKeys = append(infoKeys, m.Label)
-			infoValues = append(infoValues, val)
+// ReadyzCheck returns an error until the initial list of all registered
+// stores was attempted. Stores whose lists fail, e.g. for a missing CRD or
+// missing permissions, do not hold back readiness; they are reported by the
+// failure metrics instead. It implements healthz.Checker.
+func (m *ManagedMetricsHandler) ReadyzCheck(_ *http.Request) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var pending []string
+	for name, s := range m.metricsWriter {
+		if s.pending() {
+			pending = append(pending, name)
 		}
+	}
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		return fmt.Errorf("metric stores not synced: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
 
-		o_info := metric.Family{
-			Name: metricName + "_info",
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   append(labelKeys, infoKeys...),
-					LabelValues: append(labelValues(obj), infoValues...),
-					Value:       1,
-				},
-			},
+// WaitForSync blocks until the initial list of all registered stores
+// completed or ctx is done. In the latter case the pending stores are returned
+// as error.
+func (m *ManagedMetricsHandler) WaitForSync(ctx context.Context) error {
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+	for {
+		err := m.ReadyzCheck(nil)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-t.C:
 		}
+	}
+}
 
-		families = append(families, &o_info)
+// registerMetricStoreForGVR starts a reflector and store for each watched
+// namespace of the resource. Cluster wide registrations watch the namespaces
+// configured by resourceConfig. m.mu must be held.
+func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, resourceConfig ResourceConfig) ([]*instrumentedStore, context.CancelFunc) {
 
-		status := getCrossplaneStatus(obj)
-		o_ready := metric.Family{
-			Name: metricName + "_ready",
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
-					Value:       status.ready,
-				},
-			},
-		}
+	ctx, cancel := context.WithCancel(ctx)
+	resourceConfig.Group, resourceConfig.Version, resourceConfig.Resource = gvr.Group, gvr.Version, gvr.Resource
 
-		families = append(families, o_ready)
+	opts := listWatchOptions{
+		pageSize:      m.config.listPageSize(),
+		labelSelector: resourceConfig.LabelSelector,
+		fieldSelector: resourceConfig.FieldSelector,
+		sharding:      m.Sharding,
+		timeout:       m.ListTimeout,
+	}
+	namespaces := []string{namespace}
+	if namespace == "" {
+		namespaces, opts.fieldSelector = resourceConfig.watchedNamespaces()
+	}
 
-		o_ready_time := metric.Family{
-			Name: metricName + "_ready_time",
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
-					Value:       float64(status.readyTime.Unix()),
-				},
-			},
+	// The objects of remote clusters are exported by the same families
+	clusters := append([]Cluster{{Name: m.config.ClusterName, Client: m.Client, MetadataClient: m.MetadataClient}}, m.Clusters...)
+
+	if m.lists == nil {
+		m.lists = newListLimiter(m.MaxInitialLists)
+	}
+	lists := m.lists
+
+	familyName := m.config.Naming.familyName(metricName, gvr, namespace)
+	stores := make([]*instrumentedStore, 0, len(clusters)*len(namespaces))
+	for _, c := range clusters {
+		objLabels := newObjectLabels(namespace, c.Name, resourceConfig)
+		comp := newComposition(familyName, objLabels, resourceConfig)
+		for _, ns := range namespaces {
+			// Each store lists and watches with a context of its own, so that
+			// it can be stopped on its own
+			ctx, storeCancel := context.WithCancel(ctx)
+			ctx = withStoreLog(ctx, m.debugStore(metricName, gvr), "store", metricName, "gvr", gvr.String(), "namespace", ns, "cluster", c.Name)
+			ctx = m.withFailureEvents(ctx, metricName, ns, c.Name)
+			t, fc := newTransitions(), newFamilyCache()
+			reflectorStore := newInstrumentedStore(newMetricsStore(familyName, namespace, c.Name, resourceConfig, t, fc, m.generators.forResource(gvr)...), gvr)
+			reflectorStore.cancel = storeCancel
+			reflectorStore.transitions = t
+			reflectorStore.families = fc
+			reflectorStore.cluster = c.Name
+			reflectorStore.composition = comp
+			reflectorStore.events = newStoreEvents(familyName, objLabels, gvr, resourceConfig)
+			reflectorStore.limits = resourceConfig.Limits
+			reflectorStore.log = log.FromContext(ctx)
+			stores = append(stores, reflectorStore)
+			// Stores counting the events of the same kind share an event
+			// informer
+			eventKey := eventInformerKey{cluster: c.Name, namespace: ns, kind: resourceConfig.Kind}
+			if reflectorStore.events != nil {
+				m.events.subscribe(ctx, c.Client, eventKey, reflectorStore.events.counter)
+			}
+
+			// Stores of the same objects share an informer
+			metadataOnly := pointer.BoolDeref(resourceConfig.MetadataOnly, false) && c.MetadataClient != nil
+			key := informerKey{
+				cluster:       c.Name,
+				gvr:           gvr,
+				namespace:     ns,
+				pageSize:      opts.pageSize,
+				labelSelector: opts.labelSelector,
+				fieldSelector: opts.fieldSelector,
+				sharding:      opts.sharding,
+				resync:        m.resyncPeriod(resourceConfig),
+				metadataOnly:  metadataOnly,
+				dropSpec:      pointer.BoolDeref(resourceConfig.DropSpec, false) && !metadataOnly,
+			}
+			reflectorStore.cancel = func() {
+				storeCancel()
+				m.informers.unsubscribe(key, reflectorStore)
+				if reflectorStore.events != nil {
+					m.events.unsubscribe(eventKey, reflectorStore.events.counter)
+				}
+			}
+			c, opts, priority := c, opts, resourceConfig.Priority
+			opts.transform = stripObject(key.dropSpec)
+			m.informers.subscribe(ctx, key, reflectorStore, func(ctx context.Context, inf *sharedInformer) {
+				var lw *cache.ListWatch
+				if metadataOnly {
+					lw = newMetadataListWatch(ctx, c.MetadataClient.Resource(gvr).Namespace(key.namespace), opts)
+				} else {
+					lw = newListWatch(ctx, c.Client.Resource(gvr).Namespace(key.namespace), opts)
+				}
+				resumeWatches(ctx, lw, gvr)
+				countLists(ctx, lw, gvr)
+				recordWatches(lw, inf)
+
+				re := cache.NewReflector(lw, &unstructured.Unstructured{}, inf, 0)
+				if opts.pageSize > 0 {
+					re.WatchListPageSize = opts.pageSize
+				}
+				if m.CheckPermissions && !waitForPermissions(ctx, c.Client, gvr, key.namespace, inf.setError) {
+					return
+				}
+				release, ok := lists.acquire(ctx, priority)
+				if !ok {
+					return
+				}
+				defer release()
+				releaseAfterList(lw, release)
+				runReflector(ctx, re, gvr, key.resync, inf.setError)
+			})
+			go func() {
+				<-ctx.Done()
+				reflectorStore.stop()
+			}()
 		}
+	}
 
-		families = append(families, o_ready_time)
+	return stores, cancel
+}
 
-		o_synced := metric.Family{
-			Name: metricName + "_synced",
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
-					Value:       status.synced,
-				},
-			},
+// Backoff of reflector restarts, as used by client-go
+const (
+	reflectorBackoffInitial = 800 * time.Millisecond
+	reflectorBackoffMax     = 30 * time.Second
+	reflectorBackoffReset   = 2 * time.Minute
+	reflectorBackoffFactor  = 2.0
+	reflectorBackoffJitter  = 1.0
+)
+
+// runReflector lists and watches until ctx is done. Failed lists and
+// watches, e.g. during API server outages, are restarted with exponential
+// backoff and jitter, resuming from the last synced resource version. If
+// resync is positive, the watch is stopped after resync to list all objects
+// again, so that missed events don't persist. Failures are reported to
+// report unless it is nil.
+func runReflector(ctx context.Context, re *cache.Reflector, gvr schema.GroupVersionResource, resync time.Duration, report func(error)) {
+	log, debug := log.FromContext(ctx), debugLog(ctx)
+	backoff := wait.NewExponentialBackoffManager(reflectorBackoffInitial, reflectorBackoffMax, reflectorBackoffReset, reflectorBackoffFactor, reflectorBackoffJitter, clock.RealClock{})
+	first, resynced := true, false
+	// retries counts the failures since the last list and watch ending
+	// without error
+	retries := 0
+	wait.BackoffUntil(func() {
+		switch {
+		case first:
+			debug.Info("starting list and watch", "resync", resync.String())
+		case resynced:
+			reflectorResyncs.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Inc()
+			debug.Info("resyncing, listing all objects again")
+		default:
+			reflectorRestarts.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Inc()
+			debug.Info("restarting list and watch", "retries", retries)
 		}
+		first = false
+		lctx, cancel := ctx, context.CancelFunc(func() {})
+		if resync > 0 {
+			lctx, cancel = context.WithTimeout(ctx, resync)
+		}
+		defer cancel()
+		if err := re.ListAndWatch(lctx.Done()); err != nil {
+			retries++
+			listWatchErrors.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Inc()
+			log.Error(err, "list and watch failed", "retries", retries)
+			if report != nil {
+				report(err)
+			}
+			if retries == failureEventRetries {
+				warn(ctx, ReasonListWatchFailed, "cannot list and watch %s after %d attempts: %v", gvr.String(), retries, err)
+			}
+		} else {
+			retries = 0
+		}
+		resynced = ctx.Err() == nil && lctx.Err() != nil
+	}, backoff, true, ctx.Done())
+}
 
-		families = append(families, o_synced)
+type family struct {
+	suffix string
+	help   string
+}
 
-		o_synced_time := metric.Family{
-			Name: metricName + "_synced_time",
-			Metrics: []*metric.Metric{
-				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
-					Value:       float64(status.syncedTime.Unix()),
-				},
-			},
+// header returns the header of the family, typed as counter if the family
+// is a total.
+func (f family) header(metricName, help string) string {
+	if strings.HasSuffix(f.suffix, "_total") {
+		return fmt.Sprintf("# TYPE %s counter\n# HELP %s %s", metricName+f.suffix, metricName+f.suffix, help)
+	}
+	return header(metricName+f.suffix, help)
+}
+
+// key returns the key of the family in ResourceOptions.Help.
+func (f family) key() string {
+	if f.suffix == "" {
+		return "object"
+	}
+	return strings.TrimPrefix(f.suffix, "_")
+}
+
+// knownFamily returns true if key is the key of one of builtins,
+// composedFamilies or eventFamily.
+func knownFamily(key string) bool {
+	for _, b := range builtins {
+		if b.key() == key {
+			return true
+		}
+	}
+	for _, f := range append([]family{eventFamily}, composedFamilies...) {
+		if f.key() == key {
+			return true
 		}
+	}
+	return false
+}
 
-		families = append(families, o_synced_time)
+func header(name, help string) string {
+	return fmt.Sprintf("# TYPE %s gauge\n# HELP %s %s", name, name, help)
+}
 
-		return families
-	})
+// objectLabels are the labels identifying an object in the families of a
+// store.
+type objectLabels struct {
+	namespace bool
+	uid       bool
+	cluster   string
+}
 
-	lw := cache.ListWatch{
-		ListFunc: func(opt metav1.ListOptions) (runtime.Object, error) {
-			o, err := m.Client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-			if err != nil {
-				log.Info("err listing")
-			}
-			return o, err
-		},
-		WatchFunc: func(ops metav1.ListOptions) (watch.Interface, error) {
-			return m.Client.Resource(gvr).Namespace(namespace).Watch(ctx, ops)
-		},
+func newObjectLabels(namespace, cluster string, resourceConfig ResourceConfig) objectLabels {
+	return objectLabels{
+		namespace: namespace != "" || resourceConfig.multiNamespace() || pointer.BoolDeref(resourceConfig.NamespaceLabel, false),
+		uid:       pointer.BoolDeref(resourceConfig.UIDLabel, false),
+		cluster:   cluster,
+	}
+}
+
+func (l objectLabels) keys() []string {
+	keys := []string{"name"}
+	if l.namespace {
+		keys = append(keys, "namespace")
+	}
+	if l.uid {
+		keys = append(keys, "uid")
+	}
+	if l.cluster != "" {
+		keys = append(keys, "cluster")
 	}
+	return keys
+}
 
-	re := cache.NewReflector(&lw, &unstructured.Unstructured{}, reflectorStore, 0)
+func (l objectLabels) values(name, namespace string, uid types.UID) []string {
+	values := []string{name}
+	if l.namespace {
+		values = append(values, namespace)
+	}
+	if l.uid {
+		values = append(values, string(uid))
+	}
+	if l.cluster != "" {
+		values = append(values, l.cluster)
+	}
+	return values
+}
 
-	channel := make(chan struct{})
-	go re.Run(channel)
+// storeFamily is a family of a store with its header and the generator of the
+// series of an object, which lack the labels identifying the object.
+type storeFamily struct {
+	name     string
+	header   string
+	generate func(o *object) []*metric.Metric
+}
+
+// storeFamilies returns the enabled built-in families of metricName followed
+// by the configured gauges, expressions and extra conditions and the families
+// of generators.
+func storeFamilies(metricName string, resourceConfig ResourceConfig, generators []FamilyGenerator) []storeFamily {
+	disabled := map[string]bool{}
+	for _, f := range resourceConfig.DisabledFamilies {
+		disabled[f] = true
+	}
+	families := make([]storeFamily, 0, len(builtins)+len(generators))
+	for _, b := range builtins {
+		if disabled[b.key()] {
+			continue
+		}
+		help := b.help
+		if typ, ok := conditionFamilies[b.suffix]; ok {
+			help = resourceConfig.ConditionValues.help(string(typ), resourceConfig.ConditionEncoding, true)
+		}
+		if h, ok := resourceConfig.Help[b.key()]; ok {
+			help = h
+		}
+		generate := b.generate
+		families = append(families, storeFamily{
+			name:   metricName + b.suffix,
+			header: b.header(metricName, resourceConfig.expandHelp(help)),
+			generate: func(o *object) []*metric.Metric {
+				return generate(o, &resourceConfig)
+			},
+		})
+	}
+	for _, g := range resourceConfig.Gauges {
+		fieldPath := g.FieldPath
+		help := g.Help
+		if help == "" {
+			help = "Value of " + g.FieldPath
+		}
+		name := metricName + "_" + GetValidLabel(g.Suffix)
+		families = append(families, storeFamily{
+			name:   name,
+			header: header(name, resourceConfig.expandHelp(help)),
+			generate: func(o *object) []*metric.Metric {
+				if v, ok := getNumericFieldValue(o.paved, fieldPath); ok {
+					return series(v)
+				}
+				return nil
+			},
+		})
+	}
+	for _, e := range resourceConfig.Expressions {
+		expr, err := CompileExpression(e.Expression)
+		if err != nil {
+			// invalid expressions are rejected by Config.Validate
+			continue
+		}
+		help := e.Help
+		if help == "" {
+			help = "Value of " + e.Expression
+		}
+		name := metricName + "_" + GetValidLabel(e.Suffix)
+		families = append(families, storeFamily{
+			name:   name,
+			header: header(name, resourceConfig.expandHelp(help)),
+			generate: func(o *object) []*metric.Metric {
+				if v, ok := expr.EvalFloat(o.Object); ok {
+					return series(v)
+				}
+				return nil
+			},
+		})
+	}
+	for _, c := range resourceConfig.ExtraConditions {
+		conditionType := c
+		name := conditionFamilyName(metricName, c)
+		families = append(families, storeFamily{
+			name:   name,
+			header: header(name, resourceConfig.ConditionValues.help(c, resourceConfig.ConditionEncoding, false)),
+			generate: func(o *object) []*metric.Metric {
+				// objects without the condition, e.g. before their first
+				// asynchronous operation, get no series
+				var ms []*metric.Metric
+				for _, cond := range o.status.conditions {
+					if string(cond.Type) != conditionType {
+						continue
+					}
+					if resourceConfig.ConditionEncoding == ConditionEncodingStateSet {
+						ms = stateSet(cond)
+					} else {
+						ms = series(resourceConfig.ConditionValues.status(cond))
+					}
+				}
+				return ms
+			},
+		})
+	}
+	for _, g := range generators {
+		g := g
+		suffix, help := g.Family()
+		families = append(families, storeFamily{
+			name:   metricName + suffix,
+			header: family{suffix: suffix}.header(metricName, resourceConfig.expandHelp(help)),
+			generate: func(o *object) []*metric.Metric {
+				return g.Generate(o.Unstructured)
+			},
+		})
+	}
+	return families
+}
+
+// newMetricsStore returns a store generating the metric families of metricName
+// for each object added to it. Objects of namespaced registrations are labeled
+// with their namespace, objects of named clusters with the cluster. The
+// condition changes are counted by t and the families of unchanged objects are
+// taken from c, or from stores of their own if nil. The families of generators
+// follow the built-in families.
+func newMetricsStore(metricName string, namespace string, cluster string, resourceConfig ResourceConfig, t *transitions, c *familyCache, generators ...FamilyGenerator) *metricsstore.MetricsStore {
+	if t == nil {
+		t = newTransitions()
+	}
+	if c == nil {
+		c = newFamilyCache()
+	}
+	families := storeFamilies(metricName, resourceConfig, generators)
+	c.shed = resourceConfig.Limits.shedFamilies(metricName, families)
+	headers := make([]string, len(families))
+	for i, f := range families {
+		headers[i] = f.header
+	}
+	objLabels := newObjectLabels(namespace, cluster, resourceConfig)
+	labelKeys := objLabels.keys()
+	relabel := newRelabeling(resourceConfig.RelabelConfigs)
+	interned := newKeyCache(len(families))
+	return metricsstore.NewMetricsStore(headers, c.generate(func(objAny any) []metric.FamilyInterface {
+		o := newStoreObject(objAny.(*unstructured.Unstructured), t)
+		o.keys = interned
+		labelValues := objLabels.values(o.GetName(), o.GetNamespace(), o.GetUID())
+		generated := make([]metric.FamilyInterface, len(families))
+		for i, f := range families {
+			family := &metric.Family{Name: f.name}
+			for _, m := range f.generate(o) {
+				var keys, values []string
+				keep := true
+				if len(relabel) == 0 {
+					// Series are rendered without modifying their labels,
+					// so the keys and values are shared
+					keys, values = interned.keys(i, labelKeys, m.LabelKeys), labelValues
+					if len(m.LabelValues) > 0 {
+						values = appendLabels(labelValues, m.LabelValues...)
+					}
+				} else {
+					keys, values, keep = relabel.apply(f.name, appendLabels(labelKeys, m.LabelKeys...), appendLabels(labelValues, m.LabelValues...))
+				}
+				if !keep {
+					continue
+				}
+				family.Metrics = append(family.Metrics, &metric.Metric{
+					LabelKeys:   keys,
+					LabelValues: values,
+					Value:       m.Value,
+				})
+			}
+			generated[i] = family
+		}
+		return generated
+	}))
+}
 
-	return reflectorStore, channel
+// conditionFamilyName returns the name of the family of an extra condition,
+// e.g. <metric>_condition_last_async_operation for LastAsyncOperation.
+func conditionFamilyName(metricName, conditionType string) string {
+	return metricName + "_condition_" + snakeCase(GetValidLabel(conditionType))
 }
 
 func GetValidLabel(name string) string {
@@ -287,8 +1109,135 @@ func GetValidLabel(name string) string {
 	}, name)
 }
 
+// allowedAnnotations returns the annotations in allowlist as label keys and
+// values, sorted by key. The keys are interned in keys.
+func allowedAnnotations(annotations map[string]string, allowlist []string, keys *keyCache) ([]string, []string) {
+	var labelKeys, values []string
+	for _, k := range sortedKeys(annotations) {
+		if matchesAny(allowlist, k) {
+			labelKeys = append(labelKeys, keys.name("annotation_", k))
+			values = append(values, annotations[k])
+		}
+	}
+	return labelKeys, values
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appendLabels returns a copy of base with labels appended, leaving base untouched.
+func appendLabels(base []string, labels ...string) []string {
+	return append(append(make([]string, 0, len(base)+len(labels)), base...), labels...)
+}
+
+// getFieldValue returns the scalar value at path as string. Missing fields and
+// non-scalar values result in an empty string.
+func getFieldValue(paved *fieldpath.Paved, path string) string {
+	v, err := paved.GetValue(path)
+	if err != nil {
+		return ""
+	}
+	switch t := v.(type) {
+	case string:
+		return t
+	case bool, int64, float64:
+		return fmt.Sprint(t)
+	default:
+		return ""
+	}
+}
+
+// getManagementPolicies returns the set management policies of a managed
+// resource. Managed resources without spec.managementPolicies default to all
+// policies. Objects which are no managed resources return false.
+func getManagementPolicies(paved *fieldpath.Paved) (map[string]bool, bool) {
+	var policies []string
+	if err := paved.GetValueInto("spec.managementPolicies", &policies); err != nil {
+		if _, err := paved.GetValue("spec.forProvider"); err != nil {
+			return nil, false
+		}
+		policies = []string{"*"}
+	}
+	set := make(map[string]bool, len(policies))
+	for _, p := range policies {
+		set[p] = true
+	}
+	return set, true
+}
+
+// claimFields are fields of which at least one is set on claims, as the
+// composition fields are defaulted.
+var claimFields = []string{"spec.resourceRef", "spec.compositionRef", "spec.compositionSelector", "spec.compositeDeletePolicy", "spec.compositionUpdatePolicy"}
+
+// getConnectionSecret returns the connection secret of spec.writeConnectionSecretToRef.
+// Claims reference secrets in their own namespace, so the namespace of the
+// reference defaults to the namespace of the object.
+func getConnectionSecret(paved *fieldpath.Paved, namespace string) (string, string, bool) {
+	name, err := paved.GetString("spec.writeConnectionSecretToRef.name")
+	if err != nil || name == "" {
+		return "", "", false
+	}
+	if ns, err := paved.GetString("spec.writeConnectionSecretToRef.namespace"); err == nil && ns != "" {
+		namespace = ns
+	}
+	return namespace, name, true
+}
+
+// getClaimBinding returns the name of the composite resource a claim is bound
+// to, empty if it is not bound yet, and false if the object is no claim.
+// Claims are namespaced, unlike composite resources.
+func getClaimBinding(paved *fieldpath.Paved, namespace string) (string, bool) {
+	if namespace == "" {
+		return "", false
+	}
+	for _, f := range claimFields {
+		if _, err := paved.GetValue(f); err == nil {
+			return getFieldValue(paved, "spec.resourceRef.name"), true
+		}
+	}
+	return "", false
+}
+
+// getNumericFieldValue returns the value at path as float. Booleans map to
+// 1 and 0, strings are parsed as float. Missing or non-numeric values return
+// false.
+func getNumericFieldValue(paved *fieldpath.Paved, path string) (float64, bool) {
+	v, err := paved.GetValue(path)
+	if err != nil {
+		return 0, false
+	}
+	switch t := v.(type) {
+	case int64:
+		return float64(t), true
+	case float64:
+		return t, true
+	case bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
 func statusToPrometheusValue(s xpv1.ConditionedStatus, typ xpv1.ConditionType) float64 {
-	switch s.GetCondition(typ).Status {
+	return conditionValue(s.GetCondition(typ))
+}
+
+// conditionValue maps the status of a condition to True=1, False=0 and
+// other=-1.
+func conditionValue(c xpv1.Condition) float64 {
+	switch c.Status {
 	case "True":
 		return 1
 	case "False":
@@ -307,5 +1256,6 @@ func getCrossplaneStatus(u *unstructured.Unstructured) crossplaneStatus {
 		synced:     statusToPrometheusValue(conditioned, xpv1.TypeSynced),
 		readyTime:  conditioned.GetCondition(xpv1.TypeReady).LastTransitionTime.Time,
 		syncedTime: conditioned.GetCondition(xpv1.TypeSynced).LastTransitionTime.Time,
+		conditions: conditioned.Conditions,
 	}
 }
@@ -0,0 +1,1875 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+	"k8s.io/utils/pointer"
+)
+
+// familySamples returns the samples of the metric family name written by the store.
+func familySamples(t *testing.T, config ResourceConfig, obj *unstructured.Unstructured, name string) []string {
+	t.Helper()
+	store := newMetricsStore("test", "", "", config, nil, nil)
+	if err := store.Add(obj); err != nil {
+		t.Fatalf("store.Add(...): %v", err)
+	}
+	buf := &bytes.Buffer{}
+	store.WriteAll(buf)
+
+	var samples []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, name+"{") || strings.HasPrefix(line, name+" ") {
+			samples = append(samples, line)
+		}
+	}
+	return samples
+}
+
+func newObject(object map[string]any) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: object}
+	u.SetName("obj")
+	u.SetUID("uid")
+	return u
+}
+
+func TestConditionFamily(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"AllConditions": {
+			reason: "Should export a series for each condition of the object.",
+			obj: newObject(map[string]any{
+				"status": map[string]any{
+					"conditions": []any{
+						map[string]any{"type": "Ready", "status": "True"},
+						map[string]any{"type": "Healthy", "status": "False"},
+					},
+				},
+			}),
+			want: []string{
+				`test_condition{name="obj",type="Ready",status="True"} 1`,
+				`test_condition{name="obj",type="Healthy",status="False"} 1`,
+			},
+		},
+		"NoConditions": {
+			reason: "Should not export series without conditions.",
+			obj:    newObject(map[string]any{}),
+			want:   nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{}, tc.obj, "test_condition")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_condition: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStatusReasonFamily(t *testing.T) {
+	obj := newObject(map[string]any{
+		"status": map[string]any{
+			"conditions": []any{
+				map[string]any{"type": "Synced", "status": "False", "reason": "ReconcileError", "message": "boom"},
+			},
+		},
+	})
+
+	cases := map[string]struct {
+		reason string
+		config ResourceConfig
+		want   []string
+	}{
+		"Reason": {
+			reason: "Should export the condition reason as label.",
+			want: []string{
+				`test_status_reason{name="obj",type="Synced",reason="ReconcileError"} 1`,
+			},
+		},
+		"Message": {
+			reason: "Should export the condition message as label if configured.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{ConditionMessages: pointer.Bool(true)}},
+			want: []string{
+				`test_status_reason{name="obj",type="Synced",reason="ReconcileError",message="boom"} 1`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, tc.config, obj, "test_status_reason")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_status_reason: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConditionUID(t *testing.T) {
+	obj := newObject(map[string]any{
+		"status": map[string]any{
+			"conditions": []any{
+				map[string]any{"type": "Ready", "status": "True"},
+				map[string]any{"type": "Synced", "status": "False"},
+			},
+		},
+	})
+
+	cases := map[string]struct {
+		reason string
+		config ResourceConfig
+		want   []string
+	}{
+		"Disabled": {
+			reason: "Should not label the series with the object UID by default.",
+			want:   []string{`test_ready{name="obj"} 1`, `test_synced{name="obj"} 0`},
+		},
+		"Enabled": {
+			reason: "Should label the _ready and _synced series with the object UID if configured.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{ConditionUID: pointer.Bool(true)}},
+			want:   []string{`test_ready{name="obj",uid="uid"} 1`, `test_synced{name="obj",uid="uid"} 0`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := append(familySamples(t, tc.config, obj, "test_ready"), familySamples(t, tc.config, obj, "test_synced")...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nnewMetricsStore(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUIDLabel(t *testing.T) {
+	obj := newObject(map[string]any{
+		"status": map[string]any{
+			"conditions": []any{
+				map[string]any{"type": "Ready", "status": "True"},
+			},
+		},
+	})
+
+	cases := map[string]struct {
+		reason string
+		config ResourceConfig
+		want   []string
+	}{
+		"Disabled": {
+			reason: "Should not label the series with the object UID by default.",
+			want:   []string{`test{name="obj"} 1`, `test_ready{name="obj"} 1`},
+		},
+		"Enabled": {
+			reason: "Should label the series of all families with the object UID if configured.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{UIDLabel: pointer.Bool(true)}},
+			want:   []string{`test{name="obj",uid="uid"} 1`, `test_ready{name="obj",uid="uid"} 1`},
+		},
+		"ConditionUID": {
+			reason: "Should not label the _ready series twice if the condition UID is enabled as well.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{UIDLabel: pointer.Bool(true), ConditionUID: pointer.Bool(true)}},
+			want:   []string{`test{name="obj",uid="uid"} 1`, `test_ready{name="obj",uid="uid"} 1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := append(familySamples(t, tc.config, obj, "test"), familySamples(t, tc.config, obj, "test_ready")...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nnewMetricsStore(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConditionValues(t *testing.T) {
+	obj := newObject(map[string]any{
+		"status": map[string]any{
+			"conditions": []any{
+				map[string]any{"type": "Ready", "status": "Unknown"},
+			},
+		},
+	})
+	two, nan := ConditionValue(2), ConditionValue(math.NaN())
+
+	cases := map[string]struct {
+		reason string
+		values *ConditionValues
+		want   []string
+	}{
+		"Default": {
+			reason: "Should map other statuses and missing conditions to -1 by default.",
+			want:   []string{`test_ready{name="obj"} -1`, `test_synced{name="obj"} -1`},
+		},
+		"Custom": {
+			reason: "Should map the statuses to the configured values.",
+			values: &ConditionValues{Unknown: &two, Missing: &nan},
+			want:   []string{`test_ready{name="obj"} 2`, `test_synced{name="obj"} NaN`},
+		},
+		"MissingDefaultsToUnknown": {
+			reason: "Should map missing conditions like other statuses unless configured.",
+			values: &ConditionValues{Unknown: &two},
+			want:   []string{`test_ready{name="obj"} 2`, `test_synced{name="obj"} 2`},
+		},
+		"OmitMissing": {
+			reason: "Should omit the series of missing conditions if configured.",
+			values: &ConditionValues{OmitMissing: true},
+			want:   []string{`test_ready{name="obj"} -1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := ResourceConfig{ResourceOptions: ResourceOptions{ConditionValues: tc.values}}
+			got := append(familySamples(t, config, obj, "test_ready"), familySamples(t, config, obj, "test_synced")...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nnewMetricsStore(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStateSetEncoding(t *testing.T) {
+	obj := newObject(map[string]any{
+		"status": map[string]any{
+			"conditions": []any{
+				map[string]any{"type": "Ready", "status": "False"},
+				map[string]any{"type": "LastAsyncOperation", "status": "True"},
+			},
+		},
+	})
+
+	cases := map[string]struct {
+		reason  string
+		options ResourceOptions
+		family  string
+		want    []string
+	}{
+		"Ready": {
+			reason:  "Should write a series for each status with 1 for the current status.",
+			options: ResourceOptions{ConditionEncoding: ConditionEncodingStateSet},
+			family:  "test_ready",
+			want:    []string{`test_ready{name="obj",status="True"} 0`, `test_ready{name="obj",status="False"} 1`, `test_ready{name="obj",status="Unknown"} 0`},
+		},
+		"Missing": {
+			reason:  "Should write missing conditions as Unknown.",
+			options: ResourceOptions{ConditionEncoding: ConditionEncodingStateSet, ConditionUID: pointer.Bool(true)},
+			family:  "test_synced",
+			want:    []string{`test_synced{name="obj",uid="uid",status="True"} 0`, `test_synced{name="obj",uid="uid",status="False"} 0`, `test_synced{name="obj",uid="uid",status="Unknown"} 1`},
+		},
+		"OmitMissing": {
+			reason:  "Should omit the series of missing conditions if configured.",
+			options: ResourceOptions{ConditionEncoding: ConditionEncodingStateSet, ConditionValues: &ConditionValues{OmitMissing: true}},
+			family:  "test_synced",
+		},
+		"ExtraCondition": {
+			reason:  "Should encode extra conditions as state set.",
+			options: ResourceOptions{ConditionEncoding: ConditionEncodingStateSet, ExtraConditions: []string{"LastAsyncOperation"}},
+			family:  "test_condition_last_async_operation",
+			want:    []string{`test_condition_last_async_operation{name="obj",status="True"} 1`, `test_condition_last_async_operation{name="obj",status="False"} 0`, `test_condition_last_async_operation{name="obj",status="Unknown"} 0`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{ResourceOptions: tc.options}, obj, tc.family)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nnewMetricsStore(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConditionHelp(t *testing.T) {
+	two, nan := ConditionValue(2), ConditionValue(math.NaN())
+
+	cases := map[string]struct {
+		reason  string
+		options ResourceOptions
+		want    []string
+	}{
+		"Default": {
+			reason:  "Should describe the default condition values.",
+			options: ResourceOptions{ExtraConditions: []string{"LastAsyncOperation"}},
+			want: []string{
+				"# HELP test_ready A metrics series mapping the Ready status condition to a value (True=1,False=0,other=-1)",
+				"# HELP test_synced A metrics series mapping the Synced status condition to a value (True=1,False=0,other=-1)",
+				"# HELP test_condition_last_async_operation A metrics series mapping the LastAsyncOperation status condition to a value (True=1,False=0,other=-1)",
+			},
+		},
+		"CustomValues": {
+			reason:  "Should describe the configured condition values, with the value of missing conditions only for Ready and Synced.",
+			options: ResourceOptions{ExtraConditions: []string{"LastAsyncOperation"}, ConditionValues: &ConditionValues{Unknown: &two, Missing: &nan}},
+			want: []string{
+				"# HELP test_ready A metrics series mapping the Ready status condition to a value (True=1,False=0,other=2,missing=NaN)",
+				"# HELP test_synced A metrics series mapping the Synced status condition to a value (True=1,False=0,other=2,missing=NaN)",
+				"# HELP test_condition_last_async_operation A metrics series mapping the LastAsyncOperation status condition to a value (True=1,False=0,other=2)",
+			},
+		},
+		"StateSet": {
+			reason:  "Should describe the state set encoding.",
+			options: ResourceOptions{ExtraConditions: []string{"LastAsyncOperation"}, ConditionEncoding: ConditionEncodingStateSet, ConditionValues: &ConditionValues{Unknown: &two}},
+			want: []string{
+				"# HELP test_ready A metrics series for each status of the Ready status condition, labeled by status (current=1,other=0)",
+				"# HELP test_synced A metrics series for each status of the Synced status condition, labeled by status (current=1,other=0)",
+				"# HELP test_condition_last_async_operation A metrics series for each status of the LastAsyncOperation status condition, labeled by status (current=1,other=0)",
+			},
+		},
+		"HelpOverride": {
+			reason:  "Should keep configured help texts.",
+			options: ResourceOptions{ConditionValues: &ConditionValues{Unknown: &two}, Help: map[string]string{"ready": "Readiness of the {kind}"}},
+			want: []string{
+				"# HELP test_ready Readiness of the instance",
+				"# HELP test_synced A metrics series mapping the Synced status condition to a value (True=1,False=0,other=2)",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := newMetricsStore("test", "", "", ResourceConfig{Resource: "instances", ResourceOptions: tc.options}, nil, nil)
+			_ = store.Add(newObject(map[string]any{}))
+			buf := &bytes.Buffer{}
+			store.WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				for _, f := range []string{"test_ready", "test_synced", "test_condition_last_async_operation"} {
+					if strings.HasPrefix(line, "# HELP "+f+" ") {
+						got = append(got, line)
+					}
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nnewMetricsStore(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConcurrentScrapeAndRegistration(t *testing.T) {
+	m := NewManagedMetricsHandler(nil, Config{})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		name := fmt.Sprintf("store%d", i)
+		go func() {
+			defer wg.Done()
+			m.addMetricStore(name, func() {}, newInstrumentedStore(newMetricsStore(name, "", "", ResourceConfig{}, nil, nil), schema.GroupVersionResource{}))
+			m.RemoveMetricStore(name)
+		}()
+		go func() {
+			defer wg.Done()
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x-metrics", nil))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestServeHTTPGzip(t *testing.T) {
+	m := NewManagedMetricsHandler(nil, Config{})
+	m.addMetricStore("test", func() {}, newInstrumentedStore(newMetricsStore("test", "", "", ResourceConfig{}, nil, nil), schema.GroupVersionResource{}))
+
+	req := httptest.NewRequest("GET", "/x-metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("ServeHTTP(...): want Content-Encoding gzip, got %q", got)
+	}
+	r, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader(...): %v", err)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("io.ReadAll(...): %v", err)
+	}
+	if !strings.Contains(string(body), "# TYPE test gauge") {
+		t.Errorf("ServeHTTP(...): want decompressed metric headers, got:\n%s", body)
+	}
+}
+
+func TestStoreHandler(t *testing.T) {
+	type want struct {
+		status  int
+		headers []string
+	}
+	cases := map[string]struct {
+		reason string
+		path   string
+		want   want
+	}{
+		"Store": {
+			reason: "Should serve the families of the requested store only.",
+			path:   "/x-metrics/first",
+			want:   want{status: http.StatusOK, headers: []string{"# TYPE first gauge"}},
+		},
+		"UnknownStore": {
+			reason: "Should respond with not found for unknown stores.",
+			path:   "/x-metrics/unknown",
+			want:   want{status: http.StatusNotFound},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{})
+			for _, n := range []string{"first", "second"} {
+				m.addMetricStore(n, func() {}, newInstrumentedStore(newMetricsStore(n, "", "", ResourceConfig{}, nil, nil), schema.GroupVersionResource{}))
+			}
+			rec := httptest.NewRecorder()
+			m.StoreHandler("/x-metrics/").ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+
+			got := want{status: rec.Code}
+			for _, l := range strings.Split(rec.Body.String(), "\n") {
+				if strings.HasPrefix(l, "# TYPE") && strings.HasSuffix(l, "gauge") && !strings.Contains(l, "_") {
+					got.headers = append(got.headers, l)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nStoreHandler(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInfoFamily(t *testing.T) {
+	withExternalName := newObject(map[string]any{
+		"spec": map[string]any{
+			"forProvider": map[string]any{"region": "eu-central-1", "size": int64(3)},
+		},
+	})
+	withExternalName.SetAnnotations(map[string]string{"crossplane.io/external-name": "db-1234"})
+	withOwners := newObject(map[string]any{})
+	withOwners.SetOwnerReferences([]metav1.OwnerReference{
+		{APIVersion: "example.org/v1", Kind: "Usage", Name: "protection"},
+		{APIVersion: "example.org/v1", Kind: "XDatabase", Name: "db-x7k2p", Controller: pointer.Bool(true)},
+	})
+	withSecrets := newObject(map[string]any{
+		"spec": map[string]any{
+			"forProvider": map[string]any{
+				"region":                  "eu-central-1",
+				"masterPassword":          "hunter2",
+				"masterPasswordSecretRef": map[string]any{"name": "db-password", "key": "password"},
+			},
+			"writeConnectionSecretToRef": map[string]any{"namespace": "crossplane-system", "name": "db-conn"},
+		},
+	})
+
+	cases := map[string]struct {
+		reason string
+		config ResourceConfig
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"Empty": {
+			reason: "Should export only the object labels without mappings.",
+			obj:    newObject(map[string]any{}),
+			want:   []string{`test_info{name="obj"} 1`},
+		},
+		"ExternalName": {
+			reason: "Should export the external name annotation as label.",
+			obj:    withExternalName,
+			want:   []string{`test_info{name="obj",external_name="db-1234"} 1`},
+		},
+		"CompositionReference": {
+			reason: "Should export composition references of composite resources.",
+			obj: newObject(map[string]any{
+				"spec": map[string]any{
+					"compositionRef":         map[string]any{"name": "xpostgres"},
+					"compositionRevisionRef": map[string]any{"name": "xpostgres-1a2b3c"},
+				},
+			}),
+			want: []string{`test_info{name="obj",composition_name="xpostgres",composition_revision="xpostgres-1a2b3c"} 1`},
+		},
+		"ClaimReference": {
+			reason: "Should export claim references of composite resources.",
+			obj: newObject(map[string]any{
+				"spec": map[string]any{
+					"claimRef": map[string]any{"namespace": "team-a", "name": "db"},
+				},
+			}),
+			want: []string{`test_info{name="obj",claim_namespace="team-a",claim_name="db"} 1`},
+		},
+		"ProviderConfigReference": {
+			reason: "Should export the provider config of managed resources.",
+			obj: newObject(map[string]any{
+				"spec": map[string]any{
+					"providerConfigRef": map[string]any{"name": "aws-prod"},
+				},
+			}),
+			want: []string{`test_info{name="obj",provider_config="aws-prod"} 1`},
+		},
+		"Owner": {
+			reason: "Should export the controller owner reference, e.g. the composite resource of a composed resource.",
+			obj:    withOwners,
+			want:   []string{`test_info{name="obj",owner_kind="XDatabase",owner_name="db-x7k2p"} 1`},
+		},
+		"InfoMappings": {
+			reason: "Should export configured field paths as labels.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{InfoMappings: []InfoMappings{
+				{FieldPath: "spec.forProvider.region", Label: "region"},
+				{FieldPath: "spec.forProvider.size", Label: "size"},
+				{FieldPath: "spec.forProvider.missing", Label: "missing"},
+			}}},
+			obj:  withExternalName,
+			want: []string{`test_info{name="obj",external_name="db-1234",region="eu-central-1",size="3",missing=""} 1`},
+		},
+		"RedactSensitiveFields": {
+			reason: "Should redact the values of field paths matching the default sensitive fields, ignoring case.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{InfoMappings: []InfoMappings{
+				{FieldPath: "spec.forProvider.masterPassword", Label: "password"},
+				{FieldPath: "spec.forProvider.region", Label: "region"},
+			}}},
+			obj:  withSecrets,
+			want: []string{`test_info{name="obj",password="<redacted>",region="eu-central-1"} 1`},
+		},
+		"SecretReferences": {
+			reason: "Should not redact fields referencing a secret by its name by default.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{InfoMappings: []InfoMappings{
+				{FieldPath: "spec.writeConnectionSecretToRef.name", Label: "connection_secret"},
+				{FieldPath: "spec.forProvider.masterPasswordSecretRef.name", Label: "password_secret"},
+			}}},
+			obj:  withSecrets,
+			want: []string{`test_info{name="obj",connection_secret="db-conn",password_secret="db-password"} 1`},
+		},
+		"CustomSecretReferences": {
+			reason: "Should redact fields referencing a secret if they match the configured sensitive fields.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{SensitiveFields: []string{"*secret*"}, InfoMappings: []InfoMappings{
+				{FieldPath: "spec.writeConnectionSecretToRef.name", Label: "connection_secret"},
+			}}},
+			obj:  withSecrets,
+			want: []string{`test_info{name="obj",connection_secret="<redacted>"} 1`},
+		},
+		"DropSensitiveFields": {
+			reason: "Should omit the labels of sensitive fields if configured.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{Redaction: RedactionDrop, InfoMappings: []InfoMappings{
+				{FieldPath: "spec.forProvider.masterPassword", Label: "password"},
+				{FieldPath: "spec.forProvider.region", Label: "region"},
+			}}},
+			obj:  withSecrets,
+			want: []string{`test_info{name="obj",region="eu-central-1"} 1`},
+		},
+		"CustomSensitiveFields": {
+			reason: "Should only redact the configured sensitive fields if set.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{SensitiveFields: []string{"*.region"}, InfoMappings: []InfoMappings{
+				{FieldPath: "spec.forProvider.masterPassword", Label: "password"},
+				{FieldPath: "spec.forProvider.region", Label: "region"},
+			}}},
+			obj:  withSecrets,
+			want: []string{`test_info{name="obj",password="hunter2",region="<redacted>"} 1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, tc.config, tc.obj, "test_info")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_info: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPausedFamily(t *testing.T) {
+	paused := newObject(map[string]any{})
+	paused.SetAnnotations(map[string]string{"crossplane.io/paused": "true"})
+
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"Paused": {
+			reason: "Should export 1 for paused objects.",
+			obj:    paused,
+			want:   []string{`test_paused{name="obj"} 1`},
+		},
+		"NotPaused": {
+			reason: "Should export 0 for objects without the annotation.",
+			obj:    newObject(map[string]any{}),
+			want:   []string{`test_paused{name="obj"} 0`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{}, tc.obj, "test_paused")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_paused: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGenerationFamilies(t *testing.T) {
+	obj := newObject(map[string]any{
+		"status": map[string]any{"observedGeneration": int64(2)},
+	})
+	obj.SetGeneration(3)
+
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		family string
+		want   []string
+	}{
+		"Generation": {
+			reason: "Should export metadata.generation.",
+			obj:    obj,
+			family: "test_generation",
+			want:   []string{`test_generation{name="obj"} 3`},
+		},
+		"ObservedGeneration": {
+			reason: "Should export status.observedGeneration.",
+			obj:    obj,
+			family: "test_observed_generation",
+			want:   []string{`test_observed_generation{name="obj"} 2`},
+		},
+		"NoObservedGeneration": {
+			reason: "Should omit the observed generation if not reported.",
+			obj:    newObject(map[string]any{}),
+			family: "test_observed_generation",
+			want:   nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{}, tc.obj, tc.family)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n%s: -want, +got:\n%s", tc.reason, tc.family, diff)
+			}
+		})
+	}
+}
+
+func TestManagementPolicyFamily(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"ObserveOnly": {
+			reason: "Should export the configured management policies.",
+			obj: newObject(map[string]any{
+				"spec": map[string]any{
+					"forProvider":        map[string]any{},
+					"managementPolicies": []any{"Observe"},
+				},
+			}),
+			want: []string{
+				`test_management_policy{name="obj",policy="Create"} 0`,
+				`test_management_policy{name="obj",policy="Update"} 0`,
+				`test_management_policy{name="obj",policy="Delete"} 0`,
+				`test_management_policy{name="obj",policy="Observe"} 1`,
+				`test_management_policy{name="obj",policy="LateInitialize"} 0`,
+			},
+		},
+		"Default": {
+			reason: "Should enable all policies for managed resources without management policies.",
+			obj: newObject(map[string]any{
+				"spec": map[string]any{"forProvider": map[string]any{}},
+			}),
+			want: []string{
+				`test_management_policy{name="obj",policy="Create"} 1`,
+				`test_management_policy{name="obj",policy="Update"} 1`,
+				`test_management_policy{name="obj",policy="Delete"} 1`,
+				`test_management_policy{name="obj",policy="Observe"} 1`,
+				`test_management_policy{name="obj",policy="LateInitialize"} 1`,
+			},
+		},
+		"NotManaged": {
+			reason: "Should not export management policies for other objects.",
+			obj:    newObject(map[string]any{}),
+			want:   nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{}, tc.obj, "test_management_policy")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_management_policy: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestBoundFamily(t *testing.T) {
+	newClaim := func(spec map[string]any) *unstructured.Unstructured {
+		u := newObject(map[string]any{"spec": spec})
+		u.SetNamespace("team-a")
+		return u
+	}
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"Bound": {
+			reason: "Should export bound claims with their composite resource.",
+			obj: newClaim(map[string]any{
+				"compositeDeletePolicy": "Background",
+				"resourceRef":           map[string]any{"apiVersion": "example.org/v1", "kind": "XDatabase", "name": "obj-x7k2p"},
+			}),
+			want: []string{`test_bound{name="obj",namespace="team-a",composite="obj-x7k2p"} 1`},
+		},
+		"Unbound": {
+			reason: "Should export claims without resourceRef as unbound.",
+			obj:    newClaim(map[string]any{"compositeDeletePolicy": "Background"}),
+			want:   []string{`test_bound{name="obj",namespace="team-a",composite=""} 0`},
+		},
+		"Composite": {
+			reason: "Should not export cluster scoped composite resources.",
+			obj:    newObject(map[string]any{"spec": map[string]any{"compositionRef": map[string]any{"name": "db"}}}),
+			want:   nil,
+		},
+		"NotClaim": {
+			reason: "Should not export namespaced objects without claim fields.",
+			obj:    newClaim(map[string]any{"forProvider": map[string]any{}}),
+			want:   nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{ResourceOptions: ResourceOptions{Namespaces: []string{"team-a"}}}, tc.obj, "test_bound")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_bound: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConnectionSecretFamilies(t *testing.T) {
+	newClaim := func(object map[string]any) *unstructured.Unstructured {
+		u := newObject(object)
+		u.SetNamespace("team-a")
+		return u
+	}
+	ref := map[string]any{"name": "db-conn"}
+
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"PublishedClaim": {
+			reason: "Should export the secret in the namespace of a claim and that its connection details were published.",
+			obj: newClaim(map[string]any{
+				"spec":   map[string]any{"writeConnectionSecretToRef": ref},
+				"status": map[string]any{"connectionDetails": map[string]any{"lastPublishedTime": "2023-06-01T12:00:00Z"}},
+			}),
+			want: []string{
+				`test_connection_secret{name="obj",namespace="team-a",secret_namespace="team-a",secret_name="db-conn"} 1`,
+				`test_connection_details_published{name="obj",namespace="team-a"} 1`,
+			},
+		},
+		"UnpublishedComposite": {
+			reason: "Should export composite resources which did not publish their connection details yet.",
+			obj: newObject(map[string]any{
+				"spec": map[string]any{"writeConnectionSecretToRef": map[string]any{"namespace": "crossplane-system", "name": "db-conn"}},
+			}),
+			want: []string{
+				`test_connection_secret{name="obj",secret_namespace="crossplane-system",secret_name="db-conn"} 1`,
+				`test_connection_details_published{name="obj"} 0`,
+			},
+		},
+		"Managed": {
+			reason: "Should not export whether managed resources published their connection details.",
+			obj: newObject(map[string]any{
+				"spec": map[string]any{"forProvider": map[string]any{}, "writeConnectionSecretToRef": map[string]any{"namespace": "crossplane-system", "name": "db-conn"}},
+			}),
+			want: []string{
+				`test_connection_secret{name="obj",secret_namespace="crossplane-system",secret_name="db-conn"} 1`,
+			},
+		},
+		"NoSecret": {
+			reason: "Should not export objects without connection secret.",
+			obj:    newObject(map[string]any{"spec": map[string]any{}}),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := ResourceConfig{}
+			if tc.obj.GetNamespace() != "" {
+				config.Namespaces = []string{"team-a"}
+			}
+			got := append(familySamples(t, config, tc.obj, "test_connection_secret"), familySamples(t, config, tc.obj, "test_connection_details_published")...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_connection_secret: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestExtraConditionFamilies(t *testing.T) {
+	config := ResourceConfig{ResourceOptions: ResourceOptions{ExtraConditions: []string{"LastAsyncOperation", "AsyncOperation"}}}
+
+	cases := map[string]struct {
+		reason     string
+		conditions []any
+		want       []string
+	}{
+		"Conditions": {
+			reason: "Should map the status of each configured condition to a value.",
+			conditions: []any{
+				map[string]any{"type": "Ready", "status": "True"},
+				map[string]any{"type": "LastAsyncOperation", "status": "False", "reason": "AsyncCreateFailure"},
+				map[string]any{"type": "AsyncOperation", "status": "True"},
+			},
+			want: []string{
+				`test_condition_last_async_operation{name="obj"} 0`,
+				`test_condition_async_operation{name="obj"} 1`,
+			},
+		},
+		"Missing": {
+			reason:     "Should not export conditions the object doesn't report.",
+			conditions: []any{map[string]any{"type": "LastAsyncOperation", "status": "Unknown"}},
+			want:       []string{`test_condition_last_async_operation{name="obj"} -1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := newObject(map[string]any{"status": map[string]any{"conditions": tc.conditions}})
+			got := append(familySamples(t, config, obj, "test_condition_last_async_operation"), familySamples(t, config, obj, "test_condition_async_operation")...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_condition_<type>: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGeneratorFamilies(t *testing.T) {
+	replicas := NewFamilyGenerator("_replicas", "Replicas of the {kind}", func(obj *unstructured.Unstructured) []*metric.Metric {
+		n, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			return nil
+		}
+		return []*metric.Metric{{LabelKeys: []string{"source"}, LabelValues: []string{"spec"}, Value: float64(n)}}
+	})
+
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"Generated": {
+			reason: "Should add the series of the generator to the labels of the object.",
+			obj:    newObject(map[string]any{"spec": map[string]any{"replicas": int64(3)}}),
+			want: []string{
+				"# HELP test_replicas Replicas of the instance",
+				"# TYPE test_replicas gauge",
+				`test_replicas{name="obj",source="spec"} 3`,
+			},
+		},
+		"NoSeries": {
+			reason: "Should only write the header if the generator returns no series.",
+			obj:    newObject(map[string]any{}),
+			want: []string{
+				"# HELP test_replicas Replicas of the instance",
+				"# TYPE test_replicas gauge",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := newMetricsStore("test", "", "", ResourceConfig{Resource: "instances"}, nil, nil, replicas)
+			_ = store.Add(tc.obj)
+			buf := &bytes.Buffer{}
+			store.WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.Contains(line, "test_replicas") {
+					got = append(got, line)
+				}
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_replicas: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFinalizersFamily(t *testing.T) {
+	withFinalizers := newObject(map[string]any{})
+	withFinalizers.SetFinalizers([]string{"finalizer.managedresource.crossplane.io", "in-use.crossplane.io"})
+
+	cases := map[string]struct {
+		reason string
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"Finalizers": {
+			reason: "Should export a series for each finalizer.",
+			obj:    withFinalizers,
+			want: []string{
+				`test_finalizers{name="obj",finalizer="finalizer.managedresource.crossplane.io"} 1`,
+				`test_finalizers{name="obj",finalizer="in-use.crossplane.io"} 1`,
+			},
+		},
+		"NoFinalizers": {
+			reason: "Should not export objects without finalizers.",
+			obj:    newObject(map[string]any{}),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{}, tc.obj, "test_finalizers")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_finalizers: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestTimeToReadyFamily(t *testing.T) {
+	newMR := func(status, transition string) *unstructured.Unstructured {
+		u := newObject(map[string]any{"status": map[string]any{"conditions": []any{
+			map[string]any{"type": "Ready", "status": status, "lastTransitionTime": transition},
+		}}})
+		u.SetCreationTimestamp(metav1.NewTime(time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)))
+		return u
+	}
+	cases := map[string]struct {
+		reason  string
+		updates []*unstructured.Unstructured
+		want    []string
+	}{
+		"Ready": {
+			reason:  "Should export the seconds from creation to the transition to Ready=True.",
+			updates: []*unstructured.Unstructured{newMR("True", "2023-06-01T12:05:30Z")},
+			want:    []string{`test_time_to_ready_seconds{name="obj"} 330`},
+		},
+		"NotReady": {
+			reason:  "Should not export objects which are not ready.",
+			updates: []*unstructured.Unstructured{newMR("False", "2023-06-01T12:05:30Z")},
+		},
+		"NoTransition": {
+			reason:  "Should not export ready objects without transition time.",
+			updates: []*unstructured.Unstructured{newMR("True", "")},
+		},
+		"Flapped": {
+			reason: "Should keep the first transition to Ready=True when the condition flaps later.",
+			updates: []*unstructured.Unstructured{
+				newMR("False", "2023-06-01T12:00:10Z"),
+				newMR("True", "2023-06-01T12:05:30Z"),
+				newMR("False", "2023-06-01T13:00:00Z"),
+				newMR("True", "2023-06-01T13:10:00Z"),
+			},
+			want: []string{`test_time_to_ready_seconds{name="obj"} 330`},
+		},
+		"FlappedNotReady": {
+			reason: "Should keep the first transition to Ready=True while the object is not ready again.",
+			updates: []*unstructured.Unstructured{
+				newMR("True", "2023-06-01T12:05:30Z"),
+				newMR("False", "2023-06-01T13:00:00Z"),
+			},
+			want: []string{`test_time_to_ready_seconds{name="obj"} 330`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := newMetricsStore("test", "", "", ResourceConfig{}, nil, nil)
+			for _, u := range tc.updates {
+				if err := store.Update(u); err != nil {
+					t.Fatalf("store.Update(...): %v", err)
+				}
+			}
+			buf := &bytes.Buffer{}
+			store.WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(line, "test_time_to_ready_seconds{") {
+					got = append(got, line)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_time_to_ready_seconds: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestTransitionFamilies(t *testing.T) {
+	newMR := func(ready, synced string) *unstructured.Unstructured {
+		return newObject(map[string]any{"status": map[string]any{"conditions": []any{
+			map[string]any{"type": "Ready", "status": ready},
+			map[string]any{"type": "Synced", "status": synced},
+		}}})
+	}
+	cases := map[string]struct {
+		reason  string
+		updates []*unstructured.Unstructured
+		deleted bool
+		want    []string
+	}{
+		"Flapping": {
+			reason:  "Should count each change of a condition.",
+			updates: []*unstructured.Unstructured{newMR("False", "True"), newMR("True", "True"), newMR("False", "True"), newMR("False", "False")},
+			want: []string{
+				`test_ready_transitions_total{name="obj"} 2`,
+				`test_synced_transitions_total{name="obj"} 1`,
+			},
+		},
+		"Unchanged": {
+			reason:  "Should not count updates without condition changes.",
+			updates: []*unstructured.Unstructured{newMR("True", "True"), newMR("True", "True")},
+			want: []string{
+				`test_ready_transitions_total{name="obj"} 0`,
+				`test_synced_transitions_total{name="obj"} 0`,
+			},
+		},
+		"Recreated": {
+			reason:  "Should restart counting objects which were deleted.",
+			updates: []*unstructured.Unstructured{newMR("False", "True"), newMR("True", "True")},
+			deleted: true,
+			want: []string{
+				`test_ready_transitions_total{name="obj"} 0`,
+				`test_synced_transitions_total{name="obj"} 0`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tr := newTransitions()
+			s := newInstrumentedStore(newMetricsStore("test", "", "", ResourceConfig{}, tr, nil), schema.GroupVersionResource{})
+			s.transitions = tr
+			for _, u := range tc.updates {
+				_ = s.Update(u)
+			}
+			if tc.deleted {
+				_ = s.Delete(tc.updates[0])
+				_ = s.Add(tc.updates[len(tc.updates)-1])
+			}
+			buf := &bytes.Buffer{}
+			s.metrics.WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(line, "test_ready_transitions_total{") || strings.HasPrefix(line, "test_synced_transitions_total{") {
+					got = append(got, line)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_transitions_total: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestAnnotationsFamily(t *testing.T) {
+	obj := newObject(map[string]any{})
+	obj.SetAnnotations(map[string]string{
+		"example.com/team":        "platform",
+		"example.com/cost-center": "1234",
+		"crossplane.io/paused":    "false",
+	})
+
+	cases := map[string]struct {
+		reason    string
+		allowlist []string
+		want      []string
+	}{
+		"NoAllowlist": {
+			reason: "Should not export annotations without allowlist.",
+			want:   nil,
+		},
+		"Allowlist": {
+			reason:    "Should export allowlisted annotations only.",
+			allowlist: []string{"example.com/team", "example.com/cost-center"},
+			want:      []string{`test_annotations{name="obj",annotation_example_com_cost_center="1234",annotation_example_com_team="platform"} 1`},
+		},
+		"Wildcard": {
+			reason:    "Should export all annotations for the wildcard.",
+			allowlist: []string{"*"},
+			want:      []string{`test_annotations{name="obj",annotation_crossplane_io_paused="false",annotation_example_com_cost_center="1234",annotation_example_com_team="platform"} 1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := ResourceConfig{ResourceOptions: ResourceOptions{AnnotationsAllowlist: tc.allowlist}}
+			got := familySamples(t, config, obj, "test_annotations")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_annotations: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLabelsFamily(t *testing.T) {
+	obj := newObject(map[string]any{})
+	obj.SetLabels(map[string]string{
+		"app.kubernetes.io/name": "db",
+		"team":                   "platform",
+		"pod-template-hash":      "5d8f7b",
+	})
+
+	cases := map[string]struct {
+		reason  string
+		options ResourceOptions
+		want    []string
+	}{
+		"AllLabels": {
+			reason: "Should export all labels by default.",
+			want:   []string{`test_labels{name="obj",label_app_kubernetes_io_name="db",label_pod_template_hash="5d8f7b",label_team="platform"} 1`},
+		},
+		"Allowlist": {
+			reason:  "Should export allowlisted labels only.",
+			options: ResourceOptions{LabelsAllowlist: []string{"app.kubernetes.io/*"}},
+			want:    []string{`test_labels{name="obj",label_app_kubernetes_io_name="db"} 1`},
+		},
+		"Denylist": {
+			reason:  "Should drop denylisted labels.",
+			options: ResourceOptions{LabelsDenylist: []string{"*-hash"}},
+			want:    []string{`test_labels{name="obj",label_app_kubernetes_io_name="db",label_team="platform"} 1`},
+		},
+		"Relabel": {
+			reason: "Should apply the relabel configs to the series.",
+			options: ResourceOptions{
+				LabelsAllowlist: []string{"app.kubernetes.io/*"},
+				RelabelConfigs: []RelabelConfig{
+					{SourceLabels: []string{"label_app_kubernetes_io_name"}, TargetLabel: "app"},
+					{Action: RelabelLabelDrop, Regex: "label_.*"},
+				},
+			},
+			want: []string{`test_labels{name="obj",app="db"} 1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, ResourceConfig{ResourceOptions: tc.options}, obj, "test_labels")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n_labels: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGaugeFamilies(t *testing.T) {
+	obj := newObject(map[string]any{
+		"spec": map[string]any{
+			"forProvider": map[string]any{"size": "20", "multiAZ": true},
+		},
+		"status": map[string]any{
+			"atProvider": map[string]any{"nodeCount": int64(3), "name": "db"},
+		},
+	})
+	config := ResourceConfig{ResourceOptions: ResourceOptions{Gauges: []GaugeMappings{
+		{FieldPath: "status.atProvider.nodeCount", Suffix: "node_count"},
+		{FieldPath: "spec.forProvider.size", Suffix: "size"},
+		{FieldPath: "spec.forProvider.multiAZ", Suffix: "multi_az"},
+		{FieldPath: "status.atProvider.name", Suffix: "name"},
+	}}}
+
+	cases := map[string]struct {
+		reason string
+		family string
+		want   []string
+	}{
+		"Integer": {
+			reason: "Should export integer fields.",
+			family: "test_node_count",
+			want:   []string{`test_node_count{name="obj"} 3`},
+		},
+		"NumericString": {
+			reason: "Should parse numeric strings.",
+			family: "test_size",
+			want:   []string{`test_size{name="obj"} 20`},
+		},
+		"Boolean": {
+			reason: "Should map booleans to 1 and 0.",
+			family: "test_multi_az",
+			want:   []string{`test_multi_az{name="obj"} 1`},
+		},
+		"NonNumeric": {
+			reason: "Should omit non-numeric values.",
+			family: "test_name",
+			want:   nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, config, obj, tc.family)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n%s: -want, +got:\n%s", tc.reason, tc.family, diff)
+			}
+		})
+	}
+}
+
+func TestExpressionFamilies(t *testing.T) {
+	obj := newObject(map[string]any{
+		"status": map[string]any{
+			"atProvider": map[string]any{"endpoint": "db.example.org", "replicas": int64(2)},
+		},
+	})
+	config := ResourceConfig{ResourceOptions: ResourceOptions{Expressions: []ExpressionMappings{
+		{Expression: "has(status.atProvider.endpoint) ? 1 : 0", Suffix: "has_endpoint"},
+		{Expression: "status.atProvider.replicas * 2", Suffix: "capacity"},
+		{Expression: "status.atProvider.missing", Suffix: "missing"},
+	}}}
+
+	cases := map[string]struct {
+		reason string
+		family string
+		want   []string
+	}{
+		"Conditional": {
+			reason: "Should export the result of the expression.",
+			family: "test_has_endpoint",
+			want:   []string{`test_has_endpoint{name="obj"} 1`},
+		},
+		"Arithmetic": {
+			reason: "Should export computed values.",
+			family: "test_capacity",
+			want:   []string{`test_capacity{name="obj"} 4`},
+		},
+		"Error": {
+			reason: "Should omit the series if the expression fails.",
+			family: "test_missing",
+			want:   nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, config, obj, tc.family)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\n%s: -want, +got:\n%s", tc.reason, tc.family, diff)
+			}
+		})
+	}
+}
+
+func TestReflectorRestart(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"})
+	// Fail the first list, as during an API server outage
+	failed := false
+	dc.PrependReactor("list", "instances", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if !failed {
+			failed = true
+			return true, nil, errors.New("connection refused")
+		}
+		return false, nil, nil
+	})
+
+	before := testutil.ToFloat64(reflectorRestarts.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource))
+	m := NewManagedMetricsHandler(dc, Config{})
+	channel := m.RegisterAndAddMetricStoreForGVR(context.Background(), "test", gvr, "")
+	defer close(channel)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for testutil.ToFloat64(reflectorRestarts.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("RegisterAndAddMetricStoreForGVR(...): want reflector restart after failed list")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestResync(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta2", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"})
+	var mu sync.Mutex
+	lists := 0
+	dc.PrependReactor("list", "instances", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lists++
+		return false, nil, nil
+	})
+
+	m := NewManagedMetricsHandler(dc, Config{}, WithResync(50*time.Millisecond))
+	channel := m.RegisterAndAddMetricStoreForGVR(context.Background(), "test", gvr, "")
+	defer close(channel)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := lists
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("RegisterAndAddMetricStoreForGVR(...): want objects listed again after the resync period")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(reflectorRestarts.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)); got != 0 {
+		t.Errorf("RegisterAndAddMetricStoreForGVR(...): want resyncs not counted as restarts, got %v", got)
+	}
+	if got := testutil.ToFloat64(reflectorResyncs.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)); got == 0 {
+		t.Error("RegisterAndAddMetricStoreForGVR(...): want resyncs counted")
+	}
+}
+
+func TestResyncPeriod(t *testing.T) {
+	m := NewManagedMetricsHandler(nil, Config{}, WithResync(time.Hour))
+	cases := map[string]struct {
+		reason string
+		resync *metav1.Duration
+		want   time.Duration
+	}{
+		"Default": {
+			reason: "Should default to the resync period of the handler.",
+			want:   time.Hour,
+		},
+		"Configured": {
+			reason: "Should take the resync period configured for the resource.",
+			resync: &metav1.Duration{Duration: 10 * time.Minute},
+			want:   10 * time.Minute,
+		},
+		"Disabled": {
+			reason: "Should not resync resources configuring a zero period.",
+			resync: &metav1.Duration{},
+			want:   0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := m.resyncPeriod(ResourceConfig{ResourceOptions: ResourceOptions{Resync: tc.resync}})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nresyncPeriod(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"})
+
+	cases := map[string]struct {
+		reason string
+		// stop stops the store, closing the channel returned on
+		// registration if closes is set
+		stop   func(m *ManagedMetricsHandler, cancel context.CancelFunc, channel chan struct{})
+		closes bool
+	}{
+		"Stop": {
+			reason: "Stop should remove the store.",
+			stop:   func(m *ManagedMetricsHandler, _ context.CancelFunc, _ chan struct{}) { m.Stop("test") },
+		},
+		"Close": {
+			reason: "Close should remove all stores.",
+			stop:   func(m *ManagedMetricsHandler, _ context.CancelFunc, _ chan struct{}) { m.Close() },
+		},
+		"CloseChannel": {
+			reason: "Closing the channel should remove the store.",
+			stop:   func(_ *ManagedMetricsHandler, _ context.CancelFunc, channel chan struct{}) { close(channel) },
+			closes: true,
+		},
+		"CancelContext": {
+			reason: "Canceling the context of the registration should remove the store.",
+			stop:   func(_ *ManagedMetricsHandler, cancel context.CancelFunc, _ chan struct{}) { cancel() },
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			m := NewManagedMetricsHandler(dc, Config{})
+			channel := m.RegisterAndAddMetricStoreForGVR(ctx, "test", gvr, "")
+			tc.stop(&m, cancel, channel)
+			if !tc.closes {
+				// Callers may still close the channel
+				close(channel)
+			}
+
+			deadline := time.Now().Add(10 * time.Second)
+			for {
+				rec := httptest.NewRecorder()
+				m.ServeHTTP(rec, httptest.NewRequest("GET", "/x-metrics", nil))
+				if !strings.Contains(rec.Body.String(), "# TYPE test gauge") {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("\n%s\nServeHTTP(...): want no metrics of stopped store, got:\n%s", tc.reason, rec.Body.String())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestReadyzCheck(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		synced []bool
+		failed []bool
+		want   string
+	}{
+		"NoStores": {
+			reason: "Should be ready without registered stores.",
+			want:   "",
+		},
+		"Synced": {
+			reason: "Should be ready if all stores completed their initial list.",
+			synced: []bool{true, true},
+			want:   "",
+		},
+		"Pending": {
+			reason: "Should not be ready until all stores completed their initial list.",
+			synced: []bool{true, false},
+			want:   "metric stores not synced: store1",
+		},
+		"Failed": {
+			reason: "Should be ready if a store never syncs because its lists fail.",
+			synced: []bool{true, false},
+			failed: []bool{false, true},
+			want:   "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{})
+			for i, synced := range tc.synced {
+				name := fmt.Sprintf("store%d", i)
+				s := newInstrumentedStore(newMetricsStore(name, "", "", ResourceConfig{}, nil, nil), schema.GroupVersionResource{Group: "test.x-metrics.io", Version: "v1", Resource: name})
+				if synced {
+					_ = s.Replace(nil, "1")
+				}
+				if i < len(tc.failed) && tc.failed[i] {
+					s.setError(errors.New("customresourcedefinitions.apiextensions.k8s.io is forbidden"))
+				}
+				m.addMetricStore(name, func() {}, s)
+			}
+			got := ""
+			if err := m.ReadyzCheck(nil); err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nReadyzCheck(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSetSharding(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	sharding := Sharding{Shard: 0, TotalShards: 2}
+	var objs []runtime.Object
+	var want []string
+	for i := 0; i < 6; i++ {
+		u := &unstructured.Unstructured{Object: map[string]any{}}
+		u.SetAPIVersion("rds.aws.upbound.io/v1beta1")
+		u.SetKind("Instance")
+		u.SetName(fmt.Sprintf("obj-%d", i))
+		u.SetUID(types.UID(u.GetName()))
+		objs = append(objs, u)
+		if sharding.owns(u.GetUID()) {
+			want = append(want, fmt.Sprintf(`test{name="%s"} 1`, u.GetName()))
+		}
+	}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"}, objs...)
+
+	m := NewManagedMetricsHandler(dc, Config{})
+	channel := m.RegisterAndAddMetricStoreForGVR(context.Background(), "test", gvr, "")
+	defer close(channel)
+
+	series := func() []string {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", "/x-metrics?include=test", nil))
+		var got []string
+		for _, l := range strings.Split(rec.Body.String(), "\n") {
+			if strings.HasPrefix(l, "test{") {
+				got = append(got, l)
+			}
+		}
+		sort.Strings(got)
+		return got
+	}
+	waitFor := func(n int) []string {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			got := series()
+			if len(got) == n || time.Now().After(deadline) {
+				return got
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	if got := waitFor(len(objs)); len(got) != len(objs) {
+		t.Fatalf("RegisterAndAddMetricStoreForGVR(...): want %d series, got %v", len(objs), got)
+	}
+	m.SetSharding(sharding)
+	if diff := cmp.Diff(want, waitFor(len(want))); diff != "" {
+		t.Errorf("SetSharding(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"})
+	instances := ResourceConfig{Group: gvr.Group, Resource: gvr.Resource, ResourceOptions: ResourceOptions{
+		InfoMappings: []InfoMappings{{FieldPath: "spec.forProvider.region", Label: "region"}},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		// explicit registers the store with an explicit configuration
+		explicit bool
+		config   Config
+		want     bool
+	}{
+		"Unchanged": {
+			reason: "Should not restart stores whose configuration didn't change.",
+			config: Config{Resources: []ResourceConfig{{Group: "ec2.aws.upbound.io", Resource: "vpcs", ResourceOptions: instances.ResourceOptions}}},
+			want:   false,
+		},
+		"ResourceChanged": {
+			reason: "Should restart stores whose resource configuration changed.",
+			config: Config{Resources: []ResourceConfig{instances}},
+			want:   true,
+		},
+		"NamingChanged": {
+			reason: "Should restart all stores if the naming changed.",
+			config: Config{Naming: Naming{Prefix: "x_"}},
+			want:   true,
+		},
+		"ExplicitUnchanged": {
+			reason:   "Should not apply resource configurations to stores registered with an explicit configuration.",
+			explicit: true,
+			config:   Config{Resources: []ResourceConfig{instances}},
+			want:     false,
+		},
+		"ExplicitDefaultsChanged": {
+			reason:   "Should apply changed defaults to stores registered with an explicit configuration.",
+			explicit: true,
+			config:   Config{Defaults: ResourceOptions{ConditionMessages: pointer.Bool(true)}},
+			want:     true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(dc, Config{})
+			var channel chan struct{}
+			if tc.explicit {
+				channel = m.RegisterAndAddMetricStore(context.Background(), "test", gvr, "", ResourceConfig{})
+			} else {
+				channel = m.RegisterAndAddMetricStoreForGVR(context.Background(), "test", gvr, "")
+			}
+			defer close(channel)
+
+			before := m.metricsWriter["test"]
+			m.SetConfig(context.Background(), tc.config)
+			got := m.metricsWriter["test"] != before
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nSetConfig(...): -want restarted, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSetConfigWatched(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"})
+	watched := Config{Resources: []ResourceConfig{{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Kind: "Instance", Watch: true}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewManagedMetricsHandler(dc, Config{})
+	registered := func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		_, ok := m.metricsWriter["rds_aws_upbound_io_Instance_v1beta1"]
+		return ok
+	}
+	m.SetConfig(ctx, watched)
+	if !registered() {
+		t.Fatalf("SetConfig(...): want store of the watched resource registered")
+	}
+	before := m.metricsWriter["rds_aws_upbound_io_Instance_v1beta1"]
+	m.SetConfig(ctx, watched)
+	if m.metricsWriter["rds_aws_upbound_io_Instance_v1beta1"] != before {
+		t.Errorf("SetConfig(...): want store of a still watched resource kept")
+	}
+	m.SetConfig(ctx, Config{})
+	deadline := time.Now().Add(10 * time.Second)
+	for registered() {
+		if time.Now().After(deadline) {
+			t.Fatalf("SetConfig(...): want store of a resource no longer watched removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMetadataOnly(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	obj := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rds.aws.upbound.io/v1beta1", Kind: "Instance"},
+		ObjectMeta: metav1.ObjectMeta{Name: "obj", UID: "uid", Generation: 2},
+	}
+	scheme := metadatafake.NewTestScheme()
+	if err := metav1.AddMetaToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManagedMetricsHandler(nil, Config{})
+	m.MetadataClient = metadatafake.NewSimpleMetadataClient(scheme, obj)
+	config := ResourceConfig{ResourceOptions: ResourceOptions{MetadataOnly: pointer.Bool(true)}}
+	channel := m.RegisterAndAddMetricStore(context.Background(), "test", gvr, "", config)
+	defer close(channel)
+
+	want := `test_generation{name="obj"} 2`
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", "/x-metrics", nil))
+		if strings.Contains(rec.Body.String(), want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("RegisterAndAddMetricStore(...): want %q from metadata only watch, got:\n%s", want, rec.Body.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestMultiNamespace(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	objs := []runtime.Object{}
+	for _, ns := range []string{"team-a", "team-b", "team-c"} {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("rds.aws.upbound.io/v1beta1")
+		u.SetKind("Instance")
+		u.SetName("db")
+		u.SetNamespace(ns)
+		u.SetUID(types.UID(ns))
+		objs = append(objs, u)
+	}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"}, objs...)
+
+	m := NewManagedMetricsHandler(dc, Config{})
+	config := ResourceConfig{ResourceOptions: ResourceOptions{Namespaces: []string{"team-a", "team-b"}}}
+	channel := m.RegisterAndAddMetricStore(context.Background(), "test", gvr, "", config)
+	defer close(channel)
+
+	want := []string{
+		`# TYPE test gauge`,
+		`test{name="db",namespace="team-a"} 1`,
+		`test{name="db",namespace="team-b"} 1`,
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", "/x-metrics", nil))
+		var got []string
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if line == "# TYPE test gauge" || strings.HasPrefix(line, "test{") {
+				got = append(got, line)
+			}
+		}
+		sort.Strings(got)
+		diff := cmp.Diff(want, got)
+		if diff == "" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("RegisterAndAddMetricStore(...): -want, +got:\n%s", diff)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestNamespaceLabel(t *testing.T) {
+	namespaced := newObject(map[string]any{})
+	namespaced.SetNamespace("team-a")
+
+	cases := map[string]struct {
+		reason string
+		config ResourceConfig
+		obj    *unstructured.Unstructured
+		want   []string
+	}{
+		"Disabled": {
+			reason: "Should not label objects of cluster wide registrations with their namespace by default.",
+			obj:    newObject(map[string]any{}),
+			want:   []string{`test{name="obj"} 1`},
+		},
+		"ClusterScoped": {
+			reason: "Should add an empty namespace label to cluster scoped objects.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{NamespaceLabel: pointer.Bool(true)}},
+			obj:    newObject(map[string]any{}),
+			want:   []string{`test{name="obj",namespace=""} 1`},
+		},
+		"Namespaced": {
+			reason: "Should add the namespace label to namespaced objects.",
+			config: ResourceConfig{ResourceOptions: ResourceOptions{NamespaceLabel: pointer.Bool(true)}},
+			obj:    namespaced,
+			want:   []string{`test{name="obj",namespace="team-a"} 1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familySamples(t, tc.config, tc.obj, "test")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nnewMetricsStore(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMultiCluster(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	newClient := func(name string) *dynamicfake.FakeDynamicClient {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("rds.aws.upbound.io/v1beta1")
+		u.SetKind("Instance")
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"}, u)
+	}
+
+	m := NewManagedMetricsHandler(newClient("hub-db"), Config{ClusterName: "hub"})
+	m.Clusters = []Cluster{{Name: "spoke", Client: newClient("spoke-db")}}
+	channel := m.RegisterAndAddMetricStoreForGVR(context.Background(), "test", gvr, "")
+	defer close(channel)
+
+	want := []string{
+		`# TYPE test gauge`,
+		`test{name="hub-db",cluster="hub"} 1`,
+		`test{name="spoke-db",cluster="spoke"} 1`,
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", "/x-metrics", nil))
+		var got []string
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if line == "# TYPE test gauge" || strings.HasPrefix(line, "test{") {
+				got = append(got, line)
+			}
+		}
+		sort.Strings(got)
+		diff := cmp.Diff(want, got)
+		if diff == "" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("RegisterAndAddMetricStoreForGVR(...): -want, +got:\n%s", diff)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	config := ResourceConfig{
+		Group:    "rds.aws.upbound.io",
+		Version:  "v1beta1",
+		Resource: "instances",
+		Kind:     "Instance",
+		ResourceOptions: ResourceOptions{
+			Help:   map[string]string{"object": "Objects of kind {kind} in {group}", "ready": "Whether the {resource} are ready"},
+			Gauges: []GaugeMappings{{FieldPath: "spec.forProvider.size", Suffix: "size", Help: "Size of the {kind}"}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	newMetricsStore("test", "", "", config, nil, nil).WriteAll(buf)
+
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "# HELP test ") || strings.HasPrefix(line, "# HELP test_ready ") || strings.HasPrefix(line, "# HELP test_size ") || strings.HasPrefix(line, "# HELP test_created ") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"# HELP test Objects of kind Instance in rds.aws.upbound.io",
+		"# HELP test_created Unix creation timestamp",
+		"# HELP test_ready Whether the instances are ready",
+		"# HELP test_size Size of the Instance",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WriteAll(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestDisabledFamilies(t *testing.T) {
+	config := ResourceConfig{ResourceOptions: ResourceOptions{
+		DisabledFamilies: []string{"object", "labels", "created", "management_policy"},
+		Gauges:           []GaugeMappings{{FieldPath: "spec.size", Suffix: "size"}},
+	}}
+	s := newMetricsStore("test", "", "", config, nil, nil)
+	_ = s.Add(newObject(map[string]any{"spec": map[string]any{"size": int64(10)}}))
+	buf := &bytes.Buffer{}
+	s.WriteAll(buf)
+
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			got = append(got, strings.Fields(name)[0])
+		}
+	}
+	want := []string{"test_annotations", "test_info", "test_ready", "test_ready_time", "test_synced", "test_synced_time",
+		"test_condition", "test_status_reason", "test_paused", "test_generation", "test_observed_generation", "test_spec_drift", "test_bound", "test_time_to_ready_seconds", "test_ready_transitions_total", "test_synced_transitions_total", "test_connection_secret", "test_connection_details_published", "test_finalizers", "test_size"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WriteAll(...): -want, +got:\n%s", diff)
+	}
+	if !strings.Contains(buf.String(), `test_size{name="obj"} 10`) {
+		t.Errorf("WriteAll(...): want gauge after disabled families, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// informerKey identifies the objects listed and watched by a shared
+// informer.
+type informerKey struct {
+	cluster       string
+	gvr           schema.GroupVersionResource
+	namespace     string
+	pageSize      int64
+	labelSelector string
+	fieldSelector string
+	sharding      Sharding
+	resync        time.Duration
+	metadataOnly  bool
+	dropSpec      bool
+}
+
+// informers shares a reflector among the stores of the same objects, e.g. the
+// stores of several metric names of a resource, so that the objects are
+// listed and watched once.
+type informers struct {
+	mu        sync.Mutex
+	informers map[informerKey]*sharedInformer
+}
+
+func newInformers() *informers {
+	return &informers{informers: map[informerKey]*sharedInformer{}}
+}
+
+// subscribe adds s to the informer of key. The informer is started by run if
+// it isn't running yet, with a context canceled once its last store
+// unsubscribed. Stores subscribing to a synced informer are replaced with
+// its objects.
+func (i *informers) subscribe(ctx context.Context, key informerKey, s *instrumentedStore, run func(ctx context.Context, inf *sharedInformer)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	inf, ok := i.informers[key]
+	if !ok {
+		// The informer outlives the store starting it, but logs and emits
+		// failure events like it
+		ictx, cancel := context.WithCancel(detach(ctx))
+		inf = &sharedInformer{
+			Store:  cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc),
+			cancel: cancel,
+			stores: map[*instrumentedStore]bool{},
+		}
+		i.informers[key] = inf
+		sharedInformers.Inc()
+		go run(ictx, inf)
+	}
+	inf.subscribe(s)
+}
+
+// unsubscribe removes s from the informer of key, stopping the informer if
+// it was its last store.
+func (i *informers) unsubscribe(key informerKey, s *instrumentedStore) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	inf, ok := i.informers[key]
+	if !ok || inf.unsubscribe(s) > 0 {
+		return
+	}
+	inf.cancel()
+	delete(i.informers, key)
+	sharedInformers.Dec()
+}
+
+// sharedInformer is the store of a shared reflector. It holds the objects
+// listed and watched by the reflector and applies each change to the stores
+// subscribed to it.
+type sharedInformer struct {
+	cache.Store
+	cancel context.CancelFunc
+
+	mu              sync.Mutex
+	stores          map[*instrumentedStore]bool
+	synced          bool
+	resourceVersion string
+	// err is the last failure of the reflector since its last list, set on
+	// stores subscribing later
+	err error
+}
+
+func (i *sharedInformer) subscribe(s *instrumentedStore) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stores[s] = true
+	if i.synced {
+		_ = s.Replace(i.Store.List(), i.resourceVersion)
+	}
+	if i.err != nil {
+		s.setError(i.err)
+	}
+}
+
+// setError records a failure of the reflector on the stores of the informer.
+func (i *sharedInformer) setError(err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.err = err
+	for s := range i.stores {
+		s.setError(err)
+	}
+}
+
+// unsubscribe removes s and returns the number of remaining stores.
+func (i *sharedInformer) unsubscribe(s *instrumentedStore) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.stores, s)
+	return len(i.stores)
+}
+
+func (i *sharedInformer) Add(obj any) error {
+	return i.apply(func(s cache.Store) error { return s.Add(obj) })
+}
+
+func (i *sharedInformer) Update(obj any) error {
+	return i.apply(func(s cache.Store) error { return s.Update(obj) })
+}
+
+func (i *sharedInformer) Delete(obj any) error {
+	return i.apply(func(s cache.Store) error { return s.Delete(obj) })
+}
+
+func (i *sharedInformer) Replace(list []any, resourceVersion string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.synced, i.resourceVersion, i.err = true, resourceVersion, nil
+	return i.each(func(s cache.Store) error { return s.Replace(list, resourceVersion) })
+}
+
+// UpdateResourceVersion keeps the resource version replayed to new stores
+// recent, e.g. after bookmarks. It implements cache.ResourceVersionUpdater.
+func (i *sharedInformer) UpdateResourceVersion(resourceVersion string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.resourceVersion = resourceVersion
+}
+
+func (i *sharedInformer) apply(fn func(s cache.Store) error) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	errs := []error{i.each(fn)}
+	for s := range i.stores {
+		errs = append(errs, s.regenerateFamilies(i.Store.List, i.resourceVersion))
+	}
+	return errors.Join(errs...)
+}
+
+// each applies a change to the objects of the informer and to its stores.
+// i.mu must be held.
+func (i *sharedInformer) each(fn func(s cache.Store) error) error {
+	errs := []error{fn(i.Store)}
+	for s := range i.stores {
+		errs = append(errs, fn(s))
+	}
+	return errors.Join(errs...)
+}
+
+// recordSync records a sync of all stores.
+func (i *sharedInformer) recordSync() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for s := range i.stores {
+		s.recordSync()
+	}
+}
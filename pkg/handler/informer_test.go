@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestSharedInformer(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("rds.aws.upbound.io/v1beta1")
+	u.SetKind("Instance")
+	u.SetName("db")
+	u.SetUID("uid")
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"}, u)
+
+	m := NewManagedMetricsHandler(dc, Config{})
+	first := m.RegisterAndAddMetricStoreForGVR(context.Background(), "first", gvr, "")
+	waitForBody(t, &m, `first{name="db"} 1`)
+	second := m.RegisterAndAddMetricStoreForGVR(context.Background(), "second", gvr, "")
+	// The second store is replaced with the objects of the shared informer
+	waitForBody(t, &m, `second{name="db"} 1`)
+
+	lists := 0
+	for _, a := range dc.Actions() {
+		if a.GetVerb() == "list" {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Errorf("RegisterAndAddMetricStoreForGVR(...): want a single list of the resource, got %d", lists)
+	}
+	if got := len(m.informers.informers); got != 1 {
+		t.Errorf("RegisterAndAddMetricStoreForGVR(...): want a single informer, got %d", got)
+	}
+
+	m.Stop("first")
+	close(first)
+	if got := len(m.informers.informers); got != 1 {
+		t.Errorf("Stop(...): want the informer kept for the remaining store, got %d informers", got)
+	}
+	m.Stop("second")
+	close(second)
+	if got := len(m.informers.informers); got != 0 {
+		t.Errorf("Stop(...): want the informer stopped with its last store, got %d informers", got)
+	}
+}
+
+// waitForBody waits until the handler serves want.
+func waitForBody(t *testing.T, m *ManagedMetricsHandler, want string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", "/x-metrics", nil))
+		if strings.Contains(rec.Body.String(), want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ServeHTTP(...): want %q, got:\n%s", want, rec.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
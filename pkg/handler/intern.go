@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync"
+)
+
+// maxInternedNames caps the label names interned per store, e.g. against
+// objects with generated label names.
+const maxInternedNames = 4096
+
+// keyCache interns the label keys of the series of a store, so that keys
+// derived from the label and annotation names of objects and the keys of the
+// series of a family are not allocated for each object and update.
+type keyCache struct {
+	mu    sync.Mutex
+	names map[labelName]string
+	// series holds the keys of the last series of each family, following
+	// the keys identifying the object
+	series []seriesKeys
+}
+
+// labelName is a label or annotation name of an object with the prefix of its
+// label key.
+type labelName struct {
+	prefix string
+	name   string
+}
+
+// seriesKeys are the keys of a series, own, and the same following the keys
+// identifying the object.
+type seriesKeys struct {
+	own  []string
+	keys []string
+}
+
+func newKeyCache(families int) *keyCache {
+	return &keyCache{names: map[labelName]string{}, series: make([]seriesKeys, families)}
+}
+
+// name returns the label key of name, e.g. label_app_kubernetes_io_name for
+// the prefix label_ and the name app.kubernetes.io/name.
+func (c *keyCache) name(prefix, name string) string {
+	if c == nil {
+		return prefix + GetValidLabel(name)
+	}
+	n := labelName{prefix: prefix, name: name}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.names[n]; ok {
+		return key
+	}
+	key := prefix + GetValidLabel(name)
+	if len(c.names) < maxInternedNames {
+		c.names[n] = key
+	}
+	return key
+}
+
+// keys returns the keys identifying the object followed by the keys of a
+// series of a family. The returned slice is shared by the series of the
+// family and must not be modified.
+func (c *keyCache) keys(family int, object, own []string) []string {
+	if len(own) == 0 {
+		return object
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last := c.series[family]; equalStrings(last.own, own) {
+		return last.keys
+	}
+	keys := appendLabels(object, own...)
+	c.series[family] = seriesKeys{own: own, keys: keys}
+	return keys
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
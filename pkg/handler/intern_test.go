@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestKeyCache(t *testing.T) {
+	c := newKeyCache(1)
+	object := []string{"name", "namespace"}
+
+	if diff := cmp.Diff("label_app_kubernetes_io_name", c.name("label_", "app.kubernetes.io/name")); diff != "" {
+		t.Errorf("\nShould return the label key of a name.\nname(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(object, c.keys(0, object, nil)); diff != "" {
+		t.Errorf("\nShould return the object keys for series without keys of their own.\nkeys(...): -want, +got:\n%s", diff)
+	}
+	first := c.keys(0, object, []string{"finalizer"})
+	second := c.keys(0, object, []string{"finalizer"})
+	if diff := cmp.Diff([]string{"name", "namespace", "finalizer"}, second); diff != "" {
+		t.Errorf("\nShould append the keys of the series to the object keys.\nkeys(...): -want, +got:\n%s", diff)
+	}
+	if &first[0] != &second[0] {
+		t.Errorf("keys(...): want the keys of series with the same keys to be shared")
+	}
+	if diff := cmp.Diff([]string{"name", "namespace", "reason"}, c.keys(0, object, []string{"reason"})); diff != "" {
+		t.Errorf("\nShould not share the keys of series with other keys.\nkeys(...): -want, +got:\n%s", diff)
+	}
+}
+
+// benchmarkObject returns an object with labels and annotations, as added to
+// stores on each update.
+func benchmarkObject(i int) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "rds.aws.upbound.io/v1beta1",
+		"kind":       "Instance",
+		"spec":       map[string]any{"forProvider": map[string]any{"region": "eu-central-1"}},
+		"status": map[string]any{"conditions": []any{
+			map[string]any{"type": "Ready", "status": "True", "reason": "Available"},
+			map[string]any{"type": "Synced", "status": "True", "reason": "ReconcileSuccess"},
+		}},
+	}}
+	u.SetName("instance-" + strconv.Itoa(i))
+	u.SetNamespace("default")
+	u.SetUID(types.UID(strconv.Itoa(i)))
+	u.SetFinalizers([]string{"finalizer.managedresource.crossplane.io"})
+	labels := map[string]string{}
+	annotations := map[string]string{}
+	for j := 0; j < 10; j++ {
+		labels[fmt.Sprintf("example.com/label-%d", j)] = "value"
+		annotations[fmt.Sprintf("example.com/annotation-%d", j)] = "value"
+	}
+	u.SetLabels(labels)
+	u.SetAnnotations(annotations)
+	return u
+}
+
+func BenchmarkMetricsStore(b *testing.B) {
+	config := ResourceConfig{ResourceOptions: ResourceOptions{
+		AnnotationsAllowlist: []string{"example.com/*"},
+		InfoMappings:         []InfoMappings{{FieldPath: "spec.forProvider.region", Label: "region"}},
+	}}
+	objects := make([]*unstructured.Unstructured, 1000)
+	for i := range objects {
+		objects[i] = benchmarkObject(i)
+	}
+
+	b.Run("Add", func(b *testing.B) {
+		s := newMetricsStore("instance", "default", "", config, nil, nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Objects without resourceVersion bypass the family cache
+			_ = s.Add(objects[i%len(objects)])
+		}
+	})
+	b.Run("WriteAll", func(b *testing.B) {
+		s := newMetricsStore("instance", "default", "", config, nil, nil)
+		for _, o := range objects {
+			_ = s.Add(o)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.WriteAll(io.Discard)
+		}
+	})
+}
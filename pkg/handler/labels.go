@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+)
+
+// staticLabels are labels added to every series written, sorted by key.
+type staticLabels []staticLabel
+
+type staticLabel struct {
+	key string
+	// pair is the rendered key="value" pair
+	pair []byte
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func newStaticLabels(labels map[string]string) staticLabels {
+	l := make(staticLabels, 0, len(labels))
+	for k, v := range labels {
+		l = append(l, staticLabel{key: k, pair: []byte(k + `="` + labelValueEscaper.Replace(v) + `"`)})
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].key < l[j].key })
+	return l
+}
+
+// ReservedInfoLabel returns true if name is a label of the _info family set
+// by x-metrics itself, which info mappings must not use, as duplicate label
+// names fail the whole scrape.
+func ReservedInfoLabel(name string) bool {
+	switch name {
+	case "name", "namespace", "uid", "cluster", "external_name", "owner_kind", "owner_name":
+		return true
+	}
+	for _, r := range referenceLabels {
+		if r.Label == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidLabelName returns true if name is a valid Prometheus label name not
+// reserved for internal use.
+func ValidLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// writer returns a writer adding the labels to the series written to it.
+// Labels already set on a series take precedence.
+func (l staticLabels) writer(w io.Writer) io.Writer {
+	if len(l) == 0 {
+		return w
+	}
+	return &lineWriter{w: w, fn: (&seriesLabeler{labels: l}).label}
+}
+
+// seriesLabeler adds labels to series lines.
+type seriesLabeler struct {
+	labels staticLabels
+	buf    []byte
+}
+
+// label returns line with the labels missing on the series added. Comments
+// are returned unchanged.
+func (sl *seriesLabeler) label(line []byte) []byte {
+	if len(line) == 0 || line[0] == '#' || line[0] == '\n' {
+		return line
+	}
+	end := bytes.IndexAny(line, "{ ")
+	if end < 0 {
+		return line
+	}
+	var present []string
+	if line[end] == '{' {
+		present = labelKeys(line[end+1:])
+	}
+
+	sl.buf = append(sl.buf[:0], line[:end]...)
+	sl.buf = append(sl.buf, '{')
+	added := 0
+	for _, l := range sl.labels {
+		if containsString(present, l.key) {
+			continue
+		}
+		if added > 0 {
+			sl.buf = append(sl.buf, ',')
+		}
+		sl.buf = append(sl.buf, l.pair...)
+		added++
+	}
+	if added == 0 {
+		return line
+	}
+	if line[end] == ' ' {
+		sl.buf = append(sl.buf, '}')
+		return append(sl.buf, line[end:]...)
+	}
+	if line[end+1] != '}' {
+		sl.buf = append(sl.buf, ',')
+	}
+	return append(sl.buf, line[end+1:]...)
+}
+
+// labelKeys returns the label keys of the label set following the opening
+// brace of a series.
+func labelKeys(s []byte) []string {
+	var keys []string
+	for i := 0; i < len(s) && s[i] != '}'; {
+		eq := bytes.IndexByte(s[i:], '=')
+		if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return keys
+		}
+		keys = append(keys, string(s[i:i+eq]))
+		// skip the quoted value
+		j := i + eq + 2
+		for ; j < len(s) && s[j] != '"'; j++ {
+			if s[j] == '\\' {
+				j++
+			}
+		}
+		i = j + 1
+		if i < len(s) && s[i] == ',' {
+			i++
+		}
+	}
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStaticLabels(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		labels map[string]string
+		in     string
+		want   string
+	}{
+		"None": {
+			reason: "Should write the series unchanged without labels.",
+			in:     "# TYPE a gauge\na{name=\"x\"} 1\n",
+			want:   "# TYPE a gauge\na{name=\"x\"} 1\n",
+		},
+		"Labels": {
+			reason: "Should add the labels to series with and without labels.",
+			labels: map[string]string{"region": "eu", "environment": "prod"},
+			in:     "# HELP a Objects.\n# TYPE a gauge\na{name=\"x\"} 1\nb 2\nc{} 3\n",
+			want:   "# HELP a Objects.\n# TYPE a gauge\na{environment=\"prod\",region=\"eu\",name=\"x\"} 1\nb{environment=\"prod\",region=\"eu\"} 2\nc{environment=\"prod\",region=\"eu\"} 3\n",
+		},
+		"SeriesLabelsTakePrecedence": {
+			reason: "Should not override labels of the series, even after escaped quotes.",
+			labels: map[string]string{"cluster": "hub", "environment": "prod"},
+			in:     "a{name=\"x\\\",cluster=\\\"y\",cluster=\"spoke\"} 1\n",
+			want:   "a{environment=\"prod\",name=\"x\\\",cluster=\\\"y\",cluster=\"spoke\"} 1\n",
+		},
+		"Escaping": {
+			reason: "Should escape the label values.",
+			labels: map[string]string{"team": "a\"b"},
+			in:     "a 1\n",
+			want:   "a{team=\"a\\\"b\"} 1\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := newStaticLabels(tc.labels).writer(buf)
+			// write in two parts to cover incomplete lines
+			in := []byte(tc.in)
+			_, _ = w.Write(in[:len(in)/2])
+			_, _ = w.Write(in[len(in)/2:])
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("\n%s\nwriter(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
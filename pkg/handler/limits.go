@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StoreLimits protect x-metrics from resources with far more objects than
+// expected, which would otherwise exhaust its memory.
+type StoreLimits struct {
+	// MaxObjects is the maximum number of objects exported by a store.
+	// Further objects are ignored until objects are deleted. Unlimited if 0
+	MaxObjects int `json:"maxObjects,omitempty"`
+	// ShedAbove is the number of objects of a store above which the
+	// ShedFamilies are dropped for all of its objects, to export more
+	// objects with the same memory. Disabled if 0
+	ShedAbove int `json:"shedAbove,omitempty"`
+	// ShedFamilies are the families dropped above ShedAbove, keyed like
+	// DisabledFamilies. Defaults to labels, annotations and info
+	ShedFamilies []string `json:"shedFamilies,omitempty"`
+}
+
+// defaultShedFamilies are the families of most series per object.
+var defaultShedFamilies = []string{"labels", "annotations", "info"}
+
+func (l StoreLimits) validate() error {
+	if l.MaxObjects < 0 {
+		return fmt.Errorf("maxObjects: must not be negative")
+	}
+	if l.ShedAbove < 0 {
+		return fmt.Errorf("shedAbove: must not be negative")
+	}
+	for j, f := range l.ShedFamilies {
+		if !knownFamily(f) {
+			return fmt.Errorf("shedFamilies[%d]: unknown family %q", j, f)
+		}
+	}
+	return nil
+}
+
+// shedFamilies returns the indexes of the families of metricName dropped
+// above ShedAbove.
+func (l *StoreLimits) shedFamilies(metricName string, families []storeFamily) []int {
+	if l == nil || l.ShedAbove <= 0 {
+		return nil
+	}
+	keys := l.ShedFamilies
+	if len(keys) == 0 {
+		keys = defaultShedFamilies
+	}
+	var shed []int
+	for i, f := range families {
+		key := strings.TrimPrefix(strings.TrimPrefix(f.name, metricName), "_")
+		if key == "" {
+			key = "object"
+		}
+		for _, k := range keys {
+			if k == key {
+				shed = append(shed, i)
+			}
+		}
+	}
+	return shed
+}
+
+// shedding returns whether a store of n objects drops its shed families.
+// Once shedding, a store keeps doing so until it shrinks by a tenth below
+// ShedAbove, so that it doesn't regenerate the families of all objects
+// repeatedly around the limit.
+func (l *StoreLimits) shedding(n int, shedding bool) bool {
+	if l == nil || l.ShedAbove <= 0 {
+		return false
+	}
+	if shedding {
+		return n > l.ShedAbove-l.ShedAbove/10
+	}
+	return n > l.ShedAbove
+}
+
+// maxObjects returns the maximum number of objects of a store, 0 if
+// unlimited.
+func (l *StoreLimits) maxObjects() int {
+	if l == nil {
+		return 0
+	}
+	return l.MaxObjects
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestMaxObjects(t *testing.T) {
+	newNamed := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{}}
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		return u
+	}
+	a, b, c, d := newNamed("a"), newNamed("b"), newNamed("c"), newNamed("d")
+
+	type want struct {
+		objects []string
+		dropped float64
+	}
+	cases := map[string]struct {
+		reason string
+		ops    func(s *instrumentedStore)
+		want   want
+	}{
+		"Replace": {
+			reason: "Should keep the first objects of a list and drop the others.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b, c}, "1")
+			},
+			want: want{objects: []string{"a", "b"}, dropped: 1},
+		},
+		"Add": {
+			reason: "Should ignore objects added beyond the limit.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Add(a)
+				_ = s.Add(b)
+				_ = s.Add(c)
+				_ = s.Update(c)
+			},
+			want: want{objects: []string{"a", "b"}, dropped: 1},
+		},
+		"AddAfterReplace": {
+			reason: "Should count the objects added beyond the limit in addition to those dropped by a list.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b, c}, "1")
+				_ = s.Add(d)
+				_ = s.Update(d)
+			},
+			want: want{objects: []string{"a", "b"}, dropped: 2},
+		},
+		"Admit": {
+			reason: "Should export a dropped object on its next update once an object was deleted.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b, c}, "1")
+				_ = s.Delete(a)
+				_ = s.Update(c)
+			},
+			want: want{objects: []string{"b", "c"}, dropped: 0},
+		},
+		"DeleteDropped": {
+			reason: "Should forget deleted objects that were dropped.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b, c}, "1")
+				_ = s.Delete(c)
+			},
+			want: want{objects: []string{"a", "b"}, dropped: 0},
+		},
+		"Stop": {
+			reason: "Should not count the dropped objects of stopped stores.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b, c}, "1")
+				s.stop()
+			},
+			want: want{dropped: 0},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gvr := schema.GroupVersionResource{Group: "limits.x-metrics.io", Version: "v1", Resource: name}
+			s := newInstrumentedStore(newMetricsStore("test", "", "", ResourceConfig{}, nil, nil), gvr)
+			s.limits = &StoreLimits{MaxObjects: 2}
+			tc.ops(s)
+
+			buf := &bytes.Buffer{}
+			s.metrics.WriteAll(buf)
+			var objects []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if rest, ok := strings.CutPrefix(line, `test{name="`); ok {
+					objects = append(objects, rest[:strings.Index(rest, `"`)])
+				}
+			}
+			sort.Strings(objects)
+			dropped := testutil.ToFloat64(droppedObjects.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource))
+			if diff := cmp.Diff(tc.want, want{objects: objects, dropped: dropped}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nMaxObjects: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestShedFamilies(t *testing.T) {
+	newLabeled := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{}}
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		u.SetResourceVersion("1")
+		u.SetLabels(map[string]string{"team": "platform"})
+		return u
+	}
+	a, b, c := newLabeled("a"), newLabeled("b"), newLabeled("c")
+
+	gvr := schema.GroupVersionResource{Group: "limits.x-metrics.io", Version: "v1", Resource: "shed"}
+	config := ResourceConfig{ResourceOptions: ResourceOptions{Limits: &StoreLimits{ShedAbove: 2}}}
+	fc := newFamilyCache()
+	s := newInstrumentedStore(newMetricsStore("test", "", "", config, nil, fc), gvr)
+	s.families, s.limits = fc, config.Limits
+	i := &sharedInformer{Store: cache.NewStore(cache.MetaNamespaceKeyFunc), stores: map[*instrumentedStore]bool{s: true}}
+
+	labels := func() int {
+		buf := &bytes.Buffer{}
+		s.metrics.WriteAll(buf)
+		return strings.Count(buf.String(), "test_labels{")
+	}
+	_ = i.Replace([]any{a, b}, "1")
+	if got := labels(); got != 2 {
+		t.Errorf("Replace(...): want the labels of 2 objects, got %d", got)
+	}
+	_ = i.Add(c)
+	if got := labels(); got != 0 {
+		t.Errorf("Add(...): want the labels of all objects shed above shedAbove, got %d", got)
+	}
+	if got := testutil.ToFloat64(sheddingStores.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)); got != 1 {
+		t.Errorf("Add(...): want x_metrics_shedding_stores 1, got %v", got)
+	}
+	_ = i.Delete(c)
+	if got := labels(); got != 2 {
+		t.Errorf("Delete(...): want the labels of the remaining objects again, got %d", got)
+	}
+}
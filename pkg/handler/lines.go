@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"io"
+)
+
+// lineWriter writes the lines written to it to w, transformed by fn. fn is
+// called with complete lines including the newline, and drops a line by
+// returning an empty slice. The returned slice is written before fn is called
+// again, so it may be reused.
+type lineWriter struct {
+	w  io.Writer
+	fn func(line []byte) []byte
+	// line holds an incomplete line of the last write
+	line []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			lw.line = append(lw.line, p...)
+			break
+		}
+		line := p[:i+1]
+		if len(lw.line) > 0 {
+			line = append(lw.line, line...)
+			lw.line = lw.line[:0]
+		}
+		p = p[i+1:]
+
+		if line = lw.fn(line); len(line) == 0 {
+			continue
+		}
+		if _, err := lw.w.Write(line); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLineWriter(t *testing.T) {
+	upper := func(line []byte) []byte { return bytes.ToUpper(line) }
+	dropComments := func(line []byte) []byte {
+		if line[0] == '#' {
+			return nil
+		}
+		return line
+	}
+
+	cases := map[string]struct {
+		reason string
+		fn     func([]byte) []byte
+		writes []string
+		want   string
+	}{
+		"Lines": {
+			reason: "Should transform each line.",
+			fn:     upper,
+			writes: []string{"a 1\nb 2\n"},
+			want:   "A 1\nB 2\n",
+		},
+		"IncompleteLines": {
+			reason: "Should transform lines split across writes once they are complete.",
+			fn:     upper,
+			writes: []string{"a", " 1\nb ", "2", "\n"},
+			want:   "A 1\nB 2\n",
+		},
+		"TrailingIncompleteLine": {
+			reason: "Should hold back an incomplete last line.",
+			fn:     upper,
+			writes: []string{"a 1\nb"},
+			want:   "A 1\n",
+		},
+		"Dropped": {
+			reason: "Should drop lines transformed to an empty slice.",
+			fn:     dropComments,
+			writes: []string{"# TYPE a gauge\na 1\n"},
+			want:   "a 1\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := &lineWriter{w: buf, fn: tc.fn}
+			for _, s := range tc.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q): want %d, nil, got %d, %v", s, len(s), n, err)
+				}
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("\n%s\nWrite(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// listWatchOptions configure how a resource is listed and watched.
+type listWatchOptions struct {
+	// pageSize is the number of objects listed per request, if positive
+	pageSize      int64
+	labelSelector string
+	fieldSelector string
+	// transform is applied to all listed and watched objects
+	transform transformFunc
+	// sharding drops objects not owned by the shard
+	sharding Sharding
+	// timeout cancels list requests, if positive
+	timeout time.Duration
+}
+
+// list returns the options of a list request of the reflector.
+func (o listWatchOptions) list(opt metav1.ListOptions) metav1.ListOptions {
+	if o.pageSize > 0 && opt.ResourceVersion == "0" {
+		// The watch cache of the API server ignores the limit of lists
+		// at resource version 0, so list the pages from etcd instead
+		opt.ResourceVersion = ""
+	}
+	return o.watch(opt)
+}
+
+// listContext returns the context of a list request, canceled after the
+// timeout if positive. Watches aren't canceled, as they last until the API
+// server ends them.
+func (o listWatchOptions) listContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return ctx, func() {}
+}
+
+// watch returns the options of a watch request of the reflector.
+func (o listWatchOptions) watch(opt metav1.ListOptions) metav1.ListOptions {
+	opt.LabelSelector = o.labelSelector
+	opt.FieldSelector = o.fieldSelector
+	return opt
+}
+
+// newListWatch returns a ListWatch of the resource. The reflector lists in
+// pages, continuing with the options it passes. Listed and watched objects are
+// transformed, so that removed fields are freed before all pages are listed.
+func newListWatch(ctx context.Context, ri dynamic.ResourceInterface, opts listWatchOptions) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(opt metav1.ListOptions) (runtime.Object, error) {
+			ctx, cancel := opts.listContext(ctx)
+			defer cancel()
+			list, err := ri.List(ctx, opts.list(opt))
+			if err != nil {
+				return nil, err
+			}
+			shardList(list, opts.sharding)
+			transformList(list, opts.transform)
+			return list, nil
+		},
+		WatchFunc: func(opt metav1.ListOptions) (watch.Interface, error) {
+			w, err := ri.Watch(ctx, opts.watch(opt))
+			if err != nil {
+				return nil, err
+			}
+			return transformWatch(w, opts.transform, opts.sharding), nil
+		},
+	}
+}
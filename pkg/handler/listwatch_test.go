@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// listRecorder records the options of list requests.
+type listRecorder struct {
+	dynamic.ResourceInterface
+	options []metav1.ListOptions
+}
+
+func (l *listRecorder) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	l.options = append(l.options, opts)
+	return &unstructured.UnstructuredList{}, nil
+}
+
+func TestListWatchOptions(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		opts   listWatchOptions
+		list   metav1.ListOptions
+		want   metav1.ListOptions
+	}{
+		"InitialList": {
+			reason: "Should list pages from etcd instead of the watch cache, which ignores the limit.",
+			opts:   listWatchOptions{pageSize: 500},
+			list:   metav1.ListOptions{ResourceVersion: "0", Limit: 500},
+			want:   metav1.ListOptions{Limit: 500},
+		},
+		"Continue": {
+			reason: "Should pass the continue token of subsequent pages.",
+			opts:   listWatchOptions{pageSize: 500},
+			list:   metav1.ListOptions{Limit: 500, Continue: "token"},
+			want:   metav1.ListOptions{Limit: 500, Continue: "token"},
+		},
+		"Relist": {
+			reason: "Should keep the resource version of relists.",
+			opts:   listWatchOptions{pageSize: 500},
+			list:   metav1.ListOptions{ResourceVersion: "42"},
+			want:   metav1.ListOptions{ResourceVersion: "42"},
+		},
+		"Unpaginated": {
+			reason: "Should list from the watch cache if pagination is disabled.",
+			opts:   listWatchOptions{pageSize: -1},
+			list:   metav1.ListOptions{ResourceVersion: "0"},
+			want:   metav1.ListOptions{ResourceVersion: "0"},
+		},
+		"Selectors": {
+			reason: "Should add the label and field selectors.",
+			opts:   listWatchOptions{labelSelector: "environment=prod", fieldSelector: "metadata.name=db"},
+			list:   metav1.ListOptions{ResourceVersion: "42"},
+			want:   metav1.ListOptions{ResourceVersion: "42", LabelSelector: "environment=prod", FieldSelector: "metadata.name=db"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ri := &listRecorder{}
+			if _, err := newListWatch(context.Background(), ri, tc.opts).List(tc.list); err != nil {
+				t.Fatalf("List(...): %v", err)
+			}
+			if diff := cmp.Diff([]metav1.ListOptions{tc.want}, ri.options); diff != "" {
+				t.Errorf("\n%s\nList(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
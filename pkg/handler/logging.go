@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// logger is the part of a logr.Logger used to log the lists and watches of a
+// store.
+type logger interface {
+	Info(msg string, keysAndValues ...any)
+	Error(err error, msg string, keysAndValues ...any)
+}
+
+type debugLogKey struct{}
+
+// withStoreLog returns a context logging with keysAndValues identifying a
+// store. Debug messages of the store are logged at verbosity 1, or at 0 if
+// debug is set, e.g. for a store raised by WithDebugStores.
+func withStoreLog(ctx context.Context, debug bool, keysAndValues ...any) context.Context {
+	l := log.FromContext(ctx).WithValues(keysAndValues...)
+	d := l.V(1)
+	if debug {
+		d = l
+	}
+	return context.WithValue(log.IntoContext(ctx, l), debugLogKey{}, d)
+}
+
+// debugLog returns the logger of debug messages of the store of ctx.
+func debugLog(ctx context.Context) logger {
+	if d, ok := ctx.Value(debugLogKey{}).(logger); ok {
+		return d
+	}
+	return log.FromContext(ctx).V(1)
+}
+
+// detach returns a background context logging and emitting failure events
+// like ctx.
+func detach(ctx context.Context) context.Context {
+	detached := context.WithValue(log.IntoContext(context.Background(), log.FromContext(ctx)), debugLogKey{}, debugLog(ctx))
+	if e, ok := ctx.Value(failureEventsKey{}).(*failureEvents); ok {
+		detached = context.WithValue(detached, failureEventsKey{}, e)
+	}
+	return detached
+}
+
+// debugStore returns true if the store of metricName for gvr logs its debug
+// messages without raising the verbosity of all stores.
+func (m *ManagedMetricsHandler) debugStore(metricName string, gvr schema.GroupVersionResource) bool {
+	for _, name := range m.debugStores {
+		if name == metricName || name == gvr.GroupResource().String() {
+			return true
+		}
+	}
+	return false
+}
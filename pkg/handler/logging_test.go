@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDebugStore(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+
+	cases := map[string]struct {
+		reason     string
+		opts       []Option
+		metricName string
+		want       bool
+	}{
+		"None": {
+			reason:     "Should not raise the verbosity of stores by default.",
+			metricName: "instances",
+		},
+		"MetricName": {
+			reason:     "Should raise the verbosity of a store named by its metric name.",
+			opts:       []Option{WithDebugStores("instances")},
+			metricName: "instances",
+			want:       true,
+		},
+		"Resource": {
+			reason:     "Should raise the verbosity of all stores of a resource named as resource.group.",
+			opts:       []Option{WithDebugStores("instances.rds.aws.upbound.io")},
+			metricName: "databases",
+			want:       true,
+		},
+		"OtherStore": {
+			reason:     "Should not raise the verbosity of other stores.",
+			opts:       []Option{WithDebugStores("buckets")},
+			metricName: "instances",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{}, tc.opts...)
+			if got := m.debugStore(tc.metricName, gvr); got != tc.want {
+				t.Errorf("\n%s\ndebugStore(...): want %t, got %t", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
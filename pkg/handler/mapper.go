@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// mapperResetInterval is the minimum interval between two rediscoveries of
+// the resources of a ResourceMapper
+const mapperResetInterval = 10 * time.Second
+
+// ResourceMapper resolves kinds and short names to resources by the cached
+// discovery information of the API server. The cache is refreshed when a
+// resource is not found, e.g. because its CRD was created after the last
+// discovery.
+type ResourceMapper struct {
+	mapper   *restmapper.DeferredDiscoveryRESTMapper
+	expander meta.RESTMapper
+
+	mu        sync.Mutex
+	lastReset time.Time
+	now       func() time.Time
+}
+
+// NewResourceMapper returns a ResourceMapper discovering the resources by d.
+func NewResourceMapper(d discovery.DiscoveryInterface) *ResourceMapper {
+	cached := memory.NewMemCacheClient(d)
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cached)
+	return &ResourceMapper{
+		mapper:   mapper,
+		expander: restmapper.NewShortcutExpander(mapper, cached),
+		now:      time.Now,
+	}
+}
+
+// ResourceFor returns the preferred version of the resource given as
+// <kind>.<group>, <resource>.<group>, <resource>.<version>.<group> or short
+// name, e.g. Bucket.s3.aws.upbound.io, buckets.s3.aws.upbound.io or bucket.
+func (r *ResourceMapper) ResourceFor(name string) (schema.GroupVersionResource, error) {
+	gvr, err := r.resourceFor(name)
+	if meta.IsNoMatchError(err) && r.reset() {
+		gvr, err = r.resourceFor(name)
+	}
+	return gvr, err
+}
+
+func (r *ResourceMapper) resourceFor(name string) (schema.GroupVersionResource, error) {
+	gvr, gr := schema.ParseResourceArg(name)
+	if gvr != nil {
+		if resolved, err := r.expander.ResourceFor(*gvr); err == nil {
+			return resolved, nil
+		}
+	}
+	return r.expander.ResourceFor(gr.WithVersion(""))
+}
+
+// reset invalidates the discovery cache, unless it was invalidated within
+// mapperResetInterval. It returns true if the cache was invalidated.
+func (r *ResourceMapper) reset() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if now.Sub(r.lastReset) < mapperResetInterval {
+		return false
+	}
+	r.lastReset = now
+	r.mapper.Reset()
+	return true
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestResourceFor(t *testing.T) {
+	buckets := metav1.APIResource{Name: "buckets", SingularName: "bucket", Kind: "Bucket", ShortNames: []string{"bkt"}}
+	d := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "s3.aws.upbound.io/v1beta1", APIResources: []metav1.APIResource{buckets}},
+		{GroupVersion: "s3.aws.upbound.io/v1alpha1", APIResources: []metav1.APIResource{buckets}},
+	}}}
+	v1beta1 := schema.GroupVersionResource{Group: "s3.aws.upbound.io", Version: "v1beta1", Resource: "buckets"}
+
+	type want struct {
+		gvr schema.GroupVersionResource
+		err bool
+	}
+	cases := map[string]struct {
+		reason string
+		name   string
+		want   want
+	}{
+		"Kind": {
+			reason: "Should resolve a kind to the preferred version of its resource.",
+			name:   "Bucket.s3.aws.upbound.io",
+			want:   want{gvr: v1beta1},
+		},
+		"Singular": {
+			reason: "Should resolve the singular name of a resource.",
+			name:   "bucket.s3.aws.upbound.io",
+			want:   want{gvr: v1beta1},
+		},
+		"Version": {
+			reason: "Should resolve a resource in the given version.",
+			name:   "buckets.v1alpha1.s3.aws.upbound.io",
+			want:   want{gvr: schema.GroupVersionResource{Group: "s3.aws.upbound.io", Version: "v1alpha1", Resource: "buckets"}},
+		},
+		"ShortName": {
+			reason: "Should resolve the short name of a resource.",
+			name:   "bkt",
+			want:   want{gvr: v1beta1},
+		},
+		"Unknown": {
+			reason: "Should return an error for unknown kinds.",
+			name:   "Instance.rds.aws.upbound.io",
+			want:   want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gvr, err := NewResourceMapper(d).ResourceFor(tc.name)
+			if diff := cmp.Diff(tc.want, want{gvr: gvr, err: err != nil}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nResourceFor(%q): -want, +got:\n%s", tc.reason, tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestResourceForNewCRD(t *testing.T) {
+	configmaps := &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps", Kind: "ConfigMap"}}}
+	d := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{configmaps}}}
+	now := time.Date(2023, 5, 4, 10, 15, 0, 0, time.UTC)
+	m := NewResourceMapper(d)
+	m.now = func() time.Time { return now }
+
+	if _, err := m.ResourceFor("Bucket.s3.aws.upbound.io"); err == nil {
+		t.Fatalf("ResourceFor(...): want error before the CRD is created")
+	}
+	d.Resources = []*metav1.APIResourceList{
+		configmaps,
+		{GroupVersion: "s3.aws.upbound.io/v1beta1", APIResources: []metav1.APIResource{{Name: "buckets", SingularName: "bucket", Kind: "Bucket"}}},
+	}
+	if _, err := m.ResourceFor("Bucket.s3.aws.upbound.io"); err == nil {
+		t.Errorf("ResourceFor(...): want error within %s of the last discovery", mapperResetInterval)
+	}
+
+	now = now.Add(mapperResetInterval)
+	gvr, err := m.ResourceFor("Bucket.s3.aws.upbound.io")
+	if err != nil {
+		t.Fatalf("ResourceFor(...): %v", err)
+	}
+	if diff := cmp.Diff(schema.GroupVersionResource{Group: "s3.aws.upbound.io", Version: "v1beta1", Resource: "buckets"}, gvr); diff != "" {
+		t.Errorf("\nShould discover resources created after the last discovery.\nResourceFor(...): -want, +got:\n%s", diff)
+	}
+}
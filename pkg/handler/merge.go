@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"io"
+
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+// mergedWriter writes the families of stores sharing a family name, e.g. the
+// stores of a resource registered under two metric names with the
+// GroupResource naming strategy. Families of the same name are written as a
+// single block with the headers of the first store, as Prometheus rejects
+// repeated headers.
+type mergedWriter []metricsstore.MetricsWriter
+
+// mergedFamily is a family of a mergedWriter.
+type mergedFamily struct {
+	header bytes.Buffer
+	series bytes.Buffer
+	// store is the index of the store whose header is written
+	store int
+}
+
+func (m mergedWriter) WriteAll(w io.Writer) {
+	var families []*mergedFamily
+	byName := map[string]*mergedFamily{}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(buf)
+	for i, s := range m {
+		buf.Reset()
+		s.WriteAll(buf)
+		var current *mergedFamily
+		for _, line := range bytes.SplitAfter(buf.Bytes(), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			if name, ok := headerName(line); ok {
+				f, seen := byName[name]
+				if !seen {
+					f = &mergedFamily{store: i}
+					byName[name] = f
+					families = append(families, f)
+				}
+				if f.store == i {
+					f.header.Write(line)
+				}
+				current = f
+				continue
+			}
+			if current == nil {
+				// series without header can't be merged
+				_, _ = w.Write(line)
+				continue
+			}
+			current.series.Write(line)
+		}
+	}
+	buf.Reset()
+	for _, f := range families {
+		_, _ = f.header.WriteTo(w)
+		_, _ = f.series.WriteTo(w)
+	}
+}
+
+// headerName returns the family name of a TYPE or HELP line.
+func headerName(line []byte) (string, bool) {
+	for _, prefix := range [][]byte{[]byte("# TYPE "), []byte("# HELP ")} {
+		if rest, ok := bytes.CutPrefix(line, prefix); ok {
+			name, _, _ := bytes.Cut(bytes.TrimRight(rest, "\n"), []byte(" "))
+			return string(name), true
+		}
+	}
+	return "", false
+}
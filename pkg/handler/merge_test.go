@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestMergedWriter(t *testing.T) {
+	store := func(s string) writerFunc {
+		return func(w io.Writer) {
+			_, _ = io.WriteString(w, s)
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		writer mergedWriter
+		want   string
+	}{
+		"SharedFamilies": {
+			reason: "Should write families of the same name as a single block with the headers of the first store.",
+			writer: mergedWriter{
+				store("# HELP a_ready First.\n# TYPE a_ready gauge\na_ready{name=\"x\"} 1\n# TYPE a_synced gauge\na_synced{name=\"x\"} 0\n"),
+				store("# HELP a_ready Second.\n# TYPE a_ready gauge\na_ready{name=\"y\"} 0\n# TYPE a_synced gauge\na_synced{name=\"y\"} 1\n"),
+			},
+			want: "# HELP a_ready First.\n# TYPE a_ready gauge\na_ready{name=\"x\"} 1\na_ready{name=\"y\"} 0\n# TYPE a_synced gauge\na_synced{name=\"x\"} 0\na_synced{name=\"y\"} 1\n",
+		},
+		"DistinctFamilies": {
+			reason: "Should keep families only written by one store in the order of their first appearance.",
+			writer: mergedWriter{
+				store("# TYPE a_ready gauge\na_ready 1\n"),
+				store("# TYPE a_info gauge\na_info 1\n# TYPE a_ready gauge\na_ready 0\n"),
+			},
+			want: "# TYPE a_ready gauge\na_ready 1\na_ready 0\n# TYPE a_info gauge\na_info 1\n",
+		},
+		"EmptyFamily": {
+			reason: "Should write the headers of families without series.",
+			writer: mergedWriter{
+				store("# TYPE a_ready gauge\n"),
+				store("# TYPE a_ready gauge\n"),
+			},
+			want: "# TYPE a_ready gauge\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			tc.writer.WriteAll(buf)
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("\n%s\nWriteAll(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSharedFamily(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("rds.aws.upbound.io/v1beta1")
+	u.SetKind("Instance")
+	u.SetName("db")
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"}, u)
+
+	m := NewManagedMetricsHandler(dc, Config{Naming: Naming{Strategy: NamingGroupResource}})
+	first := m.RegisterAndAddMetricStoreForGVR(context.Background(), "first", gvr, "")
+	defer close(first)
+	second := m.RegisterAndAddMetricStoreForGVR(context.Background(), "second", gvr, "")
+	defer close(second)
+	if err := m.WaitForSync(context.Background()); err != nil {
+		t.Fatalf("WaitForSync(...): %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/x-metrics", nil))
+	body := rec.Body.String()
+	if got := strings.Count(body, "# TYPE rds_instance_ready gauge\n"); got != 1 {
+		t.Errorf("ServeHTTP(...): want a single header of the shared family, got %d:\n%s", got, body)
+	}
+	if got := strings.Count(body, "rds_instance_ready{"); got != 2 {
+		t.Errorf("ServeHTTP(...): want the series of both stores, got %d:\n%s", got, body)
+	}
+}
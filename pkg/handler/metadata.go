@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newMetadataListWatch returns a ListWatch of the metadata of a resource, see
+// newListWatch. The API server only sends the metadata of objects, which are
+// converted to unstructured objects without spec and status.
+func newMetadataListWatch(ctx context.Context, ri metadata.ResourceInterface, opts listWatchOptions) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(opt metav1.ListOptions) (runtime.Object, error) {
+			ctx, cancel := opts.listContext(ctx)
+			defer cancel()
+			ml, err := ri.List(ctx, opts.list(opt))
+			if err != nil {
+				return nil, err
+			}
+			list := &unstructured.UnstructuredList{Object: map[string]any{}}
+			list.SetResourceVersion(ml.ResourceVersion)
+			list.SetContinue(ml.Continue)
+			list.SetRemainingItemCount(ml.RemainingItemCount)
+			list.Items = make([]unstructured.Unstructured, 0, len(ml.Items))
+			for i := range ml.Items {
+				obj, err := metadataToUnstructured(&ml.Items[i])
+				if err != nil {
+					return nil, err
+				}
+				list.Items = append(list.Items, *obj)
+			}
+			shardList(list, opts.sharding)
+			transformList(list, opts.transform)
+			return list, nil
+		},
+		WatchFunc: func(opt metav1.ListOptions) (watch.Interface, error) {
+			w, err := ri.Watch(ctx, opts.watch(opt))
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+				if m, ok := e.Object.(*metav1.PartialObjectMetadata); ok {
+					obj, err := metadataToUnstructured(m)
+					if err != nil {
+						return e, false
+					}
+					if opts.transform != nil {
+						opts.transform(obj)
+					}
+					e.Object = obj
+				}
+				return e, shardEvent(e, opts.sharding)
+			}), nil
+		},
+	}
+}
+
+func metadataToUnstructured(m *metav1.PartialObjectMetadata) (*unstructured.Unstructured, error) {
+	o, err := runtime.DefaultUnstructuredConverter.ToUnstructured(m)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: o}, nil
+}
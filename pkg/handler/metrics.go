@@ -0,0 +1,523 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/crossplane-contrib/x-metrics/internal/version"
+)
+
+var gvrLabels = []string{"group", "version", "resource"}
+
+// Metrics about x-metrics itself, served on the /metrics endpoint of the manager.
+var (
+	reflectorRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_reflector_restarts_total",
+		Help: "Number of times the reflector of a resource was restarted after a list or watch failure",
+	}, gvrLabels)
+	reflectorResyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_reflector_resyncs_total",
+		Help: "Number of times the objects of a resource were listed again after the resync period",
+	}, gvrLabels)
+	watchBookmarks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_watch_bookmarks_total",
+		Help: "Number of bookmark events received by the watches of a resource",
+	}, gvrLabels)
+	watchResumes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_watch_resumes_total",
+		Help: "Number of watches of a resource resumed after a transient failure without listing the objects again",
+	}, gvrLabels)
+	watchExpirations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_watch_expirations_total",
+		Help: "Number of watches of a resource ended because their resource version expired, causing a relist",
+	}, gvrLabels)
+	listErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_list_errors_total",
+		Help: "Number of failed list requests of a resource, per reason of the API server, e.g. Forbidden",
+	}, append(gvrLabels, "reason"))
+	relists = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_relists_total",
+		Help: "Number of times all objects of a resource were listed again after the initial list",
+	}, gvrLabels)
+	droppedObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_dropped_objects",
+		Help: "Objects of a resource not exported because their stores exceed their limit of objects",
+	}, gvrLabels)
+	sheddingStores = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_shedding_stores",
+		Help: "Stores of a resource dropping their shed families because they exceed the objects configured by shedAbove",
+	}, gvrLabels)
+	listWatchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metrics_list_watch_errors_total",
+		Help: "Number of failed lists and watches of a resource",
+	}, gvrLabels)
+	lastSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_reflector_last_sync_timestamp_seconds",
+		Help: "Unix timestamp of the last completed list of a resource",
+	}, gvrLabels)
+	cachedObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_objects",
+		Help: "Number of objects of a resource held by the metric stores",
+	}, gvrLabels)
+	registeredStores = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "x_metrics_stores",
+		Help: "Number of registered metric stores",
+	})
+	pendingLists = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "x_metrics_pending_initial_lists",
+		Help: "Number of reflectors waiting for the limit of concurrent initial lists to start listing",
+	})
+	sharedInformers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "x_metrics_reflectors",
+		Help: "Number of running reflectors, each shared by the metric stores of the same objects",
+	})
+	scrapeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "x_metrics_scrape_duration_seconds",
+		Help:    "Duration of requests to the metrics endpoint of the metric stores",
+		Buckets: prometheus.DefBuckets,
+	})
+	storeResync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_store_resync_period_seconds",
+		Help: "Period in which the objects of a metric store are listed again, 0 if they are not",
+	}, []string{"store"})
+	storeWriteDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_store_write_duration_seconds",
+		Help: "Time spent writing the families of a metric store during the last scrape",
+	}, []string{"store"})
+	storeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_store_bytes",
+		Help: "Uncompressed bytes written by a metric store during the last scrape",
+	}, []string{"store"})
+	storeSeries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_store_series",
+		Help: "Number of series written by a metric store during the last scrape",
+	}, []string{"store"})
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metrics_build_info",
+		Help: "Build information of x-metrics, with a constant value of 1",
+	}, []string{"version", "goversion"})
+)
+
+func init() {
+	buildInfo.WithLabelValues(version.New().GetVersionString(), runtime.Version()).Set(1)
+	metrics.Registry.MustRegister(reflectorRestarts, reflectorResyncs, watchBookmarks, watchResumes, watchExpirations, listErrors, relists, listWatchErrors, lastSync, cachedObjects, droppedObjects, sheddingStores, registeredStores, pendingLists, sharedInformers, scrapeDuration,
+		storeResync, storeWriteDuration, storeBytes, storeSeries, buildInfo)
+}
+
+// instrumentedStore wraps the store of a reflector to count the objects held
+// per resource and record the time of the last completed list. It keeps the
+// status of the objects for the resources API.
+type instrumentedStore struct {
+	cache.Store
+	metrics *metricsstore.MetricsStore
+	gvr     schema.GroupVersionResource
+	cluster string
+	// cancel stops the reflector of the store, nil for stores without
+	cancel context.CancelFunc
+	// composition writes the composed families, nil for stores without
+	composition *composition
+	// transitions counts the condition changes of the objects, nil for
+	// stores without
+	transitions *transitions
+	// families caches the families of the objects, nil for stores without
+	families *familyCache
+	// events counts the Warning events of the objects, nil for stores
+	// without
+	events *storeEvents
+	// limits cap the objects of the store, nil for stores without
+	limits *StoreLimits
+	// log logs when the store exceeds its limits, nil for stores without
+	log interface {
+		Info(msg string, keysAndValues ...any)
+	}
+
+	mu      sync.Mutex
+	objects map[types.UID]objectStatus
+	// dropped are the objects ignored beyond the limit of objects
+	dropped  map[types.UID]bool
+	shedding bool
+	// regenerate is set when the families of all objects have to be
+	// generated again, after shedding started or stopped
+	regenerate bool
+	synced     bool
+	stopped    bool
+	// lastError is the last failure of the lists and watches of the store
+	// since its last list, empty if none
+	lastError string
+	// syncTime is the time of the last list, watch or event
+	syncTime time.Time
+}
+
+func newInstrumentedStore(s *metricsstore.MetricsStore, gvr schema.GroupVersionResource) *instrumentedStore {
+	return &instrumentedStore{Store: s, metrics: s, gvr: gvr, objects: map[types.UID]objectStatus{}, dropped: map[types.UID]bool{}}
+}
+
+func (s *instrumentedStore) Add(obj any) error {
+	if !s.track(obj, true) {
+		return nil
+	}
+	return s.Store.Add(obj)
+}
+
+func (s *instrumentedStore) Update(obj any) error {
+	if !s.track(obj, true) {
+		return nil
+	}
+	return s.Store.Update(obj)
+}
+
+func (s *instrumentedStore) Delete(obj any) error {
+	if !s.track(obj, false) {
+		return nil
+	}
+	return s.Store.Delete(obj)
+}
+
+func (s *instrumentedStore) Replace(list []any, rv string) error {
+	objects := make(map[types.UID]objectStatus, len(list))
+	dropped := map[types.UID]bool{}
+	kept := list[:0:0]
+	max := s.limits.maxObjects()
+	for _, obj := range list {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			kept = append(kept, obj)
+			continue
+		}
+		if max > 0 && len(objects) >= max {
+			dropped[u.GetUID()] = true
+			continue
+		}
+		objects[u.GetUID()] = newObjectStatus(u)
+		kept = append(kept, obj)
+	}
+	s.mu.Lock()
+	stopped := s.stopped
+	if !stopped {
+		cachedObjects.WithLabelValues(s.labels()...).Add(float64(len(objects) - len(s.objects)))
+		lastSync.WithLabelValues(s.labels()...).Set(float64(time.Now().Unix()))
+		s.setDropped(dropped)
+		s.objects = objects
+		s.synced = true
+		s.lastError = ""
+		s.syncTime = time.Now()
+		if s.transitions != nil {
+			s.transitions.retain(objects)
+		}
+		if s.families != nil {
+			s.families.retain(objects)
+		}
+		s.updateShedding()
+		// the families of all objects are generated again by the replace
+		s.regenerate = false
+	}
+	s.mu.Unlock()
+	if stopped {
+		return nil
+	}
+	return s.Store.Replace(kept, rv)
+}
+
+// setDropped replaces the objects ignored beyond the limit of objects. s.mu
+// must be held.
+func (s *instrumentedStore) setDropped(dropped map[types.UID]bool) {
+	if len(s.dropped) == 0 && len(dropped) > 0 && s.log != nil {
+		s.log.Info("Store exceeds its limit of objects, ignoring further objects", "maxObjects", s.limits.maxObjects())
+	}
+	droppedObjects.WithLabelValues(s.labels()...).Add(float64(len(dropped) - len(s.dropped)))
+	s.dropped = dropped
+}
+
+// admit returns true if a new object fits into the limit of objects, and
+// records it as dropped otherwise. s.mu must be held.
+func (s *instrumentedStore) admit(uid types.UID) bool {
+	if max := s.limits.maxObjects(); max > 0 && len(s.objects) >= max {
+		if !s.dropped[uid] {
+			if len(s.dropped) == 0 && s.log != nil {
+				s.log.Info("Store exceeds its limit of objects, ignoring further objects", "maxObjects", max)
+			}
+			s.dropped[uid] = true
+			droppedObjects.WithLabelValues(s.labels()...).Inc()
+		}
+		return false
+	}
+	if s.dropped[uid] {
+		droppedObjects.WithLabelValues(s.labels()...).Dec()
+		delete(s.dropped, uid)
+	}
+	return true
+}
+
+// updateShedding starts or stops dropping the shed families of the objects,
+// depending on their number. s.mu must be held.
+func (s *instrumentedStore) updateShedding() {
+	if s.families == nil {
+		return
+	}
+	shedding := s.limits.shedding(len(s.objects), s.shedding)
+	if shedding == s.shedding {
+		return
+	}
+	s.shedding = shedding
+	if shedding {
+		sheddingStores.WithLabelValues(s.labels()...).Inc()
+		if s.log != nil {
+			s.log.Info("Store exceeds the objects above which families are shed, dropping them", "shedAbove", s.limits.ShedAbove, "objects", len(s.objects))
+		}
+	} else {
+		sheddingStores.WithLabelValues(s.labels()...).Dec()
+	}
+	if s.families.setShedding(shedding) {
+		s.regenerate = true
+	}
+}
+
+// regenerateFamilies generates the families of the tracked objects of the
+// list again once shedding started or stopped.
+func (s *instrumentedStore) regenerateFamilies(list func() []any, rv string) error {
+	s.mu.Lock()
+	if !s.regenerate || s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.regenerate = false
+	tracked := make([]any, 0, len(s.objects))
+	for _, obj := range list() {
+		u, ok := obj.(*unstructured.Unstructured)
+		if ok {
+			_, ok = s.objects[u.GetUID()]
+		}
+		if ok {
+			tracked = append(tracked, obj)
+		}
+	}
+	s.mu.Unlock()
+	return s.Store.Replace(tracked, rv)
+}
+
+// hasSynced returns true once the initial list of the reflector completed.
+func (s *instrumentedStore) hasSynced() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.synced
+}
+
+// recordSync records a sync of the store, e.g. the start of a watch.
+func (s *instrumentedStore) recordSync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncTime = time.Now()
+}
+
+// setError records a failure of the lists and watches of the store.
+func (s *instrumentedStore) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err.Error()
+}
+
+// failed returns true if the store never synced because its lists and
+// watches failed, e.g. for a missing CRD or missing permissions.
+func (s *instrumentedStore) failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.synced && s.lastError != ""
+}
+
+// lastSync returns the time of the last list, watch or event of the store,
+// zero if it never synced.
+func (s *instrumentedStore) lastSync() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.syncTime
+}
+
+// stop stops the reflector of the store and removes its objects from the
+// object count and its families, so that a stopped store serves no stale
+// series. Later changes are not counted anymore.
+func (s *instrumentedStore) stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cachedObjects.WithLabelValues(s.labels()...).Sub(float64(len(s.objects)))
+	droppedObjects.WithLabelValues(s.labels()...).Sub(float64(len(s.dropped)))
+	if s.shedding {
+		sheddingStores.WithLabelValues(s.labels()...).Dec()
+	}
+	s.objects = map[types.UID]objectStatus{}
+	s.dropped = map[types.UID]bool{}
+	s.shedding = false
+	s.stopped = true
+	if s.transitions != nil {
+		s.transitions.retain(s.objects)
+	}
+	if s.families != nil {
+		s.families.retain(s.objects)
+	}
+	_ = s.metrics.Replace(nil, "")
+}
+
+// track records the status of an object. It returns false if the store is
+// stopped and the change must not be applied.
+func (s *instrumentedStore) track(obj any, present bool) bool {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.syncTime = time.Now()
+	_, ok = s.objects[u.GetUID()]
+	switch {
+	case present:
+		if !ok && !s.admit(u.GetUID()) {
+			return false
+		}
+		s.objects[u.GetUID()] = newObjectStatus(u)
+		if !ok {
+			cachedObjects.WithLabelValues(s.labels()...).Inc()
+		}
+	case ok:
+		delete(s.objects, u.GetUID())
+		cachedObjects.WithLabelValues(s.labels()...).Dec()
+	case s.dropped[u.GetUID()]:
+		delete(s.dropped, u.GetUID())
+		droppedObjects.WithLabelValues(s.labels()...).Dec()
+		return false
+	}
+	s.updateShedding()
+	if !present && s.transitions != nil {
+		s.transitions.forget(u.GetUID())
+	}
+	if !present && s.families != nil {
+		s.families.forget(u.GetUID())
+	}
+	if !present && s.events != nil {
+		s.events.counter.forget(u.GetUID())
+	}
+	return true
+}
+
+// statuses calls fn for the status of each object of the store.
+func (s *instrumentedStore) statuses(fn func(uid types.UID, o objectStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uid, o := range s.objects {
+		fn(uid, o)
+	}
+}
+
+func (s *instrumentedStore) labels() []string {
+	return []string{s.gvr.Group, s.gvr.Version, s.gvr.Resource}
+}
+
+// writeStore writes the families of a store and records the duration, bytes
+// and series of the write.
+func writeStore(name string, s metricsstore.MetricsWriter, w io.Writer) {
+	start := time.Now()
+	cw := &countingWriter{w: w, lineStart: true}
+	s.WriteAll(cw)
+	storeWriteDuration.WithLabelValues(name).Set(time.Since(start).Seconds())
+	storeBytes.WithLabelValues(name).Set(float64(cw.bytes))
+	storeSeries.WithLabelValues(name).Set(float64(cw.series))
+}
+
+// bufferPool holds the buffers stores are rendered into by writeStores.
+var bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// writeStores renders the stores filtered by filter to w in the order of
+// their names. The store at the head of the order is streamed to w, while the
+// next stores, up to GOMAXPROCS, are rendered in parallel into pooled buffers
+// until their turn has come.
+func writeStores(stores map[string]metricsstore.MetricsWriter, w io.Writer, filter familyFilter) {
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writers := make([]*streamWriter, len(names))
+	done := make([]chan struct{}, len(names))
+	start := func(i int) {
+		writers[i] = newStreamWriter(w)
+		done[i] = make(chan struct{})
+		go func() {
+			defer close(done[i])
+			writeStore(names[i], stores[names[i]], filter.writer(writers[i]))
+		}()
+	}
+	window := runtime.GOMAXPROCS(0)
+	for i := 0; i < len(names) && i < window; i++ {
+		start(i)
+	}
+	for i := range names {
+		writers[i].stream()
+		<-done[i]
+		if next := i + window; next < len(names) {
+			start(next)
+		}
+	}
+}
+
+// deleteStoreMetrics removes the series of a removed store.
+func deleteStoreMetrics(name string) {
+	storeResync.DeleteLabelValues(name)
+	storeWriteDuration.DeleteLabelValues(name)
+	storeBytes.DeleteLabelValues(name)
+	storeSeries.DeleteLabelValues(name)
+}
+
+// countingWriter counts the bytes and the series, i.e. the lines not starting
+// with a comment, written to w.
+type countingWriter struct {
+	w         io.Writer
+	bytes     int
+	series    int
+	lineStart bool
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if c.lineStart && b != '#' && b != '\n' {
+			c.series++
+		}
+		c.lineStart = b == '\n'
+	}
+	n, err := c.w.Write(p)
+	c.bytes += n
+	return n, err
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+	newNamed := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{}}
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		return u
+	}
+	a, b, c := newNamed("a"), newNamed("b"), newNamed("c")
+
+	cases := map[string]struct {
+		reason string
+		ops    func(s *instrumentedStore)
+		want   float64
+	}{
+		"Replace": {
+			reason: "Should count the objects of a list.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b}, "1")
+			},
+			want: 2,
+		},
+		"Watch": {
+			reason: "Should count added objects once and subtract deleted objects.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a}, "1")
+				_ = s.Add(b)
+				_ = s.Update(b)
+				_ = s.Add(c)
+				_ = s.Delete(a)
+			},
+			want: 2,
+		},
+		"Relist": {
+			reason: "Should replace the count on a relist.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b, c}, "1")
+				_ = s.Replace([]any{c}, "2")
+			},
+			want: 1,
+		},
+		"Stopped": {
+			reason: "Should not count objects of stopped stores.",
+			ops: func(s *instrumentedStore) {
+				_ = s.Replace([]any{a, b}, "1")
+				s.stop()
+				_ = s.Add(c)
+			},
+			want: 0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gvr := schema.GroupVersionResource{Group: "test.x-metrics.io", Version: "v1", Resource: name}
+			s := newInstrumentedStore(newMetricsStore(name, "", "", ResourceConfig{}, nil, nil), gvr)
+			tc.ops(s)
+			got := testutil.ToFloat64(cachedObjects.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nx_metrics_objects: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInstrumentedStoreStop(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]any{}}
+	u.SetName("a")
+	u.SetUID("a")
+	var canceled bool
+	s := newInstrumentedStore(newMetricsStore("test", "", "", ResourceConfig{}, nil, nil), schema.GroupVersionResource{})
+	s.cancel = func() { canceled = true }
+	_ = s.Add(u)
+	s.stop()
+	// Changes of the reflector after the store is stopped are ignored
+	_ = s.Update(u)
+
+	buf := &bytes.Buffer{}
+	s.metrics.WriteAll(buf)
+	if strings.Contains(buf.String(), `name="a"`) {
+		t.Errorf("stop(): want no series of stopped store, got:\n%s", buf.String())
+	}
+	if !canceled {
+		t.Errorf("stop(): want the context of the store canceled")
+	}
+}
+
+func TestCountingWriter(t *testing.T) {
+	type want struct {
+		bytes  int
+		series int
+	}
+	cases := map[string]struct {
+		reason string
+		writes []string
+		want   want
+	}{
+		"Headers": {
+			reason: "Should not count headers as series.",
+			writes: []string{"# TYPE a gauge\n# HELP a help\n"},
+			want:   want{bytes: 29, series: 0},
+		},
+		"Series": {
+			reason: "Should count each sample line as a series.",
+			writes: []string{"# TYPE a gauge\n", "a{name=\"x\"} 1\na{name=\"y\"} 1\n"},
+			want:   want{bytes: 43, series: 2},
+		},
+		"SplitWrites": {
+			reason: "Should count lines written by several writes once.",
+			writes: []string{"a{name=", "\"x\"} 1", "\n", "b 1\n"},
+			want:   want{bytes: 18, series: 2},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cw := &countingWriter{w: io.Discard, lineStart: true}
+			for _, w := range tc.writes {
+				_, _ = cw.Write([]byte(w))
+			}
+			if diff := cmp.Diff(tc.want, want{bytes: cw.bytes, series: cw.series}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nWrite(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// writerFunc writes the families of a test store.
+type writerFunc func(w io.Writer)
+
+func (f writerFunc) WriteAll(w io.Writer) {
+	f(w)
+}
+
+func TestWriteStores(t *testing.T) {
+	family := func(name string) writerFunc {
+		return func(w io.Writer) {
+			_, _ = io.WriteString(w, "# TYPE "+name+" gauge\n"+name+" 1\n")
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		stores map[string]metricsstore.MetricsWriter
+		query  url.Values
+		want   string
+	}{
+		"Order": {
+			reason: "Should write the stores in the order of their names.",
+			stores: map[string]metricsstore.MetricsWriter{"c": family("c"), "a": family("a"), "b": family("b")},
+			want:   "# TYPE a gauge\na 1\n# TYPE b gauge\nb 1\n# TYPE c gauge\nc 1\n",
+		},
+		"Filter": {
+			reason: "Should only write the selected families.",
+			stores: map[string]metricsstore.MetricsWriter{"a": family("a"), "b": family("b")},
+			query:  url.Values{"include": []string{"b"}},
+			want:   "# TYPE b gauge\nb 1\n",
+		},
+		"ManyStores": {
+			reason: "Should write the stores in the order of their names if more stores than rendered in parallel are written.",
+			stores: func() map[string]metricsstore.MetricsWriter {
+				stores := map[string]metricsstore.MetricsWriter{}
+				for i := 0; i < 2*runtime.GOMAXPROCS(0)+1; i++ {
+					name := fmt.Sprintf("s%03d", i)
+					stores[name] = family(name)
+				}
+				return stores
+			}(),
+			want: func() string {
+				var b strings.Builder
+				for i := 0; i < 2*runtime.GOMAXPROCS(0)+1; i++ {
+					name := fmt.Sprintf("s%03d", i)
+					b.WriteString("# TYPE " + name + " gauge\n" + name + " 1\n")
+				}
+				return b.String()
+			}(),
+		},
+		"Empty": {
+			reason: "Should write nothing without stores.",
+			stores: map[string]metricsstore.MetricsWriter{},
+			want:   "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writeStores(tc.stores, buf, parseFamilyFilter(tc.query))
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("\n%s\nwriteStores(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
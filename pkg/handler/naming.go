@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// NamingStrategy decides the base name of the families of a resource.
+type NamingStrategy string
+
+const (
+	// NamingMetricName uses the metric name of the registration, e.g.
+	// rds_aws_upbound_io_Instance_v1beta1 for Metric objects
+	NamingMetricName NamingStrategy = "MetricName"
+	// NamingGroupResource uses the first segment of the group and the
+	// singular resource, e.g. rds_instance
+	NamingGroupResource NamingStrategy = "GroupResource"
+)
+
+// Naming configures the names of the exported families.
+type Naming struct {
+	// Prefix is prepended to all family names, e.g. x_
+	Prefix string `json:"prefix,omitempty"`
+	// Strategy decides the base name of the families. Defaults to MetricName
+	Strategy NamingStrategy `json:"strategy,omitempty"`
+	// SnakeCase converts family names to snake case, e.g. Instance to instance
+	SnakeCase bool `json:"snakeCase,omitempty"`
+}
+
+// familyName returns the base name of the families of a registration.
+func (n Naming) familyName(metricName string, gvr schema.GroupVersionResource, namespace string) string {
+	name := metricName
+	if n.Strategy == NamingGroupResource {
+		name = strings.SplitN(gvr.Group, ".", 2)[0] + "_" + singular(gvr.Resource)
+	}
+	if namespace != "" {
+		name = namespace + "_" + name
+	}
+	name = GetValidLabel(n.Prefix + name)
+	if n.SnakeCase {
+		name = snakeCase(name)
+	}
+	return name
+}
+
+// singular naively returns the singular of a plural resource name.
+func singular(resource string) string {
+	switch {
+	case strings.HasSuffix(resource, "ies"):
+		return strings.TrimSuffix(resource, "ies") + "y"
+	case strings.HasSuffix(resource, "sses"):
+		return strings.TrimSuffix(resource, "es")
+	case strings.HasSuffix(resource, "s"):
+		return strings.TrimSuffix(resource, "s")
+	}
+	return resource
+}
+
+// snakeCase converts camel case words of name to lower snake case, e.g.
+// RDSInstance to rds_instance.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && runes[i-1] != '_' {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
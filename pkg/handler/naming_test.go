@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFamilyName(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+
+	cases := map[string]struct {
+		reason    string
+		naming    Naming
+		namespace string
+		want      string
+	}{
+		"Default": {
+			reason: "Should use the metric name by default.",
+			want:   "rds_aws_upbound_io_Instance_v1beta1",
+		},
+		"Namespace": {
+			reason:    "Should prefix the names of namespaced registrations with the namespace.",
+			namespace: "team-a",
+			want:      "team_a_rds_aws_upbound_io_Instance_v1beta1",
+		},
+		"Prefix": {
+			reason:    "Should prepend the prefix to all names.",
+			naming:    Naming{Prefix: "x_"},
+			namespace: "team-a",
+			want:      "x_team_a_rds_aws_upbound_io_Instance_v1beta1",
+		},
+		"SnakeCase": {
+			reason: "Should convert names to snake case.",
+			naming: Naming{SnakeCase: true},
+			want:   "rds_aws_upbound_io_instance_v1beta1",
+		},
+		"GroupResource": {
+			reason: "Should derive the name from group and resource.",
+			naming: Naming{Prefix: "x_", Strategy: NamingGroupResource},
+			want:   "x_rds_instance",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.naming.familyName("rds_aws_upbound_io_Instance_v1beta1", gvr, tc.namespace)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nfamilyName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"RDSInstance":      "rds_instance",
+		"DBSubnetGroup_v1": "db_subnet_group_v1",
+		"already_snake":    "already_snake",
+		"Instance_v1beta1": "instance_v1beta1",
+	}
+	for in, want := range cases {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q): want %q, got %q", in, want, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import "time"
+
+// Option configures a ManagedMetricsHandler on top of its Config.
+type Option func(*options)
+
+type options struct {
+	config      Config
+	resync      time.Duration
+	generators  generatorRegistry
+	debugStores []string
+}
+
+// newOptions returns the configuration, resync period and generators of
+// config with opts applied.
+func newOptions(config Config, resync time.Duration, opts []Option) options {
+	o := options{config: config, resync: resync, generators: generatorRegistry{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithNamespace restricts cluster wide registrations to the given namespaces,
+// unless configured otherwise per resource.
+func WithNamespace(namespaces ...string) Option {
+	return func(o *options) {
+		o.config.Defaults.Namespaces = namespaces
+	}
+}
+
+// WithLabelAllowlist restricts the labels exposed on the _labels family to
+// the given glob patterns, unless configured otherwise per resource.
+func WithLabelAllowlist(patterns ...string) Option {
+	return func(o *options) {
+		o.config.Defaults.LabelsAllowlist = patterns
+	}
+}
+
+// WithInfoMappings adds labels to the _info family of all resources, unless
+// their info mappings are configured per resource.
+func WithInfoMappings(mappings ...InfoMappings) Option {
+	return func(o *options) {
+		o.config.Defaults.InfoMappings = append(o.config.Defaults.InfoMappings, mappings...)
+	}
+}
+
+// WithDisabledFamilies disables the given built-in families, keyed like
+// ResourceOptions.DisabledFamilies, unless configured otherwise per resource.
+func WithDisabledFamilies(keys ...string) Option {
+	return func(o *options) {
+		o.config.Defaults.DisabledFamilies = append(o.config.Defaults.DisabledFamilies, keys...)
+	}
+}
+
+// WithLabels adds static labels to every series, overriding the labels of
+// the Config with the same key.
+func WithLabels(labels map[string]string) Option {
+	return func(o *options) {
+		if len(labels) == 0 {
+			return
+		}
+		merged := make(map[string]string, len(o.config.Labels)+len(labels))
+		for k, v := range o.config.Labels {
+			merged[k] = v
+		}
+		for k, v := range labels {
+			merged[k] = v
+		}
+		o.config.Labels = merged
+	}
+}
+
+// WithConditionEncoding sets the encoding of the condition families, unless
+// configured otherwise per resource.
+func WithConditionEncoding(encoding ConditionEncoding) Option {
+	return func(o *options) {
+		o.config.Defaults.ConditionEncoding = encoding
+	}
+}
+
+// WithMetricPrefix prepends prefix to all family names.
+func WithMetricPrefix(prefix string) Option {
+	return func(o *options) {
+		o.config.Naming.Prefix = prefix
+	}
+}
+
+// WithDebugStores logs the debug messages of the lists and watches of the
+// given stores, named by their metric name or by their resource as
+// <resource>.<group>, without raising the verbosity of all stores.
+func WithDebugStores(names ...string) Option {
+	return func(o *options) {
+		o.debugStores = append(o.debugStores, names...)
+	}
+}
+
+// WithResync sets the period in which the reflectors of the stores resync
+// their objects. Objects are not resynced if zero.
+func WithResync(period time.Duration) Option {
+	return func(o *options) {
+		o.resync = period
+	}
+}
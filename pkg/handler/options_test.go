@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+func TestOptions(t *testing.T) {
+	type want struct {
+		config Config
+		resync time.Duration
+	}
+	cases := map[string]struct {
+		reason string
+		config Config
+		opts   []Option
+		want   want
+	}{
+		"None": {
+			reason: "Should keep the config without options.",
+			config: Config{ListPageSize: 100},
+			want:   want{config: Config{ListPageSize: 100}},
+		},
+		"Defaults": {
+			reason: "Should set the defaults of all resources.",
+			config: Config{Defaults: ResourceOptions{InfoMappings: []InfoMappings{{FieldPath: "spec.a", Label: "a"}}}},
+			opts: []Option{
+				WithNamespace("team-a"),
+				WithLabelAllowlist("app.kubernetes.io/*"),
+				WithInfoMappings(InfoMappings{FieldPath: "spec.b", Label: "b"}),
+				WithDisabledFamilies("annotations"),
+			},
+			want: want{config: Config{Defaults: ResourceOptions{
+				Namespaces:       []string{"team-a"},
+				LabelsAllowlist:  []string{"app.kubernetes.io/*"},
+				InfoMappings:     []InfoMappings{{FieldPath: "spec.a", Label: "a"}, {FieldPath: "spec.b", Label: "b"}},
+				DisabledFamilies: []string{"annotations"},
+			}}},
+		},
+		"PrefixAndResync": {
+			reason: "Should set the prefix of the families and the resync period.",
+			opts:   []Option{WithMetricPrefix("x_"), WithResync(time.Hour)},
+			want:   want{config: Config{Naming: Naming{Prefix: "x_"}}, resync: time.Hour},
+		},
+		"Labels": {
+			reason: "Should add the static labels, overriding those of the config.",
+			config: Config{Labels: map[string]string{"environment": "dev", "region": "eu"}},
+			opts:   []Option{WithLabels(map[string]string{"environment": "prod"})},
+			want:   want{config: Config{Labels: map[string]string{"environment": "prod", "region": "eu"}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, tc.config, tc.opts...)
+			got := want{config: m.config, resync: m.resync}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nNewManagedMetricsHandler(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSetConfigOptions(t *testing.T) {
+	m := NewManagedMetricsHandler(nil, Config{}, WithMetricPrefix("x_"))
+	m.SetConfig(context.Background(), Config{ListPageSize: 100})
+	want := Config{ListPageSize: 100, Naming: Naming{Prefix: "x_"}}
+	if diff := cmp.Diff(want, m.config); diff != "" {
+		t.Errorf("SetConfig(...): want options applied to the new config, -want, +got:\n%s", diff)
+	}
+}
+
+func TestResourceGenerators(t *testing.T) {
+	generator := func(suffix string) FamilyGenerator {
+		return NewFamilyGenerator(suffix, "", func(_ *unstructured.Unstructured) []*metric.Metric { return nil })
+	}
+	m := NewManagedMetricsHandler(nil, Config{},
+		WithResourceGenerators(schema.GroupResource{Group: "example.org", Resource: "buckets"}, generator("_bucket")),
+		WithGenerators(generator("_all")),
+	)
+	cases := map[string]struct {
+		reason string
+		gvr    schema.GroupVersionResource
+		want   []string
+	}{
+		"Registered": {
+			reason: "Should return the generators of all resources followed by those of the resource, in any version.",
+			gvr:    schema.GroupVersionResource{Group: "example.org", Version: "v1beta1", Resource: "buckets"},
+			want:   []string{"_all", "_bucket"},
+		},
+		"Other": {
+			reason: "Should return the generators of all resources only.",
+			gvr:    schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "tables"},
+			want:   []string{"_all"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, g := range m.generators.forResource(tc.gvr) {
+				suffix, _ := g.Family()
+				got = append(got, suffix)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nforResource(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var ssarGVR = schema.GroupVersionResource{Group: "authorization.k8s.io", Version: "v1", Resource: "selfsubjectaccessreviews"}
+
+// reflectorVerbs are the verbs a reflector needs on its resource
+var reflectorVerbs = []string{"list", "watch"}
+
+var missingPermissions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "x_metrics_missing_permissions",
+	Help: "Number of stores of a resource waiting for the permission to list and watch it",
+}, gvrLabels)
+
+func init() {
+	metrics.Registry.MustRegister(missingPermissions)
+}
+
+// deniedVerbs returns the verbs of reflectorVerbs the client is not allowed on
+// the resource in namespace, as reviewed by SelfSubjectAccessReviews.
+func deniedVerbs(ctx context.Context, dc dynamic.Interface, gvr schema.GroupVersionResource, namespace string) ([]string, error) {
+	var denied []string
+	for _, verb := range reflectorVerbs {
+		review := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "authorization.k8s.io/v1",
+			"kind":       "SelfSubjectAccessReview",
+			"spec": map[string]any{"resourceAttributes": map[string]any{
+				"group":     gvr.Group,
+				"version":   gvr.Version,
+				"resource":  gvr.Resource,
+				"namespace": namespace,
+				"verb":      verb,
+			}},
+		}}
+		res, err := dc.Resource(ssarGVR).Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if allowed, _, _ := unstructured.NestedBool(res.Object, "status", "allowed"); !allowed {
+			denied = append(denied, verb)
+		}
+	}
+	return denied, nil
+}
+
+// waitForPermissions blocks until the client is allowed to list and watch the
+// resource in namespace, checking again with the backoff of reflector
+// restarts. It returns false if ctx is done before. Failed reviews are logged
+// and don't block the reflector. Missing permissions are reported to report
+// unless it is nil.
+func waitForPermissions(ctx context.Context, dc dynamic.Interface, gvr schema.GroupVersionResource, namespace string, report func(error)) bool {
+	log := log.FromContext(ctx)
+	backoff := wait.NewExponentialBackoffManager(reflectorBackoffInitial, reflectorBackoffMax, reflectorBackoffReset, reflectorBackoffFactor, reflectorBackoffJitter, clock.RealClock{})
+	waiting := false
+	defer func() {
+		if waiting {
+			missingPermissions.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Dec()
+		}
+	}()
+	for {
+		denied, err := deniedVerbs(ctx, dc, gvr, namespace)
+		if err != nil {
+			log.Info("cannot review permissions, starting reflector", "error", err.Error())
+			return true
+		}
+		if len(denied) == 0 {
+			if waiting {
+				log.Info("permissions granted, starting reflector")
+			}
+			return true
+		}
+		if !waiting {
+			waiting = true
+			missingPermissions.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Inc()
+			log.Error(nil, "missing permissions, waiting for them to be granted", "verbs", strings.Join(denied, ","))
+			warn(ctx, ReasonMissingPermissions, "missing permissions to %s %s, waiting for them to be granted", strings.Join(denied, ", "), gvr.String())
+			if report != nil {
+				report(fmt.Errorf("missing permissions to %s %s", strings.Join(denied, ", "), gvr.String()))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-backoff.Backoff().C():
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// reviewingClient returns a client allowing the verbs of allowed in
+// SelfSubjectAccessReviews, or failing them with err.
+func reviewingClient(allowed map[string]bool, err error) *dynamicfake.FakeDynamicClient {
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dc.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if err != nil {
+			return true, nil, err
+		}
+		review := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		verb, _, _ := unstructured.NestedString(review.Object, "spec", "resourceAttributes", "verb")
+		_ = unstructured.SetNestedField(review.Object, allowed[verb], "status", "allowed")
+		return true, review, nil
+	})
+	return dc
+}
+
+func TestWaitForPermissions(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+
+	type want struct {
+		denied []string
+		start  bool
+	}
+	cases := map[string]struct {
+		reason  string
+		allowed map[string]bool
+		err     error
+		want    want
+	}{
+		"Allowed": {
+			reason:  "Should start the reflector if listing and watching is allowed.",
+			allowed: map[string]bool{"list": true, "watch": true},
+			want:    want{start: true},
+		},
+		"WatchDenied": {
+			reason:  "Should wait until the missing watch permission is granted.",
+			allowed: map[string]bool{"list": true},
+			want:    want{denied: []string{"watch"}},
+		},
+		"ReviewFailed": {
+			reason: "Should start the reflector if the permissions cannot be reviewed.",
+			err:    errors.New("the server could not find the requested resource"),
+			want:   want{start: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dc := reviewingClient(tc.allowed, tc.err)
+			got := want{}
+			got.denied, _ = deniedVerbs(context.Background(), dc, gvr, "")
+
+			// The reflector of a store waiting for permissions only stops
+			// with its context
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			got.start = waitForPermissions(ctx, dc, gvr, "", nil)
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nwaitForPermissions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import "strings"
+
+// Redaction is how info mappings of sensitive fields are exported.
+type Redaction string
+
+const (
+	// RedactionRedact exports the labels of sensitive fields with the value
+	// <redacted>, so that the label set doesn't change
+	RedactionRedact Redaction = "Redact"
+	// RedactionDrop omits the labels of sensitive fields
+	RedactionDrop Redaction = "Drop"
+)
+
+// redactedValue is the value of the labels of redacted fields.
+const redactedValue = "<redacted>"
+
+// DefaultSensitiveFields are the glob patterns of field paths never exposed
+// by info mappings unless ResourceOptions.SensitiveFields is set.
+var DefaultSensitiveFields = []string{"*password*", "*credential*", "*secret*", "*token*", "*privatekey*", "*accesskey*"}
+
+// secretReferences are the glob patterns of field paths referencing a
+// Secret, e.g. spec.writeConnectionSecretToRef.name. They hold the name of the
+// Secret rather than its data, so DefaultSensitiveFields don't match them.
+var secretReferences = []string{"*secretref*", "*secrettoref*"}
+
+// sensitiveFields returns the patterns of the sensitive fields of the
+// resource, the defaults if unset.
+func (o ResourceOptions) sensitiveFields() []string {
+	if o.SensitiveFields == nil {
+		return DefaultSensitiveFields
+	}
+	return o.SensitiveFields
+}
+
+// sensitive returns true if the field path matches one of the patterns of
+// sensitive fields, ignoring case.
+func (o ResourceOptions) sensitive(path string) bool {
+	path = strings.ToLower(path)
+	if o.SensitiveFields == nil {
+		for _, p := range secretReferences {
+			if globMatch(p, path) {
+				return false
+			}
+		}
+	}
+	for _, p := range o.sensitiveFields() {
+		if globMatch(strings.ToLower(p), path) {
+			return true
+		}
+	}
+	return false
+}
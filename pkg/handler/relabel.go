@@ -0,0 +1,237 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+// RelabelAction is the action of a RelabelConfig.
+type RelabelAction string
+
+const (
+	// RelabelReplace sets TargetLabel to Replacement if Regex matches the
+	// joined SourceLabels. An empty result removes TargetLabel
+	RelabelReplace RelabelAction = "replace"
+	// RelabelKeep drops series whose joined SourceLabels don't match Regex
+	RelabelKeep RelabelAction = "keep"
+	// RelabelDrop drops series whose joined SourceLabels match Regex
+	RelabelDrop RelabelAction = "drop"
+	// RelabelLabelMap copies the values of labels whose names match Regex to
+	// labels named by Replacement
+	RelabelLabelMap RelabelAction = "labelmap"
+	// RelabelLabelDrop removes labels whose names match Regex
+	RelabelLabelDrop RelabelAction = "labeldrop"
+	// RelabelLabelKeep removes labels whose names don't match Regex
+	RelabelLabelKeep RelabelAction = "labelkeep"
+)
+
+// nameLabel holds the family name of a series in SourceLabels. It can't be
+// changed.
+const nameLabel = "__name__"
+
+// RelabelConfig rewrites the labels of the generated series, like the relabel
+// configs of Prometheus.
+type RelabelConfig struct {
+	// SourceLabels are joined by Separator and matched against Regex. The
+	// family name is available as __name__
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	// Separator joins the values of SourceLabels. Defaults to ;
+	Separator *string `json:"separator,omitempty"`
+	// Regex is matched against the joined SourceLabels, or the label names
+	// for the labelmap, labeldrop and labelkeep actions. Defaults to (.*)
+	Regex string `json:"regex,omitempty"`
+	// TargetLabel is set by the replace action, may refer to capture groups
+	TargetLabel string `json:"targetLabel,omitempty"`
+	// Replacement is the value of TargetLabel, or the name of the labels
+	// mapped by the labelmap action, may refer to capture groups. Defaults
+	// to $1
+	Replacement *string `json:"replacement,omitempty"`
+	// Action is one of replace, keep, drop, labelmap, labeldrop and
+	// labelkeep. Defaults to replace
+	Action RelabelAction `json:"action,omitempty"`
+}
+
+func (c RelabelConfig) validate() error {
+	switch c.Action {
+	case "", RelabelReplace:
+		if c.TargetLabel == "" {
+			return fmt.Errorf("targetLabel must not be empty")
+		}
+		if c.TargetLabel == nameLabel {
+			return fmt.Errorf("targetLabel: %s can't be changed", nameLabel)
+		}
+	case RelabelKeep, RelabelDrop:
+		if len(c.SourceLabels) == 0 {
+			return fmt.Errorf("sourceLabels must not be empty")
+		}
+	case RelabelLabelMap, RelabelLabelDrop, RelabelLabelKeep:
+	default:
+		return fmt.Errorf("unknown action %q", c.Action)
+	}
+	if _, err := compileRelabelRegex(c.Regex); err != nil {
+		return fmt.Errorf("regex: %w", err)
+	}
+	return nil
+}
+
+func compileRelabelRegex(regex string) (*regexp.Regexp, error) {
+	if regex == "" {
+		regex = "(.*)"
+	}
+	// anchored like the regular expressions of Prometheus
+	return regexp.Compile("^(?:" + regex + ")$")
+}
+
+// relabeling is a compiled list of relabel configs.
+type relabeling []relabelRule
+
+type relabelRule struct {
+	RelabelConfig
+	regex       *regexp.Regexp
+	separator   string
+	replacement string
+}
+
+// newRelabeling compiles configs. Invalid configs, rejected by
+// Config.Validate, are skipped.
+func newRelabeling(configs []RelabelConfig) relabeling {
+	r := make(relabeling, 0, len(configs))
+	for _, c := range configs {
+		re, err := compileRelabelRegex(c.Regex)
+		if err != nil || c.validate() != nil {
+			continue
+		}
+		rule := relabelRule{RelabelConfig: c, regex: re, separator: ";", replacement: "$1"}
+		if c.Separator != nil {
+			rule.separator = *c.Separator
+		}
+		if c.Replacement != nil {
+			rule.replacement = *c.Replacement
+		}
+		if rule.Action == "" {
+			rule.Action = RelabelReplace
+		}
+		r = append(r, rule)
+	}
+	return r
+}
+
+// apply rewrites the labels of a series of the named family. keys and values
+// are modified in place. It returns false if the series is dropped.
+func (r relabeling) apply(name string, keys, values []string) ([]string, []string, bool) {
+	for _, rule := range r {
+		switch rule.Action {
+		case RelabelReplace:
+			v := rule.source(name, keys, values)
+			idx := rule.regex.FindStringSubmatchIndex(v)
+			if idx == nil {
+				continue
+			}
+			target := string(rule.regex.ExpandString(nil, rule.TargetLabel, v, idx))
+			value := string(rule.regex.ExpandString(nil, rule.replacement, v, idx))
+			keys, values = setLabel(keys, values, target, value)
+		case RelabelKeep:
+			if !rule.regex.MatchString(rule.source(name, keys, values)) {
+				return keys, values, false
+			}
+		case RelabelDrop:
+			if rule.regex.MatchString(rule.source(name, keys, values)) {
+				return keys, values, false
+			}
+		case RelabelLabelMap:
+			// map the labels present before the rule only
+			var mappedKeys, mappedValues []string
+			for i, k := range keys {
+				if rule.regex.MatchString(k) {
+					mappedKeys = append(mappedKeys, rule.regex.ReplaceAllString(k, rule.replacement))
+					mappedValues = append(mappedValues, values[i])
+				}
+			}
+			for i, k := range mappedKeys {
+				keys, values = setLabel(keys, values, k, mappedValues[i])
+			}
+		case RelabelLabelDrop, RelabelLabelKeep:
+			drop := rule.Action == RelabelLabelDrop
+			j := 0
+			for i := range keys {
+				if rule.regex.MatchString(keys[i]) != drop {
+					keys[j], values[j] = keys[i], values[i]
+					j++
+				}
+			}
+			keys, values = keys[:j], values[:j]
+		}
+	}
+	return keys, values, true
+}
+
+// series returns a series of the named family with its labels rewritten, nil
+// if it is dropped. keys and values are not modified.
+func (r relabeling) series(name string, keys, values []string, value float64) *metric.Metric {
+	if len(r) > 0 {
+		var keep bool
+		if keys, values, keep = r.apply(name, appendLabels(keys), appendLabels(values)); !keep {
+			return nil
+		}
+	}
+	return &metric.Metric{LabelKeys: keys, LabelValues: values, Value: value}
+}
+
+// source returns the joined values of the source labels of a series.
+func (rule relabelRule) source(name string, keys, values []string) string {
+	vs := make([]string, len(rule.SourceLabels))
+	for i, l := range rule.SourceLabels {
+		if l == nameLabel {
+			vs[i] = name
+			continue
+		}
+		for j, k := range keys {
+			if k == l {
+				vs[i] = values[j]
+				break
+			}
+		}
+	}
+	return strings.Join(vs, rule.separator)
+}
+
+// setLabel sets the label key to value, removing it if value is empty.
+// Invalid label names are ignored.
+func setLabel(keys, values []string, key, value string) ([]string, []string) {
+	if !ValidLabelName(key) {
+		return keys, values
+	}
+	for i, k := range keys {
+		if k != key {
+			continue
+		}
+		if value == "" {
+			return append(keys[:i], keys[i+1:]...), append(values[:i], values[i+1:]...)
+		}
+		values[i] = value
+		return keys, values
+	}
+	if value == "" {
+		return keys, values
+	}
+	return append(keys, key), append(values, value)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/utils/pointer"
+)
+
+func TestRelabeling(t *testing.T) {
+	type want struct {
+		keys   []string
+		values []string
+		keep   bool
+	}
+	cases := map[string]struct {
+		reason  string
+		configs []RelabelConfig
+		keys    []string
+		values  []string
+		want    want
+	}{
+		"Rename": {
+			reason: "Should rename a label by mapping it and dropping the original.",
+			configs: []RelabelConfig{
+				{Action: RelabelLabelMap, Regex: "label_app_kubernetes_io_(.+)", Replacement: pointer.String("app_$1")},
+				{Action: RelabelLabelDrop, Regex: "label_app_kubernetes_io_.+"},
+			},
+			keys:   []string{"name", "label_app_kubernetes_io_name"},
+			values: []string{"obj", "db"},
+			want:   want{keys: []string{"name", "app_name"}, values: []string{"obj", "db"}, keep: true},
+		},
+		"Replace": {
+			reason: "Should map values with the capture groups of the regex.",
+			configs: []RelabelConfig{
+				{SourceLabels: []string{"name", "namespace"}, Regex: "(.+)-[0-9]+;(.+)", TargetLabel: "app", Replacement: pointer.String("$2/$1")},
+			},
+			keys:   []string{"name", "namespace"},
+			values: []string{"db-1", "team-a"},
+			want:   want{keys: []string{"name", "namespace", "app"}, values: []string{"db-1", "team-a", "team-a/db"}, keep: true},
+		},
+		"ReplaceEmpty": {
+			reason: "Should remove the target label if the replacement is empty.",
+			configs: []RelabelConfig{
+				{SourceLabels: []string{"namespace"}, TargetLabel: "namespace", Replacement: pointer.String("")},
+			},
+			keys:   []string{"name", "namespace"},
+			values: []string{"obj", "team-a"},
+			want:   want{keys: []string{"name"}, values: []string{"obj"}, keep: true},
+		},
+		"ReplaceNoMatch": {
+			reason: "Should keep the labels if the regex doesn't match.",
+			configs: []RelabelConfig{
+				{SourceLabels: []string{"name"}, Regex: "db", TargetLabel: "app"},
+			},
+			keys:   []string{"name"},
+			values: []string{"db-1"},
+			want:   want{keys: []string{"name"}, values: []string{"db-1"}, keep: true},
+		},
+		"LabelKeep": {
+			reason:  "Should remove the labels not matching the regex.",
+			configs: []RelabelConfig{{Action: RelabelLabelKeep, Regex: "name|type"}},
+			keys:    []string{"name", "namespace", "type"},
+			values:  []string{"obj", "team-a", "Ready"},
+			want:    want{keys: []string{"name", "type"}, values: []string{"obj", "Ready"}, keep: true},
+		},
+		"Drop": {
+			reason:  "Should drop series by their family name.",
+			configs: []RelabelConfig{{Action: RelabelDrop, SourceLabels: []string{"__name__"}, Regex: ".*_status_reason"}},
+			keys:    []string{"name"},
+			values:  []string{"obj"},
+			want:    want{keys: []string{"name"}, values: []string{"obj"}},
+		},
+		"Keep": {
+			reason:  "Should drop series not matching the regex.",
+			configs: []RelabelConfig{{Action: RelabelKeep, SourceLabels: []string{"namespace"}, Regex: "team-.*"}},
+			keys:    []string{"name", "namespace"},
+			values:  []string{"obj", "team-a"},
+			want:    want{keys: []string{"name", "namespace"}, values: []string{"obj", "team-a"}, keep: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			keys, values, keep := newRelabeling(tc.configs).apply("test_status_reason", tc.keys, tc.values)
+			got := want{keys: keys, values: values, keep: keep}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\napply(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultReloadInterval is the default interval between two checks of the
+// configuration file.
+const DefaultReloadInterval = 10 * time.Second
+
+// ConfigReloader applies changes of a configuration file to a handler without
+// a restart. The file is read every Interval instead of watched, as mounted
+// ConfigMaps are updated by replacing a symlink.
+type ConfigReloader struct {
+	// Path of the configuration file
+	Path    string
+	Handler *ManagedMetricsHandler
+	// Interval between two checks of the file. Defaults to DefaultReloadInterval
+	Interval time.Duration
+	// Elected delays registering the stores of the watched resources until it
+	// is closed, e.g. by the manager's Elected, so that standby replicas don't
+	// list them. The stores are registered right away if nil
+	Elected <-chan struct{}
+}
+
+// Start registers the stores of the watched resources of the configuration
+// and reloads it until ctx is done. Invalid configurations are logged and not
+// applied. It implements manager.Runnable.
+func (r *ConfigReloader) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithValues("path", r.Path)
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	last, err := os.ReadFile(r.Path)
+	if err != nil {
+		return err
+	}
+	// config is nil until a valid configuration was read
+	var config *Config
+	if c, err := ParseConfig(last); err != nil {
+		log.Error(err, "cannot load config, keeping the previous config")
+	} else {
+		config = &c
+	}
+	elected := r.Elected
+	apply := func() {
+		switch {
+		case config == nil:
+		case elected != nil:
+			r.Handler.SetConfig(ctx, unwatched(*config))
+		default:
+			r.Handler.SetConfig(ctx, *config)
+		}
+	}
+	apply()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-elected:
+			elected = nil
+			apply()
+			continue
+		case <-t.C:
+		}
+		b, err := os.ReadFile(r.Path)
+		if err != nil || bytes.Equal(b, last) {
+			continue
+		}
+		last = b
+		c, err := ParseConfig(b)
+		if err != nil {
+			log.Error(err, "cannot reload config, keeping the previous config")
+			continue
+		}
+		log.Info("reloading config")
+		config = &c
+		apply()
+	}
+}
+
+// unwatched returns config without watched resources.
+func unwatched(config Config) Config {
+	resources := make([]ResourceConfig, len(config.Resources))
+	for i, r := range config.Resources {
+		r.Watch = false
+		resources[i] = r
+	}
+	config.Resources = resources
+	return config
+}
+
+// NeedLeaderElection returns false, so that standby replicas are configured
+// when they take over.
+func (r *ConfigReloader) NeedLeaderElection() bool {
+	return false
+}
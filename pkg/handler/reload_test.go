@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestConfigReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("listPageSize: 100\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManagedMetricsHandler(nil, Config{ListPageSize: 100})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &ConfigReloader{Path: path, Handler: &m, Interval: 10 * time.Millisecond}
+	go func() { _ = r.Start(ctx) }()
+
+	pageSize := func() int64 {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return m.config.ListPageSize
+	}
+	// Invalid configurations are not applied
+	if err := os.WriteFile(path, []byte("naming:\n  strategy: Unknown\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := pageSize(); got != 100 {
+		t.Fatalf("Start(...): want invalid config ignored, got listPageSize %d", got)
+	}
+	if err := os.WriteFile(path, []byte("listPageSize: 200\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for pageSize() != 200 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Start(...): want reloaded listPageSize 200, got %d", pageSize())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConfigReloaderElected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "resources:\n- group: rds.aws.upbound.io\n  version: v1beta1\n  resource: instances\n  kind: Instance\n  watch: true\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"})
+	m := NewManagedMetricsHandler(dc, Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	elected := make(chan struct{})
+	r := &ConfigReloader{Path: path, Handler: &m, Interval: 10 * time.Millisecond, Elected: elected}
+	go func() { _ = r.Start(ctx) }()
+
+	registered := func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		_, ok := m.metricsWriter["rds_aws_upbound_io_Instance_v1beta1"]
+		return ok
+	}
+	time.Sleep(50 * time.Millisecond)
+	if registered() {
+		t.Fatalf("Start(...): want watched resources not registered before the election")
+	}
+	close(elected)
+	deadline := time.Now().Add(10 * time.Second)
+	for !registered() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Start(...): want watched resources registered after the election")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Values of the X-Cache header of responses served by a responseCache
+const (
+	cacheHit  = "HIT"
+	cacheMiss = "MISS"
+)
+
+// responseCache holds rendered responses for a TTL, so that frequent scrapes,
+// e.g. of several Prometheus replicas, don't render the same stores again.
+// Responses are cached by path, query, format and encoding.
+type responseCache struct {
+	mu        sync.Mutex
+	responses map[string]*cachedResponse
+	now       func() time.Time
+}
+
+type cachedResponse struct {
+	// mu is held while the response is rendered, so that concurrent
+	// requests wait for it instead of rendering it again
+	mu      sync.Mutex
+	expires time.Time
+	header  http.Header
+	body    []byte
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{responses: map[string]*cachedResponse{}, now: time.Now}
+}
+
+// serve writes the cached response of r, or the response rendered by render
+// if none is cached or it is older than ttl.
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, ttl time.Duration, render func(w http.ResponseWriter)) {
+	key := r.URL.Path + "?" + r.URL.Query().Encode() + "|" + string(negotiateFormat(r.Header)) + "|" + strconv.FormatBool(acceptsGzip(r.Header))
+
+	c.mu.Lock()
+	now := c.now()
+	for k, e := range c.responses {
+		if k != key && e.mu.TryLock() {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(c.responses, k)
+			}
+			e.mu.Unlock()
+		}
+	}
+	e, ok := c.responses[key]
+	if !ok {
+		e = &cachedResponse{}
+		c.responses[key] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := cacheHit
+	if now = c.now(); !now.Before(e.expires) {
+		rec := &responseRecorder{header: http.Header{}}
+		render(rec)
+		e.header, e.body, e.expires = rec.header, rec.body.Bytes(), now.Add(ttl)
+		status = cacheMiss
+	}
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("X-Cache", status)
+	w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(e.expires.Sub(now).Seconds())))
+	_, _ = w.Write(e.body)
+}
+
+// responseRecorder records the header and body of a rendered response.
+type responseRecorder struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func (r *responseRecorder) WriteHeader(int) {}
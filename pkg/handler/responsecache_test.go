@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResponseCache(t *testing.T) {
+	type want struct {
+		cache    []string
+		rendered int
+	}
+	cases := map[string]struct {
+		reason string
+		paths  []string
+		// advance is the time passed before each request
+		advance time.Duration
+		want    want
+	}{
+		"Hit": {
+			reason:  "Should serve a response rendered within the TTL from the cache.",
+			paths:   []string{"/x-metrics", "/x-metrics"},
+			advance: 5 * time.Second,
+			want:    want{cache: []string{cacheMiss, cacheHit}, rendered: 1},
+		},
+		"Expired": {
+			reason:  "Should render a response again once it is older than the TTL.",
+			paths:   []string{"/x-metrics", "/x-metrics"},
+			advance: 10 * time.Second,
+			want:    want{cache: []string{cacheMiss, cacheMiss}, rendered: 2},
+		},
+		"Query": {
+			reason: "Should cache the responses of different queries separately.",
+			paths:  []string{"/x-metrics?include=a", "/x-metrics?include=b", "/x-metrics?include=a"},
+			want:   want{cache: []string{cacheMiss, cacheMiss, cacheHit}, rendered: 2},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			c := newResponseCache()
+			c.now = func() time.Time { return now }
+			got := want{}
+			for _, p := range tc.paths {
+				now = now.Add(tc.advance)
+				rec := httptest.NewRecorder()
+				c.serve(rec, httptest.NewRequest("GET", p, nil), 10*time.Second, func(w http.ResponseWriter) {
+					got.rendered++
+					_, _ = w.Write([]byte(strconv.Itoa(got.rendered)))
+				})
+				got.cache = append(got.cache, rec.Header().Get("X-Cache"))
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nserve(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
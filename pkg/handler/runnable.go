@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// DefaultPath is the default path the stores are served at on the metrics
+// endpoint of a manager.
+const DefaultPath = "/x-metrics"
+
+// Runnable embeds a handler into a controller-runtime manager: the stores are
+// served on the metrics endpoint of the manager, their sync is part of its
+// readiness checks and they are closed when the manager shuts down.
+type Runnable struct {
+	Handler *ManagedMetricsHandler
+	// Path the stores are served at, each store at Path/<name>. Defaults to
+	// DefaultPath
+	Path string
+	// ResourcesPath the registered resources are listed at, not served if
+	// empty
+	ResourcesPath string
+	// StoresPath the state of the registered stores is served at, e.g.
+	// /debug/stores, not served if empty
+	StoresPath string
+	// Pprof serves the runtime profiles at /debug/pprof/
+	Pprof bool
+	// BeforeClose is called when the manager shuts down before the stores are
+	// closed, e.g. to push their final state
+	BeforeClose func()
+}
+
+// SetupWithManager adds the handlers, the readiness check and the runnable
+// to mgr.
+func (r *Runnable) SetupWithManager(mgr manager.Manager) error {
+	path := r.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	if err := mgr.AddMetricsExtraHandler(path, r.Handler); err != nil {
+		return err
+	}
+	if err := mgr.AddMetricsExtraHandler(path+"/", r.Handler.StoreHandler(path+"/")); err != nil {
+		return err
+	}
+	if r.ResourcesPath != "" {
+		if err := mgr.AddMetricsExtraHandler(r.ResourcesPath, r.Handler.ResourcesHandler()); err != nil {
+			return err
+		}
+	}
+	if r.StoresPath != "" {
+		if err := mgr.AddMetricsExtraHandler(r.StoresPath, r.Handler.StoresDebugHandler()); err != nil {
+			return err
+		}
+	}
+	if r.Pprof {
+		for path, h := range PprofHandlers() {
+			if err := mgr.AddMetricsExtraHandler(path, h); err != nil {
+				return err
+			}
+		}
+	}
+	if err := mgr.AddReadyzCheck("metric-stores", r.Handler.ReadyzCheck); err != nil {
+		return err
+	}
+	return mgr.Add(r)
+}
+
+// Start closes the stores once ctx is done. It implements manager.Runnable.
+func (r *Runnable) Start(ctx context.Context) error {
+	<-ctx.Done()
+	if r.BeforeClose != nil {
+		r.BeforeClose()
+	}
+	r.Handler.Close()
+	return nil
+}
+
+// NeedLeaderElection returns false, so that the stores of all replicas are
+// closed on shutdown.
+func (r *Runnable) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestRunnableStart(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "rds.aws.upbound.io", Version: "v1beta1", Resource: "instances"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "InstanceList"})
+	m := NewManagedMetricsHandler(dc, Config{})
+	m.RegisterAndAddMetricStoreForGVR(context.Background(), "test", gvr, "")
+
+	var final bytes.Buffer
+	r := &Runnable{Handler: &m, BeforeClose: func() { m.WriteAll(&final) }}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start(...): %v", err)
+	}
+	if !strings.Contains(final.String(), "# TYPE test gauge") {
+		t.Errorf("Start(...): want store written before close, got:\n%s", final.String())
+	}
+	var closed bytes.Buffer
+	m.WriteAll(&closed)
+	if closed.Len() != 0 {
+		t.Errorf("Start(...): want stores closed on shutdown, got:\n%s", closed.String())
+	}
+}
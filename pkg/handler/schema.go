@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// ValidateSchema returns an error for each field path of the info mappings and
+// gauges that does not exist in the OpenAPI schema s of the resource.
+func (o ResourceOptions) ValidateSchema(s *apiextensions.JSONSchemaProps) []error {
+	var errs []error
+	for j, m := range o.InfoMappings {
+		if err := validateFieldPath(s, m.FieldPath); err != nil {
+			errs = append(errs, fmt.Errorf("infoMappings[%d]: %w", j, err))
+		}
+	}
+	for j, g := range o.Gauges {
+		if err := validateFieldPath(s, g.FieldPath); err != nil {
+			errs = append(errs, fmt.Errorf("gauges[%d]: %w", j, err))
+		}
+	}
+	return errs
+}
+
+// VersionSchema returns the OpenAPI schema of a version of the CRD, nil if
+// the version has none.
+func VersionSchema(crd *apiextensions.CustomResourceDefinition, version string) *apiextensions.JSONSchemaProps {
+	for _, v := range crd.Spec.Versions {
+		if v.Name == version && v.Schema != nil {
+			return v.Schema.OpenAPIV3Schema
+		}
+	}
+	return nil
+}
+
+// validateFieldPath returns an error if path does not exist in s. Paths into
+// metadata, which is not part of the schema of CRDs, and into fields without
+// type or preserving unknown fields are not checked.
+func validateFieldPath(s *apiextensions.JSONSchemaProps, path string) error {
+	segments, err := fieldpath.Parse(path)
+	if err != nil {
+		return fmt.Errorf("cannot parse field path %q: %w", path, err)
+	}
+	if len(segments) > 0 && segments[0].Type == fieldpath.SegmentField && segments[0].Field == "metadata" {
+		return nil
+	}
+	for i, seg := range segments {
+		if s == nil || s.Type == "" || s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields {
+			return nil
+		}
+		var next *apiextensions.JSONSchemaProps
+		switch {
+		case s.Type == "array" && (seg.Type == fieldpath.SegmentIndex || seg.Field == "*"):
+			if s.Items != nil {
+				next = s.Items.Schema
+			}
+		case s.Type == "object" && seg.Type == fieldpath.SegmentField:
+			if p, ok := s.Properties[seg.Field]; ok {
+				next = &p
+				break
+			}
+			if s.AdditionalProperties != nil && (s.AdditionalProperties.Allows || s.AdditionalProperties.Schema != nil) {
+				next = s.AdditionalProperties.Schema
+				break
+			}
+			if seg.Field == "*" {
+				return nil
+			}
+			return fmt.Errorf("field path %q: field %q not found in schema", path, fieldpath.Segments(segments[:i+1]).String())
+		default:
+			return fmt.Errorf("field path %q: %q is of type %q", path, fieldpath.Segments(segments[:i]).String(), s.Type)
+		}
+		s = next
+	}
+	return nil
+}
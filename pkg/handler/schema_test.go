@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestValidateFieldPath(t *testing.T) {
+	preserve := true
+	s := &apiextensions.JSONSchemaProps{Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+		"spec": {Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+			"forProvider": {Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+				"region": {Type: "string"},
+				"tags": {Type: "object", AdditionalProperties: &apiextensions.JSONSchemaPropsOrBool{
+					Allows: true, Schema: &apiextensions.JSONSchemaProps{Type: "string"},
+				}},
+				"subnets": {Type: "array", Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{Type: "object", Properties: map[string]apiextensions.JSONSchemaProps{
+						"id": {Type: "string"},
+					}},
+				}},
+				"parameters": {Type: "object", XPreserveUnknownFields: &preserve},
+			}},
+		}},
+	}}
+
+	cases := map[string]struct {
+		reason  string
+		path    string
+		wantErr bool
+	}{
+		"Field": {
+			reason: "Should accept a path of nested properties.",
+			path:   "spec.forProvider.region",
+		},
+		"MapKey": {
+			reason: "Should accept a key of a map.",
+			path:   "spec.forProvider.tags[team]",
+		},
+		"ArrayIndex": {
+			reason: "Should accept a field of an array item.",
+			path:   "spec.forProvider.subnets[0].id",
+		},
+		"PreserveUnknownFields": {
+			reason: "Should accept any path below a field preserving unknown fields.",
+			path:   "spec.forProvider.parameters.engine.version",
+		},
+		"Metadata": {
+			reason: "Should accept paths into metadata, which is not part of the schema.",
+			path:   "metadata.annotations[crossplane.io/external-name]",
+		},
+		"UnknownField": {
+			reason:  "Should reject a field which is not in the schema.",
+			path:    "spec.forProvider.zone",
+			wantErr: true,
+		},
+		"IndexOfObject": {
+			reason:  "Should reject an index into an object.",
+			path:    "spec.forProvider[0]",
+			wantErr: true,
+		},
+		"FieldOfString": {
+			reason:  "Should reject a field of a string.",
+			path:    "spec.forProvider.region.name",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateFieldPath(s, tc.path)
+			if diff := cmp.Diff(tc.wantErr, err != nil); diff != "" {
+				t.Errorf("\n%s\nvalidateFieldPath(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+		})
+	}
+}
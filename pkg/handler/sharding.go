@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Sharding selects the objects exported by an instance of x-metrics, so that
+// the objects of large clusters can be distributed across replicas. Objects
+// are assigned to shards by the hash of their UID, as done by
+// kube-state-metrics.
+type Sharding struct {
+	// Shard is the index of this instance, from 0 to TotalShards-1
+	Shard int32
+	// TotalShards is the number of instances. All objects are exported if
+	// it is 1 or less.
+	TotalShards int32
+}
+
+// Validate returns an error if Shard is out of range.
+func (s Sharding) Validate() error {
+	if s.TotalShards > 1 && (s.Shard < 0 || s.Shard >= s.TotalShards) {
+		return fmt.Errorf("shard %d must be in [0, %d)", s.Shard, s.TotalShards)
+	}
+	return nil
+}
+
+// owns returns true if the object with the given UID belongs to this shard.
+func (s Sharding) owns(uid types.UID) bool {
+	if s.TotalShards <= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(uid))
+	return h.Sum64()%uint64(s.TotalShards) == uint64(s.Shard)
+}
+
+// shardList removes the items of a list not owned by the shard.
+func shardList(list *unstructured.UnstructuredList, s Sharding) {
+	if s.TotalShards <= 1 {
+		return
+	}
+	items := list.Items[:0]
+	for _, item := range list.Items {
+		if s.owns(item.GetUID()) {
+			items = append(items, item)
+		}
+	}
+	list.Items = items
+}
+
+// shardEvent returns false for events of objects not owned by the shard.
+func shardEvent(e watch.Event, s Sharding) bool {
+	obj, ok := e.Object.(*unstructured.Unstructured)
+	if !ok || e.Type == watch.Bookmark || e.Type == watch.Error {
+		return true
+	}
+	return s.owns(obj.GetUID())
+}
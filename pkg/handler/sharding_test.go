@@ -0,0 +1,128 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestShardingOwns(t *testing.T) {
+	const objects = 1000
+	cases := map[string]struct {
+		reason      string
+		totalShards int32
+	}{
+		"Unsharded": {
+			reason:      "Should own all objects without sharding.",
+			totalShards: 1,
+		},
+		"ThreeShards": {
+			reason:      "Should assign each object to exactly one shard.",
+			totalShards: 3,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < objects; i++ {
+				uid := types.UID(fmt.Sprintf("uid-%d", i))
+				owners := 0
+				for s := int32(0); s < tc.totalShards; s++ {
+					if (Sharding{Shard: s, TotalShards: tc.totalShards}).owns(uid) {
+						owners++
+					}
+				}
+				if owners != 1 {
+					t.Fatalf("\n%s\nowns(%q): want 1 owner, got %d", tc.reason, uid, owners)
+				}
+			}
+		})
+	}
+}
+
+func TestShardListAndEvents(t *testing.T) {
+	s := Sharding{Shard: 0, TotalShards: 2}
+	var owned, other []string
+	list := &unstructured.UnstructuredList{}
+	for i := 0; i < 10; i++ {
+		u := unstructured.Unstructured{Object: map[string]any{}}
+		u.SetName(fmt.Sprintf("obj-%d", i))
+		u.SetUID(types.UID(u.GetName()))
+		list.Items = append(list.Items, u)
+		if s.owns(u.GetUID()) {
+			owned = append(owned, u.GetName())
+		} else {
+			other = append(other, u.GetName())
+		}
+	}
+	if len(owned) == 0 || len(other) == 0 {
+		t.Fatalf("want objects of both shards, got %v and %v", owned, other)
+	}
+
+	events := map[string]struct {
+		reason string
+		event  watch.Event
+		want   bool
+	}{
+		"Owned": {
+			reason: "Should keep events of owned objects.",
+			event:  watch.Event{Type: watch.Modified, Object: &list.Items[indexOf(list, owned[0])]},
+			want:   true,
+		},
+		"Other": {
+			reason: "Should drop events of objects of other shards.",
+			event:  watch.Event{Type: watch.Deleted, Object: &list.Items[indexOf(list, other[0])]},
+			want:   false,
+		},
+		"Bookmark": {
+			reason: "Should keep bookmarks.",
+			event:  watch.Event{Type: watch.Bookmark, Object: &unstructured.Unstructured{Object: map[string]any{}}},
+			want:   true,
+		},
+	}
+	for name, tc := range events {
+		t.Run(name, func(t *testing.T) {
+			if got := shardEvent(tc.event, s); got != tc.want {
+				t.Errorf("\n%s\nshardEvent(...): want %t, got %t", tc.reason, tc.want, got)
+			}
+		})
+	}
+
+	shardList(list, s)
+	var got []string
+	for _, item := range list.Items {
+		got = append(got, item.GetName())
+	}
+	if diff := cmp.Diff(owned, got); diff != "" {
+		t.Errorf("shardList(...): -want, +got:\n%s", diff)
+	}
+}
+
+func indexOf(list *unstructured.UnstructuredList, name string) int {
+	for i, item := range list.Items {
+		if item.GetName() == name {
+			return i
+		}
+	}
+	return -1
+}
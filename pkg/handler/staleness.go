@@ -0,0 +1,171 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
+)
+
+// StaleAction decides how the series of stale stores are served.
+type StaleAction string
+
+const (
+	// StaleDrop stops serving the series of stale stores
+	StaleDrop StaleAction = "Drop"
+	// StaleLabel adds the label stale="true" to the series of stale stores
+	StaleLabel StaleAction = "Label"
+)
+
+// MinStaleAfter is the minimum of Staleness.After. The API server sends
+// bookmarks to the watches about once a minute, so healthy stores of objects
+// that don't change sync at least that often.
+const MinStaleAfter = 2 * time.Minute
+
+// Staleness configures how the series of stores, whose reflectors didn't list,
+// watch or receive an event or bookmark for a while, are served.
+type Staleness struct {
+	// After is the time since the last sync of a store after which it is
+	// stale, at least MinStaleAfter. Stores are never stale if zero
+	After metav1.Duration `json:"after,omitempty"`
+	// Action decides how the series of stale stores are served. Defaults to Drop
+	Action StaleAction `json:"action,omitempty"`
+}
+
+// stale returns true if a store last synced at lastSync is stale at now.
+// Stores that never synced hold no series and are not stale.
+func (s Staleness) stale(lastSync, now time.Time) bool {
+	return s.After.Duration > 0 && !lastSync.IsZero() && now.Sub(lastSync) > s.After.Duration
+}
+
+// drops returns true if the series of a store synced at lastSync are dropped
+// as stale at now.
+func (s Staleness) drops(lastSync, now time.Time) bool {
+	return s.stale(lastSync, now) && s.Action != StaleLabel
+}
+
+// writer returns the writer serving a stale store, or nil if its series are
+// dropped.
+func (s Staleness) writer(w metricsstore.MetricsWriter) metricsstore.MetricsWriter {
+	if s.Action == StaleLabel {
+		return staleWriter{w}
+	}
+	return nil
+}
+
+// recordWatches records the start of each watch of lw and its bookmarks as
+// sync of s, so that a store of objects that don't change isn't stale. Events
+// of objects are recorded by the store itself.
+func recordWatches(lw *cache.ListWatch, s interface{ recordSync() }) {
+	watchFunc := lw.WatchFunc
+	lw.WatchFunc = func(opt metav1.ListOptions) (watch.Interface, error) {
+		w, err := watchFunc(opt)
+		if err != nil {
+			return w, err
+		}
+		s.recordSync()
+		return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+			if e.Type == watch.Bookmark {
+				s.recordSync()
+			}
+			return e, true
+		}), nil
+	}
+}
+
+// staleWriter writes the families of a store with the label stale="true"
+// added to each series.
+type staleWriter struct {
+	metricsstore.MetricsWriter
+}
+
+func (s staleWriter) WriteAll(w io.Writer) {
+	s.MetricsWriter.WriteAll(&lineWriter{w: w, fn: staleLine})
+}
+
+// staleLine adds the label stale="true" to a series line, e.g.
+// a{name="x"} 1 to a{stale="true",name="x"} 1. The label is inserted after
+// the opening brace, as label values may contain braces, while metric names
+// don't. Comments are returned as is.
+func staleLine(line []byte) []byte {
+	if len(line) == 0 || line[0] == '#' || line[0] == '\n' {
+		return line
+	}
+	if i := bytes.IndexByte(line, '{'); i >= 0 {
+		label := `stale="true",`
+		if i+1 < len(line) && line[i+1] == '}' {
+			label = `stale="true"`
+		}
+		return append(append(append([]byte{}, line[:i+1]...), label...), line[i+1:]...)
+	}
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 {
+		return line
+	}
+	return append(append(append([]byte{}, line[:i]...), `{stale="true"}`...), line[i:]...)
+}
+
+var (
+	storeLastSyncDesc = prometheus.NewDesc("x_metrics_store_last_sync_timestamp_seconds",
+		"Unix timestamp of the last list, watch or event of the reflectors of a metric store, the oldest of its namespaces and clusters",
+		[]string{"store"}, nil)
+	storeStaleDesc = prometheus.NewDesc("x_metrics_store_stale",
+		"Whether a metric store is stale as configured by staleness (stale=1,otherwise=0)",
+		[]string{"store"}, nil)
+)
+
+// syncCollector exports the last sync and staleness of the stores of a
+// handler.
+type syncCollector struct {
+	m *ManagedMetricsHandler
+}
+
+// SyncCollector returns a collector of the time of the last sync of each
+// registered store and whether it is stale.
+func (m *ManagedMetricsHandler) SyncCollector() prometheus.Collector {
+	return syncCollector{m: m}
+}
+
+func (c syncCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- storeLastSyncDesc
+	ch <- storeStaleDesc
+}
+
+func (c syncCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.mu.RLock()
+	defer c.m.mu.RUnlock()
+	now := time.Now()
+	for name, s := range c.m.metricsWriter {
+		lastSync := s.lastSync()
+		if lastSync.IsZero() {
+			continue
+		}
+		var stale float64
+		if c.m.config.Staleness.stale(lastSync, now) {
+			stale = 1
+		}
+		ch <- prometheus.MustNewConstMetric(storeLastSyncDesc, prometheus.GaugeValue, float64(lastSync.Unix()), name)
+		ch <- prometheus.MustNewConstMetric(storeStaleDesc, prometheus.GaugeValue, stale, name)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestStaleness(t *testing.T) {
+	cases := map[string]struct {
+		reason    string
+		staleness Staleness
+		age       time.Duration
+		want      []string
+	}{
+		"Fresh": {
+			reason:    "Should serve the series of stores synced within the threshold.",
+			staleness: Staleness{After: metav1.Duration{Duration: 15 * time.Minute}},
+			age:       time.Minute,
+			want:      []string{`test{name="obj"} 1`},
+		},
+		"Disabled": {
+			reason: "Should serve the series of all stores without threshold.",
+			age:    time.Hour,
+			want:   []string{`test{name="obj"} 1`},
+		},
+		"Drop": {
+			reason:    "Should not serve stale stores by default.",
+			staleness: Staleness{After: metav1.Duration{Duration: 15 * time.Minute}},
+			age:       time.Hour,
+		},
+		"Label": {
+			reason:    "Should add the stale label to the series of stale stores.",
+			staleness: Staleness{After: metav1.Duration{Duration: 15 * time.Minute}, Action: StaleLabel},
+			age:       time.Hour,
+			want:      []string{`test{stale="true",name="obj"} 1`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewManagedMetricsHandler(nil, Config{Staleness: tc.staleness})
+			s := newInstrumentedStore(newMetricsStore("test", "", "", ResourceConfig{}, nil, nil), schema.GroupVersionResource{})
+			_ = s.Add(newObject(map[string]any{}))
+			s.syncTime = time.Now().Add(-tc.age)
+			m.addMetricStore("test", func() {}, s)
+
+			buf := &bytes.Buffer{}
+			m.WriteAll(buf)
+			var got []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(line, "test{") {
+					got = append(got, line)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nWriteAll(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStaleLine(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		line   string
+		want   string
+	}{
+		"Labels": {
+			reason: "Should add the label to the labels of a series.",
+			line:   "a{name=\"x\"} 1\n",
+			want:   "a{stale=\"true\",name=\"x\"} 1\n",
+		},
+		"BracesInValues": {
+			reason: "Should not take braces in label values for the end of the labels.",
+			line:   "a_annotations{name=\"x\",annotation_config=\"{\\\"a\\\":{}}\"} 1\n",
+			want:   "a_annotations{stale=\"true\",name=\"x\",annotation_config=\"{\\\"a\\\":{}}\"} 1\n",
+		},
+		"NoLabels": {
+			reason: "Should add labels to a series without.",
+			line:   "a 1\n",
+			want:   "a{stale=\"true\"} 1\n",
+		},
+		"EmptyLabels": {
+			reason: "Should add the label to empty labels.",
+			line:   "a{} 1\n",
+			want:   "a{stale=\"true\"} 1\n",
+		},
+		"Comment": {
+			reason: "Should not change comments.",
+			line:   "# TYPE a gauge\n",
+			want:   "# TYPE a gauge\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := string(staleLine([]byte(tc.line)))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nstaleLine(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
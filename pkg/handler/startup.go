@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// listLimiter limits the concurrent initial lists of the reflectors, so that
+// registering many resources at once, e.g. on startup, doesn't get the client
+// throttled. Waiting lists start in the order of their priority, lists of the
+// same priority in the order they were queued. A nil limiter doesn't limit the
+// lists.
+type listLimiter struct {
+	mu      sync.Mutex
+	max     int
+	running int
+	waiting []*pendingList
+	queued  uint64
+}
+
+// pendingList is a list waiting for a slot.
+type pendingList struct {
+	priority int
+	seq      uint64
+	start    chan struct{}
+}
+
+func newListLimiter(max int) *listLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &listLimiter{max: max}
+}
+
+// acquire blocks until an initial list of the given priority may start. It
+// returns the function releasing the list, which may be called more than
+// once, or false if ctx is done before.
+func (l *listLimiter) acquire(ctx context.Context, priority int) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	l.mu.Lock()
+	p := &pendingList{priority: priority, seq: l.queued, start: make(chan struct{})}
+	l.queued++
+	l.waiting = append(l.waiting, p)
+	pendingLists.Inc()
+	l.next()
+	l.mu.Unlock()
+
+	select {
+	case <-p.start:
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for i, w := range l.waiting {
+			if w == p {
+				l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+				pendingLists.Dec()
+				return nil, false
+			}
+		}
+		// The list started concurrently, free its slot again
+		l.running--
+		l.next()
+		return nil, false
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.running--
+			l.next()
+		})
+	}, true
+}
+
+// next starts the waiting lists of the highest priority while slots are free.
+// l.mu must be held.
+func (l *listLimiter) next() {
+	for l.running < l.max && len(l.waiting) > 0 {
+		first := 0
+		for i, w := range l.waiting {
+			if f := l.waiting[first]; w.priority > f.priority || (w.priority == f.priority && w.seq < f.seq) {
+				first = i
+			}
+		}
+		p := l.waiting[first]
+		l.waiting = append(l.waiting[:first], l.waiting[first+1:]...)
+		pendingLists.Dec()
+		l.running++
+		close(p.start)
+	}
+}
+
+// releaseAfterList calls release once lw returned the last page of a list or
+// a list failed, so that resources failing to list don't hold a slot while
+// retrying.
+func releaseAfterList(lw *cache.ListWatch, release func()) {
+	listFunc := lw.ListFunc
+	lw.ListFunc = func(opt metav1.ListOptions) (runtime.Object, error) {
+		list, err := listFunc(opt)
+		if err != nil {
+			release()
+			return list, err
+		}
+		if l, err := meta.ListAccessor(list); err != nil || l.GetContinue() == "" {
+			release()
+		}
+		return list, nil
+	}
+}
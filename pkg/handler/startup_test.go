@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestListLimiter(t *testing.T) {
+	l := newListLimiter(1)
+	release, ok := l.acquire(context.Background(), 0)
+	if !ok {
+		t.Fatal("acquire(...): want the first list to start")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := l.acquire(ctx, 0); ok {
+		t.Error("acquire(...): want the second list to wait for the first")
+	}
+
+	release()
+	// releasing twice must not free another slot
+	release()
+	if _, ok := l.acquire(context.Background(), 0); !ok {
+		t.Error("acquire(...): want a list to start after the first was released")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := l.acquire(ctx, 0); ok {
+		t.Error("acquire(...): want a single slot freed by releasing twice")
+	}
+
+	if _, ok := newListLimiter(0).acquire(context.Background(), 0); !ok {
+		t.Error("acquire(...): want lists not limited without a limit")
+	}
+}
+
+func TestListLimiterPriority(t *testing.T) {
+	l := newListLimiter(1)
+	release, _ := l.acquire(context.Background(), 0)
+
+	started := make(chan string, 3)
+	queue := func(name string, priority int) {
+		l.mu.Lock()
+		queued := len(l.waiting)
+		l.mu.Unlock()
+		go func() {
+			release, _ := l.acquire(context.Background(), priority)
+			started <- name
+			release()
+		}()
+		// wait for the list to be queued, so that the order is known
+		for {
+			l.mu.Lock()
+			n := len(l.waiting)
+			l.mu.Unlock()
+			if n > queued {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	queue("low", 0)
+	queue("high", 10)
+	queue("high-later", 10)
+	release()
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		got = append(got, <-started)
+	}
+	want := []string{"high", "high-later", "low"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("acquire(...): want lists of a higher priority first, -want, +got:\n%s", diff)
+	}
+}
+
+func TestReleaseAfterList(t *testing.T) {
+	page := func(cont string) *unstructured.UnstructuredList {
+		l := &unstructured.UnstructuredList{Object: map[string]any{}}
+		l.SetContinue(cont)
+		return l
+	}
+	cases := map[string]struct {
+		reason string
+		list   runtime.Object
+		err    error
+		want   int
+	}{
+		"Page": {
+			reason: "Should not release the list before its last page.",
+			list:   page("next"),
+			want:   0,
+		},
+		"LastPage": {
+			reason: "Should release the list after its last page.",
+			list:   page(""),
+			want:   1,
+		},
+		"Error": {
+			reason: "Should release a failed list.",
+			err:    errors.New("boom"),
+			want:   1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			lw := &cache.ListWatch{ListFunc: func(_ metav1.ListOptions) (runtime.Object, error) { return tc.list, tc.err }}
+			released := 0
+			releaseAfterList(lw, func() { released++ })
+			_, _ = lw.List(metav1.ListOptions{})
+			if diff := cmp.Diff(tc.want, released); diff != "" {
+				t.Errorf("\n%s\nreleaseAfterList(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// responseBufferSize is the size of the buffered writers responses are
+// written through, coalescing the writes of single series.
+const responseBufferSize = 64 << 10
+
+// responseWriterPool holds the buffered writers responses are written through.
+var responseWriterPool = sync.Pool{New: func() any { return bufio.NewWriterSize(nil, responseBufferSize) }}
+
+// bufferedWriter returns a pooled buffered writer of w and the function
+// flushing and releasing it.
+func bufferedWriter(w io.Writer) (*bufio.Writer, func()) {
+	bw := responseWriterPool.Get().(*bufio.Writer)
+	bw.Reset(w)
+	return bw, func() {
+		_ = bw.Flush()
+		bw.Reset(nil)
+		responseWriterPool.Put(bw)
+	}
+}
+
+// streamWriter buffers the output of a store rendered ahead of its turn in a
+// pooled buffer, and writes through to w once its turn has come, so that
+// stores are never held in memory as a whole while they are written.
+type streamWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	buf    *bytes.Buffer
+	direct bool
+}
+
+func newStreamWriter(w io.Writer) *streamWriter {
+	return &streamWriter{w: w, buf: bufferPool.Get().(*bytes.Buffer)}
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.direct {
+		return s.w.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// stream writes the buffered output to w and all further writes through.
+func (s *streamWriter) stream() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.buf.WriteTo(s.w)
+	s.buf.Reset()
+	bufferPool.Put(s.buf)
+	s.buf = nil
+	s.direct = true
+}
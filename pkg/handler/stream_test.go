@@ -0,0 +1,42 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStreamWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := newStreamWriter(out)
+
+	_, _ = s.Write([]byte("a 1\n"))
+	if diff := cmp.Diff("", out.String()); diff != "" {
+		t.Errorf("\nShould buffer writes before the turn of the store.\nWrite(...): -want, +got:\n%s", diff)
+	}
+	s.stream()
+	if diff := cmp.Diff("a 1\n", out.String()); diff != "" {
+		t.Errorf("\nShould write the buffered output once streamed.\nstream(): -want, +got:\n%s", diff)
+	}
+	_, _ = s.Write([]byte("b 1\n"))
+	if diff := cmp.Diff("a 1\nb 1\n", out.String()); diff != "" {
+		t.Errorf("\nShould write through once streamed.\nWrite(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// lastAppliedAnnotation holds the full object as applied by kubectl
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// transformFunc modifies objects before metrics are generated for them.
+type transformFunc func(obj *unstructured.Unstructured)
+
+// stripObject returns a transformFunc removing the fields which are never
+// exported, and the spec if dropSpec is set.
+func stripObject(dropSpec bool) transformFunc {
+	return func(obj *unstructured.Unstructured) {
+		obj.SetManagedFields(nil)
+		if a := obj.GetAnnotations(); a != nil {
+			if _, ok := a[lastAppliedAnnotation]; ok {
+				delete(a, lastAppliedAnnotation)
+				obj.SetAnnotations(a)
+			}
+		}
+		if dropSpec {
+			delete(obj.Object, "spec")
+		}
+	}
+}
+
+// transformList applies fn to all items of a list.
+func transformList(list *unstructured.UnstructuredList, fn transformFunc) {
+	if fn == nil {
+		return
+	}
+	for i := range list.Items {
+		fn(&list.Items[i])
+	}
+}
+
+// transformWatch applies fn to the objects of all events of w, dropping
+// events of objects not owned by the shard.
+func transformWatch(w watch.Interface, fn transformFunc, s Sharding) watch.Interface {
+	if fn == nil && s.TotalShards <= 1 {
+		return w
+	}
+	return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+		if !shardEvent(e, s) {
+			return e, false
+		}
+		if obj, ok := e.Object.(*unstructured.Unstructured); ok && fn != nil {
+			fn(obj)
+		}
+		return e, true
+	})
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestStripObject(t *testing.T) {
+	object := func() map[string]any {
+		return map[string]any{
+			"metadata": map[string]any{
+				"name": "obj",
+				"annotations": map[string]any{
+					lastAppliedAnnotation: "{}",
+					"team":                "platform",
+				},
+				"managedFields": []any{map[string]any{"manager": "kubectl"}},
+			},
+			"spec":   map[string]any{"forProvider": map[string]any{"region": "eu-west-1"}},
+			"status": map[string]any{"atProvider": map[string]any{}},
+		}
+	}
+
+	cases := map[string]struct {
+		reason   string
+		dropSpec bool
+		want     map[string]any
+	}{
+		"Default": {
+			reason: "Should remove managed fields and the last applied configuration.",
+			want: map[string]any{
+				"metadata": map[string]any{
+					"name":        "obj",
+					"annotations": map[string]any{"team": "platform"},
+				},
+				"spec":   map[string]any{"forProvider": map[string]any{"region": "eu-west-1"}},
+				"status": map[string]any{"atProvider": map[string]any{}},
+			},
+		},
+		"DropSpec": {
+			reason:   "Should remove the spec if requested.",
+			dropSpec: true,
+			want: map[string]any{
+				"metadata": map[string]any{
+					"name":        "obj",
+					"annotations": map[string]any{"team": "platform"},
+				},
+				"status": map[string]any{"atProvider": map[string]any{}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: object()}
+			stripObject(tc.dropSpec)(obj)
+			if diff := cmp.Diff(tc.want, obj.Object); diff != "" {
+				t.Errorf("\n%s\nstripObject(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
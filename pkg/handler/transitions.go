@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// transitions counts the changes of the Ready and Synced conditions of the
+// objects of a store observed by its watch. The counts start at zero when the
+// store is registered, and the first observation of an object is no change.
+// It also keeps the first transition of the objects to Ready=True, which later
+// transitions don't replace.
+type transitions struct {
+	mu      sync.Mutex
+	objects map[types.UID]*transitionState
+}
+
+type transitionState struct {
+	ready, synced           float64
+	readyCount, syncedCount int
+	// firstReady is the transition time of the Ready condition when the
+	// object was first observed with Ready=True, zero until then
+	firstReady time.Time
+}
+
+func newTransitions() *transitions {
+	return &transitions{objects: map[types.UID]*transitionState{}}
+}
+
+// observe records the condition values of an object and returns its state:
+// the number of changes observed so far and its first transition to
+// Ready=True.
+func (t *transitions) observe(uid types.UID, ready, synced float64, readyTime time.Time) transitionState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.objects[uid]
+	if !ok {
+		s = &transitionState{ready: ready, synced: synced}
+		t.objects[uid] = s
+	}
+	if s.ready != ready {
+		s.ready = ready
+		s.readyCount++
+	}
+	if s.synced != synced {
+		s.synced = synced
+		s.syncedCount++
+	}
+	if ready == 1 && s.firstReady.IsZero() {
+		s.firstReady = readyTime
+	}
+	return *s
+}
+
+// forget removes the counts of a deleted object.
+func (t *transitions) forget(uid types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.objects, uid)
+}
+
+// retain removes the counts of all objects not in uids, e.g. after a relist.
+func (t *transitions) retain(uids map[types.UID]objectStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for uid := range t.objects {
+		if _, ok := uids[uid]; !ok {
+			delete(t.objects, uid)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+)
+
+// resumeWatches makes the watches of lw request bookmarks and retries watches
+// failing transiently, e.g. while the API server restarts, with backoff until
+// ctx is done. The reflector resumes from the resource version of the last
+// bookmark then, instead of listing all objects again. Bookmarks keep the
+// resource version recent, so that it isn't expired when the watch resumes.
+func resumeWatches(ctx context.Context, lw *cache.ListWatch, gvr schema.GroupVersionResource) {
+	watchFunc := lw.WatchFunc
+	lw.WatchFunc = func(opt metav1.ListOptions) (watch.Interface, error) {
+		opt.AllowWatchBookmarks = true
+		backoff := wait.NewExponentialBackoffManager(reflectorBackoffInitial, reflectorBackoffMax, reflectorBackoffReset, reflectorBackoffFactor, reflectorBackoffJitter, clock.RealClock{})
+		for retries := 1; ; retries++ {
+			w, err := watchFunc(opt)
+			if err == nil {
+				return countWatchEvents(w, gvr), nil
+			}
+			if !transientWatchError(err) {
+				return nil, err
+			}
+			debugLog(ctx).Info("watch failed transiently, resuming", "error", err.Error(), "resourceVersion", opt.ResourceVersion, "retries", retries)
+			select {
+			case <-ctx.Done():
+				return nil, err
+			case <-backoff.Backoff().C():
+			}
+			watchResumes.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Inc()
+		}
+	}
+}
+
+// transientWatchError returns true for errors of watches failing while the
+// API server is unavailable.
+func transientWatchError(err error) bool {
+	return apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// countWatchEvents counts the bookmark events of w and the errors ending it
+// because its resource version expired.
+func countWatchEvents(w watch.Interface, gvr schema.GroupVersionResource) watch.Interface {
+	bookmarks := watchBookmarks.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)
+	expirations := watchExpirations.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)
+	return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+		switch e.Type {
+		case watch.Bookmark:
+			bookmarks.Inc()
+		case watch.Error:
+			if err := apierrors.FromObject(e.Object); apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				expirations.Inc()
+			}
+		}
+		return e, true
+	})
+}
+
+// countLists counts the failed list requests of lw by reason and the lists
+// of all objects following the first one.
+func countLists(ctx context.Context, lw *cache.ListWatch, gvr schema.GroupVersionResource) {
+	listFunc := lw.ListFunc
+	listed := false
+	debug := debugLog(ctx)
+	lw.ListFunc = func(opt metav1.ListOptions) (runtime.Object, error) {
+		// the first page starts a list of all objects
+		if opt.Continue == "" {
+			if listed {
+				relists.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Inc()
+			}
+			listed = true
+		}
+		list, err := listFunc(opt)
+		if err != nil {
+			reason := string(apierrors.ReasonForError(err))
+			if reason == "" {
+				reason = string(metav1.StatusReasonUnknown)
+			}
+			listErrors.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource, reason).Inc()
+			debug.Info("list failed", "error", err.Error(), "reason", reason, "continue", opt.Continue != "")
+			return list, err
+		}
+		if l, lerr := meta.ListAccessor(list); lerr == nil {
+			debug.Info("listed page", "items", meta.LenList(list), "resourceVersion", l.GetResourceVersion(), "continue", l.GetContinue() != "")
+		}
+		return list, err
+	}
+}
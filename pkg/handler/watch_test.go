@@ -0,0 +1,171 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResumeWatches(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "watch.example.org", Version: "v1", Resource: "resumes"}
+	unavailable := apierrors.NewServiceUnavailable("restarting")
+
+	cases := map[string]struct {
+		reason      string
+		errs        []error
+		wantErr     bool
+		wantResumes float64
+	}{
+		"Success": {
+			reason: "Should return the watch requesting bookmarks.",
+		},
+		"Transient": {
+			reason:      "Should retry watches failing while the API server is unavailable.",
+			errs:        []error{unavailable},
+			wantResumes: 1,
+		},
+		"Permanent": {
+			reason:  "Should return other errors, so that the reflector lists again.",
+			errs:    []error{apierrors.NewForbidden(gvr.GroupResource(), "", errors.New("denied"))},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			watchResumes.Reset()
+			errs := tc.errs
+			fake := watch.NewFake()
+			var bookmarks bool
+			lw := &cache.ListWatch{WatchFunc: func(opt metav1.ListOptions) (watch.Interface, error) {
+				bookmarks = opt.AllowWatchBookmarks
+				if len(errs) > 0 {
+					err := errs[0]
+					errs = errs[1:]
+					return nil, err
+				}
+				return fake, nil
+			}}
+			resumeWatches(context.Background(), lw, gvr)
+
+			w, err := lw.Watch(metav1.ListOptions{})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("\n%s\nWatch(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+			if got := testutil.ToFloat64(watchResumes.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)); got != tc.wantResumes {
+				t.Errorf("\n%s\nWatch(...): want %v resumes, got %v", tc.reason, tc.wantResumes, got)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !bookmarks {
+				t.Errorf("\n%s\nWatch(...): want bookmarks requested", tc.reason)
+			}
+			defer w.Stop()
+
+			before := testutil.ToFloat64(watchBookmarks.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource))
+			go fake.Action(watch.Bookmark, &unstructured.Unstructured{})
+			if e := <-w.ResultChan(); e.Type != watch.Bookmark {
+				t.Fatalf("\n%s\nResultChan(): want bookmark, got %v", tc.reason, e.Type)
+			}
+			if got := testutil.ToFloat64(watchBookmarks.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)) - before; got != 1 {
+				t.Errorf("\n%s\nResultChan(): want 1 bookmark counted, got %v", tc.reason, got)
+			}
+		})
+	}
+}
+
+func TestCountLists(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "watch.example.org", Version: "v1", Resource: "lists"}
+	forbidden := apierrors.NewForbidden(gvr.GroupResource(), "", errors.New("denied"))
+	var err error
+	lw := &cache.ListWatch{ListFunc: func(opt metav1.ListOptions) (runtime.Object, error) {
+		return &unstructured.UnstructuredList{}, err
+	}}
+	countLists(context.Background(), lw, gvr)
+
+	// the initial list of two pages, a failed relist and a relist
+	_, _ = lw.List(metav1.ListOptions{})
+	_, _ = lw.List(metav1.ListOptions{Continue: "page-2"})
+	err = forbidden
+	_, _ = lw.List(metav1.ListOptions{})
+	err = nil
+	_, _ = lw.List(metav1.ListOptions{})
+
+	if got := testutil.ToFloat64(relists.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)); got != 2 {
+		t.Errorf("List(...): want 2 relists, got %v", got)
+	}
+	if got := testutil.ToFloat64(listErrors.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource, "Forbidden")); got != 1 {
+		t.Errorf("List(...): want 1 forbidden list, got %v", got)
+	}
+}
+
+func TestWatchExpirations(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "watch.example.org", Version: "v1", Resource: "expirations"}
+	fake := watch.NewFake()
+	w := countWatchEvents(fake, gvr)
+	defer w.Stop()
+
+	go fake.Error(&apierrors.NewResourceExpired("too old resource version").ErrStatus)
+	if e := <-w.ResultChan(); e.Type != watch.Error {
+		t.Fatalf("ResultChan(): want error, got %v", e.Type)
+	}
+	if got := testutil.ToFloat64(watchExpirations.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource)); got != 1 {
+		t.Errorf("ResultChan(): want 1 expiration, got %v", got)
+	}
+}
+
+type syncRecorder struct {
+	syncs int
+}
+
+func (r *syncRecorder) recordSync() {
+	r.syncs++
+}
+
+func TestRecordWatches(t *testing.T) {
+	fake := watch.NewFake()
+	lw := &cache.ListWatch{WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+		return fake, nil
+	}}
+	r := &syncRecorder{}
+	recordWatches(lw, r)
+	w, err := lw.WatchFunc(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Watch(...): %v", err)
+	}
+	defer w.Stop()
+
+	go fake.Action(watch.Bookmark, &unstructured.Unstructured{})
+	if e := <-w.ResultChan(); e.Type != watch.Bookmark {
+		t.Fatalf("ResultChan(): want bookmark, got %v", e.Type)
+	}
+	if r.syncs != 2 {
+		t.Errorf("ResultChan(): want 2 syncs for the start of the watch and its bookmark, got %d", r.syncs)
+	}
+}
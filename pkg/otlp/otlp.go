@@ -0,0 +1,226 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package otlp pushes the metric families of x-metrics to an OpenTelemetry
+// collector via OTLP/HTTP.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultInterval is the default interval between two exports.
+const DefaultInterval = time.Minute
+
+// Writer writes metric families in the Prometheus text format.
+type Writer interface {
+	WriteAll(w io.Writer)
+}
+
+// Exporter periodically converts the families written by Source into OTLP
+// gauges and pushes them to Endpoint, using the JSON encoding of OTLP/HTTP.
+type Exporter struct {
+	// Endpoint is the URL of the metrics endpoint of the collector, e.g.
+	// http://otel-collector:4318/v1/metrics
+	Endpoint string
+	// Interval between two exports. Defaults to DefaultInterval
+	Interval time.Duration
+	// Headers are added to each request, e.g. for authentication
+	Headers map[string]string
+	// Resource holds the attributes of the OTLP resource
+	Resource map[string]string
+	Source   Writer
+	Client   *http.Client
+}
+
+// Start exports until ctx is done. It implements manager.Runnable.
+func (e *Exporter) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithValues("endpoint", e.Endpoint)
+	interval := e.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			if err := e.Export(ctx); err != nil {
+				log.Info("cannot export metrics", "error", err.Error())
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false, as every shard exports its objects.
+func (e *Exporter) NeedLeaderElection() bool {
+	return false
+}
+
+// Export pushes the current families of Source to the collector.
+func (e *Exporter) Export(ctx context.Context) error {
+	buf := &bytes.Buffer{}
+	e.Source.WriteAll(buf)
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(buf)
+	if err != nil {
+		return fmt.Errorf("cannot parse metric families: %w", err)
+	}
+	body, err := json.Marshal(e.request(families, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	c := e.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// The following types are the subset of the JSON encoding of an OTLP
+// ExportMetricsServiceRequest used for gauges.
+
+type exportRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Gauge       gauge  `json:"gauge"`
+}
+
+type gauge struct {
+	DataPoints []dataPoint `json:"dataPoints"`
+}
+
+type dataPoint struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+	// TimeUnixNano is a fixed64, which is encoded as string
+	TimeUnixNano string  `json:"timeUnixNano"`
+	AsDouble     float64 `json:"asDouble"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// request converts the families into an OTLP request. Series without a
+// finite value are dropped, as they can't be encoded as JSON.
+func (e *Exporter) request(families map[string]*dto.MetricFamily, now time.Time) exportRequest {
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+
+	names := make([]string, 0, len(families))
+	for n := range families {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	metrics := make([]metric, 0, len(families))
+	for _, n := range names {
+		f := families[n]
+		m := metric{Name: n, Description: f.GetHelp()}
+		for _, s := range f.GetMetric() {
+			v := s.GetGauge().GetValue()
+			if s.GetGauge() == nil {
+				v = s.GetUntyped().GetValue()
+			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			dp := dataPoint{TimeUnixNano: ts, AsDouble: v}
+			for _, l := range s.GetLabel() {
+				if l.GetValue() != "" {
+					dp.Attributes = append(dp.Attributes, keyValue{Key: l.GetName(), Value: anyValue{StringValue: l.GetValue()}})
+				}
+			}
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+		}
+		if len(m.Gauge.DataPoints) > 0 {
+			metrics = append(metrics, m)
+		}
+	}
+
+	return exportRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     resource{Attributes: attributes(e.Resource)},
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: "x-metrics"}, Metrics: metrics}},
+	}}}
+}
+
+// attributes returns the attributes sorted by key.
+func attributes(m map[string]string) []keyValue {
+	kvs := make([]keyValue, 0, len(m))
+	for k, v := range m {
+		kvs = append(kvs, keyValue{Key: k, Value: anyValue{StringValue: v}})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
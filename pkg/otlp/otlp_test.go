@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type text string
+
+func (t text) WriteAll(w io.Writer) {
+	_, _ = io.WriteString(w, string(t))
+}
+
+func TestExport(t *testing.T) {
+	type want struct {
+		request exportRequest
+		header  string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		source text
+		status int
+		want   want
+	}{
+		"Gauges": {
+			reason: "Should push each series as a gauge data point with its labels as attributes.",
+			source: "# TYPE test_ready gauge\n# HELP test_ready Ready condition\ntest_ready{name=\"a\",namespace=\"\"} 1\ntest_ready{name=\"b\",namespace=\"\"} 0\n" +
+				"# TYPE test_created gauge\n# HELP test_created Creation timestamp\ntest_created{name=\"a\"} 1.6e+09\n",
+			status: http.StatusOK,
+			want: want{
+				header: "secret",
+				request: exportRequest{ResourceMetrics: []resourceMetrics{{
+					Resource: resource{Attributes: []keyValue{{Key: "service.name", Value: anyValue{StringValue: "x-metrics"}}}},
+					ScopeMetrics: []scopeMetrics{{Scope: scope{Name: "x-metrics"}, Metrics: []metric{
+						{Name: "test_created", Description: "Creation timestamp", Gauge: gauge{DataPoints: []dataPoint{
+							{Attributes: []keyValue{{Key: "name", Value: anyValue{StringValue: "a"}}}, AsDouble: 1.6e+09},
+						}}},
+						{Name: "test_ready", Description: "Ready condition", Gauge: gauge{DataPoints: []dataPoint{
+							{Attributes: []keyValue{{Key: "name", Value: anyValue{StringValue: "a"}}}, AsDouble: 1},
+							{Attributes: []keyValue{{Key: "name", Value: anyValue{StringValue: "b"}}}, AsDouble: 0},
+						}}},
+					}}},
+				}}},
+			},
+		},
+		"CollectorError": {
+			reason: "Should return an error if the collector rejects the request.",
+			source: "# TYPE test gauge\n# HELP test help\ntest{name=\"a\"} 1\n",
+			status: http.StatusServiceUnavailable,
+			want:   want{err: cmpopts.AnyError},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got.header = r.Header.Get("Authorization")
+				_ = json.NewDecoder(r.Body).Decode(&got.request)
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			e := &Exporter{
+				Endpoint: srv.URL + "/v1/metrics",
+				Headers:  map[string]string{"Authorization": "secret"},
+				Resource: map[string]string{"service.name": "x-metrics"},
+				Source:   tc.source,
+			}
+			got.err = e.Export(context.Background())
+			if tc.want.err != nil {
+				if diff := cmp.Diff(tc.want.err, got.err, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("\n%s\nExport(...): -want error, +got error:\n%s", tc.reason, diff)
+				}
+				return
+			}
+			if got.err != nil {
+				t.Fatalf("\n%s\nExport(...): %v", tc.reason, got.err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}), cmpopts.IgnoreFields(dataPoint{}, "TimeUnixNano"), cmpopts.IgnoreFields(want{}, "err")); diff != "" {
+				t.Errorf("\n%s\nExport(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
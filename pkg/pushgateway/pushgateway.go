@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pushgateway pushes the metric families of x-metrics to a Prometheus
+// Pushgateway, e.g. before a short-lived instance exits.
+package pushgateway
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// DefaultJob is the default job label of pushed metrics.
+const DefaultJob = "x-metrics"
+
+// Writer writes metric families in the Prometheus text format.
+type Writer interface {
+	WriteAll(w io.Writer)
+}
+
+// Pusher replaces the metrics of a group of a Pushgateway with the families
+// written by Source.
+type Pusher struct {
+	// URL of the Pushgateway, e.g. http://pushgateway:9091
+	URL string
+	// Job label of the group. Defaults to DefaultJob
+	Job string
+	// Grouping holds additional labels of the group, e.g. the environment
+	Grouping map[string]string
+	Source   Writer
+	Client   *http.Client
+}
+
+// Push replaces the metrics of the group with the current families of Source.
+func (p *Pusher) Push() error {
+	job := p.Job
+	if job == "" {
+		job = DefaultJob
+	}
+	pusher := push.New(p.URL, job).Gatherer(prometheus.GathererFunc(p.gather))
+	for k, v := range p.Grouping {
+		pusher = pusher.Grouping(k, v)
+	}
+	if p.Client != nil {
+		pusher = pusher.Client(p.Client)
+	}
+	return pusher.Push()
+}
+
+func (p *Pusher) gather() ([]*dto.MetricFamily, error) {
+	buf := &bytes.Buffer{}
+	p.Source.WriteAll(buf)
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse metric families: %w", err)
+	}
+	out := make([]*dto.MetricFamily, 0, len(families))
+	for _, f := range families {
+		out = append(out, f)
+	}
+	return out, nil
+}
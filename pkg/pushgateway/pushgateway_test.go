@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pushgateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+type text string
+
+func (t text) WriteAll(w io.Writer) {
+	_, _ = io.WriteString(w, string(t))
+}
+
+func TestPush(t *testing.T) {
+	type want struct {
+		method   string
+		path     string
+		families []string
+		err      error
+	}
+	cases := map[string]struct {
+		reason   string
+		source   text
+		grouping map[string]string
+		status   int
+		want     want
+	}{
+		"Families": {
+			reason:   "Should replace the group of the job and grouping labels with the families of the source.",
+			source:   "# HELP test_ready Ready condition\n# TYPE test_ready gauge\ntest_ready{name=\"a\"} 1\n# HELP test_synced Synced condition\n# TYPE test_synced gauge\ntest_synced{name=\"a\"} 1\n",
+			grouping: map[string]string{"env": "ci"},
+			status:   http.StatusOK,
+			want: want{
+				method:   http.MethodPut,
+				path:     "/metrics/job/x-metrics/env/ci",
+				families: []string{"test_ready", "test_synced"},
+			},
+		},
+		"InvalidSource": {
+			reason: "Should return an error if the families can't be parsed.",
+			source: "test_ready{name=\"a\" 1\n",
+			status: http.StatusOK,
+			want:   want{err: cmpopts.AnyError},
+		},
+		"PushgatewayError": {
+			reason: "Should return an error if the Pushgateway rejects the request.",
+			source: "# TYPE test gauge\ntest{name=\"a\"} 1\n",
+			status: http.StatusBadRequest,
+			want: want{
+				method:   http.MethodPut,
+				path:     "/metrics/job/x-metrics",
+				families: []string{"test"},
+				err:      cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got.method, got.path = r.Method, r.URL.Path
+				dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+				for {
+					f := &dto.MetricFamily{}
+					if err := dec.Decode(f); err != nil {
+						break
+					}
+					got.families = append(got.families, f.GetName())
+				}
+				sort.Strings(got.families)
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			p := &Pusher{URL: srv.URL, Grouping: tc.grouping, Source: tc.source}
+			got.err = p.Push()
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}), cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nPush(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// authCacheTTL is the duration decisions for a token, verb and path are cached,
+// so that scrapes don't cause a TokenReview and SubjectAccessReview each.
+const authCacheTTL = time.Minute
+
+// authHandler authenticates requests by a TokenReview of their bearer token
+// and authorizes them by a SubjectAccessReview of the non-resource URL, as
+// done by kube-rbac-proxy.
+type authHandler struct {
+	client  kubernetes.Interface
+	handler http.Handler
+	now     func() time.Time
+
+	mu        sync.Mutex
+	decisions map[string]decision
+}
+
+type decision struct {
+	status  int
+	expires time.Time
+}
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// WithAuth returns a handler serving requests by h only if the bearer token
+// of the request belongs to a user allowed to get the requested path, e.g. by
+// a ClusterRole with the non-resource URL /x-metrics.
+func WithAuth(c kubernetes.Interface, h http.Handler) http.Handler {
+	return &authHandler{client: c, handler: h, now: time.Now, decisions: map[string]decision{}}
+}
+
+func (a *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	status := a.authorize(r, token)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	a.handler.ServeHTTP(w, r)
+}
+
+// authorize returns http.StatusOK if the user of token may access the path of
+// the request, otherwise the status to respond with.
+func (a *authHandler) authorize(r *http.Request, token string) int {
+	verb := strings.ToLower(r.Method)
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:]) + " " + verb + " " + r.URL.Path
+
+	a.mu.Lock()
+	d, ok := a.decisions[key]
+	a.mu.Unlock()
+	if ok && a.now().Before(d.expires) {
+		return d.status
+	}
+
+	status, err := a.review(r, token, verb)
+	if err != nil {
+		// Errors of the API server are not cached
+		log.FromContext(r.Context()).Info("cannot review metrics request", "error", err.Error())
+		return http.StatusInternalServerError
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Drop expired decisions, so that rotated tokens don't accumulate
+	for k, d := range a.decisions {
+		if !a.now().Before(d.expires) {
+			delete(a.decisions, k)
+		}
+	}
+	a.decisions[key] = decision{status: status, expires: a.now().Add(authCacheTTL)}
+	return status
+}
+
+func (a *authHandler) review(r *http.Request, token, verb string) (int, error) {
+	tr, err := a.client.AuthenticationV1().TokenReviews().Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return 0, err
+	}
+	if !tr.Status.Authenticated {
+		return http.StatusUnauthorized, nil
+	}
+
+	user := tr.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	sar, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: r.URL.Path,
+				Verb: verb,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return 0, err
+	}
+	if !sar.Status.Allowed {
+		return http.StatusForbidden, nil
+	}
+	return http.StatusOK, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	return token, ok && token != ""
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// newReviewClient returns a client authenticating the token "valid" as user
+// "scraper", which is allowed to get /x-metrics only.
+func newReviewClient(reviews *int, err error) *fake.Clientset {
+	c := fake.NewSimpleClientset()
+	c.PrependReactor("create", "tokenreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		if err != nil {
+			return true, nil, err
+		}
+		tr := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if tr.Spec.Token == "valid" {
+			tr.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "scraper"}}
+		}
+		return true, tr, nil
+	})
+	c.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		sar := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attr := sar.Spec.NonResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "scraper" && attr.Path == "/x-metrics" && attr.Verb == "get"
+		return true, sar, nil
+	})
+	return c
+}
+
+func TestWithAuth(t *testing.T) {
+	type want struct {
+		status  int
+		reviews int
+	}
+	cases := map[string]struct {
+		reason   string
+		header   string
+		path     string
+		requests int
+		err      error
+		want     want
+	}{
+		"NoToken": {
+			reason:   "Should reject requests without bearer token.",
+			path:     "/x-metrics",
+			requests: 1,
+			want:     want{status: http.StatusUnauthorized},
+		},
+		"InvalidToken": {
+			reason:   "Should reject requests with a token not authenticated by the TokenReview.",
+			header:   "Bearer invalid",
+			path:     "/x-metrics",
+			requests: 1,
+			want:     want{status: http.StatusUnauthorized, reviews: 1},
+		},
+		"Forbidden": {
+			reason:   "Should reject requests to paths the user is not allowed to get.",
+			header:   "Bearer valid",
+			path:     "/metrics",
+			requests: 1,
+			want:     want{status: http.StatusForbidden, reviews: 1},
+		},
+		"Allowed": {
+			reason:   "Should serve allowed requests and cache the decision.",
+			header:   "Bearer valid",
+			path:     "/x-metrics",
+			requests: 3,
+			want:     want{status: http.StatusOK, reviews: 1},
+		},
+		"ReviewFailed": {
+			reason:   "Should not cache failed reviews.",
+			header:   "Bearer valid",
+			path:     "/x-metrics",
+			requests: 2,
+			err:      errors.New("connection refused"),
+			want:     want{status: http.StatusInternalServerError, reviews: 2},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			reviews := 0
+			h := WithAuth(newReviewClient(&reviews, tc.err), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			got := want{}
+			for i := 0; i < tc.requests; i++ {
+				r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+				if tc.header != "" {
+					r.Header.Set("Authorization", tc.header)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				got.status = w.Code
+			}
+			got.reviews = reviews
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nServeHTTP(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
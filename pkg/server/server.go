@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package server serves the metrics of x-metrics over HTTPS.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// shutdownTimeout is the time given to running requests on shutdown.
+const shutdownTimeout = 10 * time.Second
+
+// Server serves Handler over HTTPS. Rotated certificates are reloaded without
+// a restart.
+type Server struct {
+	// Addr is the address the server binds to, e.g. ":8443"
+	Addr string
+	// CertFile and KeyFile are the paths of the PEM encoded certificate and key
+	CertFile string
+	KeyFile  string
+	Handler  http.Handler
+}
+
+// Start serves until ctx is done. It implements manager.Runnable.
+func (s *Server) Start(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.serve(ctx, l)
+}
+
+// NeedLeaderElection returns false, as all replicas serve metrics.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) serve(ctx context.Context, l net.Listener) error {
+	log := log.FromContext(ctx).WithValues("addr", l.Addr().String())
+
+	cw, err := certwatcher.New(s.CertFile, s.KeyFile)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	go func() {
+		if err := cw.Start(ctx); err != nil {
+			log.Error(err, "certificate watcher failed")
+		}
+	}()
+
+	srv := &http.Server{
+		Handler:           s.Handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Info("serving metrics over HTTPS")
+	tl := tls.NewListener(l, &tls.Config{
+		GetCertificate: cw.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	})
+	if err := srv.Serve(tl); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
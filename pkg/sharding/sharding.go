@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding derives the shard of an x-metrics instance from the
+// StatefulSet it is running in.
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// Ordinal returns the ordinal of a pod of a StatefulSet, which is the suffix
+// of its name.
+func Ordinal(pod string) (int32, error) {
+	i := strings.LastIndexByte(pod, '-')
+	if i < 0 {
+		return 0, fmt.Errorf("pod name %q has no ordinal", pod)
+	}
+	o, err := strconv.ParseInt(pod[i+1:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("pod name %q has no ordinal: %w", pod, err)
+	}
+	return int32(o), nil
+}
+
+// StatefulSet returns the name of the StatefulSet controlling the pod.
+func StatefulSet(ctx context.Context, c kubernetes.Interface, namespace, pod string) (string, error) {
+	p, err := c.CoreV1().Pods(namespace).Get(ctx, pod, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if o := metav1.GetControllerOf(p); o != nil && o.Kind == "StatefulSet" {
+		return o.Name, nil
+	}
+	return "", fmt.Errorf("pod %s/%s is not controlled by a StatefulSet", namespace, pod)
+}
+
+// FromStatefulSet returns the sharding of a pod, with its ordinal as shard and
+// the replicas of the StatefulSet as total shards.
+func FromStatefulSet(sts *appsv1.StatefulSet, pod string) (xmetrics.Sharding, error) {
+	o, err := Ordinal(pod)
+	if err != nil {
+		return xmetrics.Sharding{}, err
+	}
+	s := xmetrics.Sharding{Shard: o, TotalShards: 1}
+	if sts.Spec.Replicas != nil {
+		s.TotalShards = *sts.Spec.Replicas
+	}
+	// A pod of a scaled down StatefulSet is terminating, don't export anything
+	// twice until then
+	if s.Shard >= s.TotalShards {
+		return s, fmt.Errorf("pod %s is not part of the %d replicas of StatefulSet %s", pod, s.TotalShards, sts.Name)
+	}
+	return s, nil
+}
+
+// Watcher updates the sharding of a handler if the replicas of a StatefulSet
+// change.
+type Watcher struct {
+	Client      kubernetes.Interface
+	Namespace   string
+	StatefulSet string
+	Pod         string
+	Handler     interface{ SetSharding(xmetrics.Sharding) }
+}
+
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get
+
+// Start watches the StatefulSet until ctx is done. It implements
+// manager.Runnable.
+func (w *Watcher) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithValues("statefulSet", w.StatefulSet)
+	update := func(obj any) {
+		sts, ok := obj.(*appsv1.StatefulSet)
+		if !ok {
+			return
+		}
+		s, err := FromStatefulSet(sts, w.Pod)
+		if err != nil {
+			log.Info("cannot determine shard", "error", err.Error())
+			return
+		}
+		log.V(1).Info("updating sharding", "shard", s.Shard, "totalShards", s.TotalShards)
+		w.Handler.SetSharding(s)
+	}
+	lw := cache.NewListWatchFromClient(w.Client.AppsV1().RESTClient(), "statefulsets", w.Namespace, fields.OneTermEqualSelector("metadata.name", w.StatefulSet))
+	_, informer := cache.NewInformer(lw, &appsv1.StatefulSet{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    update,
+		UpdateFunc: func(_, obj any) { update(obj) },
+	})
+	informer.Run(ctx.Done())
+	return nil
+}
+
+// NeedLeaderElection returns false, as all shards need to follow the
+// StatefulSet.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+func TestFromStatefulSet(t *testing.T) {
+	type want struct {
+		sharding xmetrics.Sharding
+		err      error
+	}
+	cases := map[string]struct {
+		reason   string
+		pod      string
+		replicas *int32
+		want     want
+	}{
+		"Ordinal": {
+			reason:   "Should use the pod ordinal as shard and the replicas as total shards.",
+			pod:      "x-metrics-2",
+			replicas: pointer.Int32(3),
+			want:     want{sharding: xmetrics.Sharding{Shard: 2, TotalShards: 3}},
+		},
+		"DefaultReplicas": {
+			reason: "Should default to a single replica.",
+			pod:    "x-metrics-0",
+			want:   want{sharding: xmetrics.Sharding{Shard: 0, TotalShards: 1}},
+		},
+		"ScaledDown": {
+			reason:   "Should return an error for pods beyond the replicas.",
+			pod:      "x-metrics-3",
+			replicas: pointer.Int32(3),
+			want:     want{sharding: xmetrics.Sharding{Shard: 3, TotalShards: 3}, err: cmpopts.AnyError},
+		},
+		"NoOrdinal": {
+			reason: "Should return an error for pod names without ordinal.",
+			pod:    "x-metrics-5d8f9",
+			want:   want{err: cmpopts.AnyError},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "x-metrics"},
+				Spec:       appsv1.StatefulSetSpec{Replicas: tc.replicas},
+			}
+			s, err := FromStatefulSet(sts, tc.pod)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nFromStatefulSet(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.sharding, s); diff != "" {
+				t.Errorf("\n%s\nFromStatefulSet(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStatefulSet(t *testing.T) {
+	owned := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "x-metrics-0",
+		Namespace: "x-metrics",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "x-metrics", Controller: pointer.Bool(true)},
+		},
+	}}
+	standalone := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "x-metrics"}}
+	c := fake.NewSimpleClientset(owned, standalone)
+
+	type want struct {
+		name string
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		pod    string
+		want   want
+	}{
+		"Owned": {
+			reason: "Should return the StatefulSet controlling the pod.",
+			pod:    "x-metrics-0",
+			want:   want{name: "x-metrics"},
+		},
+		"Standalone": {
+			reason: "Should return an error for pods not controlled by a StatefulSet.",
+			pod:    "standalone",
+			want:   want{err: cmpopts.AnyError},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := StatefulSet(context.Background(), c, "x-metrics", tc.pod)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nStatefulSet(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.name, got); diff != "" {
+				t.Errorf("\n%s\nStatefulSet(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}